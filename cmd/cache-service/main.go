@@ -5,19 +5,43 @@
 // Пример запуска:
 //
 //	go run cmd/app/main.go -cache-size=100 -default-cache-ttl=60s -server-host-port=localhost:8080
+//
+// С флагом -check сервис не запускает сервер, а выполняет самопроверку
+// и завершается с кодом 0 при успехе или ненулевым кодом при ошибке.
 package main
 
 import (
 	"cache_service/config"
 	"cache_service/internal/cache"
 	"cache_service/internal/logger"
+	"cache_service/internal/preload"
+	"cache_service/internal/selfcheck"
 	"cache_service/internal/server"
+	"context"
+	"errors"
+	"flag"
+	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// tracerShutdownTimeout — время ожидания отправки накопленных спанов при остановке сервиса.
+const tracerShutdownTimeout = 10 * time.Second
+
 func main() {
+	checkMode := flag.Bool("check", false, "Run self-check and exit")
+
 	// Загружаем переменные окружения из файла .env
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
@@ -28,23 +52,164 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load configuration: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	// Режим самопроверки не запускает сервер
+	if *checkMode {
+		if err := selfcheck.Run(context.Background(), cfg); err != nil {
+			log.Fatalf("self-check failed: %v", err)
+		}
+		log.Println("self-check passed")
+		return
+	}
 
 	// Инициализируем логгер
 	logg := logger.NewLogger(cfg.LogLevel)
 
+	trustedProxies, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		log.Fatalf("failed to parse trusted proxies: %v", err)
+	}
+
 	// Инициализируем кэш
-	cacheInstance := cache.NewLRUCache(cfg.CacheSize, cfg.DefaultCacheTTL)
+	cacheOpts, err := cache.ConfigOptions(cfg, logg)
+	if err != nil {
+		log.Fatalf("failed to parse ttl overrides: %v", err)
+	}
+	cacheInstance := cache.NewLRUCache(cfg.CacheSize, cfg.DefaultCacheTTL, cacheOpts...)
+
+	// Сигнал остановки: сервер перестаёт принимать новые запросы к кэшу
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Заполняем кэш до открытия порта, чтобы не отвечать из пустого кэша
+	if cfg.PreloadURL != "" {
+		if err := preload.Run(ctx, cacheInstance, preload.PeerSource(cfg.PreloadURL), cfg.PreloadTimeout, logg); err != nil {
+			log.Fatalf("failed to preload cache: %v", err)
+		}
+	}
+
+	// Трассировка запросов: спаны выводятся в stdout пакетами
+	var tracerProvider *sdktrace.TracerProvider
+	if cfg.TracingEnabled {
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			log.Fatalf("failed to create trace exporter: %v", err)
+		}
+		tracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "cache-service"))),
+		)
+	}
 
 	// Настраиваем сервер
-	r := server.NewServer(cacheInstance, logg)
+	var accessLog io.Writer
+	if cfg.LogAccessFormat == config.AccessLogCombined {
+		accessLog = os.Stdout
+	}
+	serverOpts := []server.Option{
+		server.WithMaxConcurrentRequests(cfg.MaxConcurrentRequests),
+		server.WithCacheHeaders(cfg.CacheHeaders),
+		server.WithMaxResponseEntries(cfg.MaxResponseEntries),
+		server.WithMaxWait(cfg.MaxWait),
+		server.WithRawJSONValues(cfg.RawJSONValues),
+		server.WithJSONNumbers(cfg.JSONNumbers),
+		server.WithVerbosePanics(cfg.VerbosePanics),
+		server.WithTenantHeader(cfg.TenantHeader),
+		server.WithTrustedProxies(trustedProxies),
+		server.WithShutdownSignal(ctx.Done()),
+		server.WithSlowRequestThreshold(cfg.SlowRequestThreshold),
+		server.WithCombinedAccessLog(accessLog),
+	}
+	if tracerProvider != nil {
+		serverOpts = append(serverOpts, server.WithTracerProvider(tracerProvider))
+	}
+	// Служебные маршруты на отдельном порту, если он задан
+	var admin *chi.Mux
+	if cfg.AdminHostPort != "" {
+		admin = chi.NewRouter()
+		serverOpts = append(serverOpts, server.WithAdminRouter(admin))
+	}
+	r := server.NewServer(cacheInstance, logg, serverOpts...)
 
 	// Запуск HTTP-сервера
 	logg.Info("Starting server",
+		"version", server.Version,
+		"commit", server.Commit,
 		"host", cfg.ServerHostPort,
+		"admin_host", cfg.AdminHostPort,
 		"log_level", cfg.LogLevel,
+		"h2c", cfg.H2C,
 	)
 
-	if err := http.ListenAndServe(cfg.ServerHostPort, r); err != nil {
+	var handler http.Handler = r
+	if cfg.H2C {
+		handler = server.NewH2CHandler(handler)
+	}
+
+	srv := server.NewHTTPServer(cfg.ServerHostPort, handler, server.Timeouts{
+		ReadHeader: cfg.ReadHeaderTimeout,
+		Read:       cfg.ReadTimeout,
+		Write:      cfg.WriteTimeout,
+		Idle:       cfg.IdleTimeout,
+	})
+
+	// Служебный сервер без таймаута записи: снятие профиля через pprof длится дольше него
+	var adminSrv *server.HTTPServer
+	if admin != nil {
+		adminSrv = server.NewHTTPServer(cfg.AdminHostPort, admin, server.Timeouts{
+			ReadHeader: cfg.ReadHeaderTimeout,
+			Read:       cfg.ReadTimeout,
+			Idle:       cfg.IdleTimeout,
+		})
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logg.Error("Admin server failed to start", "error", err)
+			}
+		}()
+	}
+
+	// Останавливаем серверы по сигналу, дожидаясь завершения активных запросов не дольше ShutdownGrace
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		var wg sync.WaitGroup
+		if adminSrv != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := adminSrv.Drain(cfg.ShutdownGrace, logg); err != nil {
+					logg.Error("Admin server shutdown failed", "error", err)
+				}
+			}()
+		}
+		if err := srv.Drain(cfg.ShutdownGrace, logg); err != nil {
+			logg.Error("Server shutdown failed", "error", err)
+		}
+		wg.Wait()
+	}()
+
+	if err := srv.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
+		<-shutdownDone
+	} else if err != nil {
 		logg.Error("Server failed to start", "error", err)
 	}
+
+	// Отправляем накопленные спаны перед завершением
+	if tracerProvider != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+			logg.Error("Tracer provider shutdown failed", "error", err)
+		}
+		cancel()
+	}
+
+	// Освобождаем ресурсы кэша после остановки сервера
+	if err := cacheInstance.Close(); err != nil {
+		logg.Error("Failed to close cache", "error", err)
+	}
+	logg.Info("Server stopped")
 }
@@ -2,17 +2,73 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"github.com/caarlos0/env/v9"
 	_ "github.com/caarlos0/env/v9"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Форматы журнала доступа (LOG_ACCESS_FORMAT).
+const (
+	AccessLogStructured = "structured" // Структурированные записи slog
+	AccessLogCombined   = "combined"   // Apache Combined Log Format
+)
+
+// TTLOverride задаёт TTL по умолчанию для ключей, соответствующих шаблону.
+type TTLOverride struct {
+	Pattern string        // Шаблон ключа; «*» соответствует любой последовательности символов
+	TTL     time.Duration // TTL для ключей, записанных без явного TTL
+}
+
 // Config описывает параметры конфигурации приложения.
 type Config struct {
-	ServerHostPort  string        `env:"SERVER_HOST_PORT" envDefault:"localhost:8080"` // Адрес и порт сервера
-	CacheSize       int           `env:"CACHE_SIZE" envDefault:"10"`                   // Размер кэша
-	DefaultCacheTTL time.Duration `env:"DEFAULT_CACHE_TTL" envDefault:"1m"`            // Время жизни элемента по умолчанию
-	LogLevel        string        `env:"LOG_LEVEL" envDefault:"WARN"`                  // Уровень логирования
+	ServerHostPort        string        `env:"SERVER_HOST_PORT" envDefault:"localhost:8080"` // Адрес и порт сервера
+	AdminHostPort         string        `env:"ADMIN_HOST_PORT" envDefault:""`                // Адрес и порт служебного сервера с /metrics, /healthz и /debug/pprof (пусто — без него)
+	CacheSize             int           `env:"CACHE_SIZE" envDefault:"10"`                   // Размер кэша (0 — без ограничения, элементы удаляются только по TTL)
+	CacheSoftLimit        int           `env:"CACHE_SOFT_LIMIT" envDefault:"0"`              // Мягкий лимит числа элементов ниже размера кэша (0 — отключён)
+	EvictExpiredFirst     bool          `env:"EVICT_EXPIRED_FIRST" envDefault:"false"`       // При переполнении сначала удалять истёкшие элементы, а не конец списка
+	CachePrefixQuota      int           `env:"CACHE_PREFIX_QUOTA" envDefault:"0"`            // Максимальное число элементов с одним префиксом ключа (0 — без квоты)
+	CachePrefixSeparator  string        `env:"CACHE_PREFIX_SEPARATOR" envDefault:":"`        // Разделитель, завершающий префикс ключа для квоты
+	CaseInsensitiveKeys   bool          `env:"CASE_INSENSITIVE_KEYS" envDefault:"false"`     // Регистронезависимые ключи: ключи приводятся к нижнему регистру
+	CacheFullness         []int         `env:"CACHE_FULLNESS_ALERTS" envDefault:"80,90,100"` // Пороги заполненности кэша в процентах, о пересечении которых выводится предупреждение
+	CacheMaxBytes         int64         `env:"CACHE_MAX_BYTES" envDefault:"0"`               // Бюджет памяти кэша в байтах (0 — без ограничения)
+	CacheCleanupInterval  time.Duration `env:"CACHE_CLEANUP_INTERVAL" envDefault:"0s"`       // Интервал фоновой очистки истёкших элементов (0 — отключена)
+	MemoryCeiling         int64         `env:"MEMORY_CEILING" envDefault:"0"`                // Предел объёма кучи процесса в байтах, выше которого элементы вытесняются экстренно (0 — отключён)
+	MemoryCheckInterval   time.Duration `env:"MEMORY_CHECK_INTERVAL" envDefault:"1s"`        // Интервал проверки объёма кучи для предела памяти
+	SnapshotInterval      time.Duration `env:"SNAPSHOT_INTERVAL" envDefault:"0s"`            // Интервал обновления снимка для списка и экспорта без блокировки кэша (0 — отключено)
+	PreloadURL            string        `env:"PRELOAD_URL" envDefault:""`                    // Адрес экземпляра сервиса, из экспорта которого кэш заполняется при запуске (пусто — без загрузки)
+	PreloadTimeout        time.Duration `env:"PRELOAD_TIMEOUT" envDefault:"30s"`             // Максимальное время ожидания готовности источника предварительной загрузки
+	CacheCompressAbove    int           `env:"CACHE_COMPRESS_ABOVE" envDefault:"0"`          // Размер значения в байтах, начиная с которого оно сжимается (0 — без сжатия)
+	DefaultCacheTTL       time.Duration `env:"DEFAULT_CACHE_TTL" envDefault:"1m"`            // Время жизни элемента по умолчанию (0 — без истечения)
+	CacheTTLJitter        float64       `env:"CACHE_TTL_JITTER" envDefault:"0"`              // Доля случайного разброса TTL, например 0.1 — ±10% (0 — без разброса)
+	TTLOverrides          []string      `env:"CACHE_TTL_OVERRIDES" envSeparator:","`         // TTL по умолчанию для шаблонов ключей в виде шаблон=длительность, например session:*=30m
+	MaxTTL                time.Duration `env:"MAX_TTL" envDefault:"0s"`                      // Максимальное время жизни элемента; больший TTL уменьшается до него (0 — без ограничения)
+	MinTTL                time.Duration `env:"MIN_TTL" envDefault:"0s"`                      // Минимальное время жизни элемента; меньший положительный TTL увеличивается до него (0 — без ограничения)
+	LogLevel              string        `env:"LOG_LEVEL" envDefault:"WARN"`                  // Уровень логирования
+	LogAccessFormat       string        `env:"LOG_ACCESS_FORMAT" envDefault:"structured"`    // Формат журнала доступа: structured или combined
+	MaxConcurrentRequests int           `env:"MAX_CONCURRENT_REQUESTS" envDefault:"0"`       // Лимит одновременных запросов (0 — без ограничений)
+	ReadHeaderTimeout     time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"5s"`          // Таймаут чтения заголовков запроса
+	ReadTimeout           time.Duration `env:"READ_TIMEOUT" envDefault:"10s"`                // Таймаут чтения запроса
+	WriteTimeout          time.Duration `env:"WRITE_TIMEOUT" envDefault:"10s"`               // Таймаут записи ответа
+	IdleTimeout           time.Duration `env:"IDLE_TIMEOUT" envDefault:"1m"`                 // Таймаут простоя keep-alive соединения
+	ShutdownGrace         time.Duration `env:"SHUTDOWN_GRACE" envDefault:"10s"`              // Время ожидания активных запросов при остановке, после которого соединения закрываются (0 — сразу)
+	MaxResponseEntries    int           `env:"MAX_RESPONSE_ENTRIES" envDefault:"0"`          // Максимальное число элементов в ответе GET /api/lru (0 — без ограничений)
+	MaxWait               time.Duration `env:"MAX_WAIT" envDefault:"5s"`                     // Максимальное время ожидания ключа в GET /api/lru/{key}?wait (0 — ожидание отключено)
+	SlowRequestThreshold  time.Duration `env:"SLOW_REQUEST_THRESHOLD" envDefault:"0s"`       // Время обработки, начиная с которого запрос логируется как медленный (0 — отключено)
+	RawJSONValues         bool          `env:"RAW_JSON_VALUES" envDefault:"false"`           // Хранить и возвращать значения как исходный JSON без повторного кодирования
+	JSONNumbers           bool          `env:"JSON_NUMBERS" envDefault:"false"`              // Декодировать числа в значениях как json.Number, сохраняя точность целых чисел
+	VerbosePanics         bool          `env:"VERBOSE_PANICS" envDefault:"false"`            // Значение и стек паники в ответе 500 (только для разработки)
+	TracingEnabled        bool          `env:"TRACING_ENABLED" envDefault:"false"`           // Трассировка запросов OpenTelemetry с выводом спанов в stdout
+	CacheHeaders          bool          `env:"CACHE_HEADERS" envDefault:"false"`             // Заголовки X-Cache-Size и X-Cache-Capacity в ответах
+	H2C                   bool          `env:"H2C_ENABLED" envDefault:"false"`               // Поддержка HTTP/2 без TLS (h2c)
+	TenantHeader          string        `env:"TENANT_HEADER" envDefault:""`                  // Заголовок с идентификатором арендатора (пусто — без разделения)
+	TrustedProxies        []string      `env:"TRUSTED_PROXIES" envSeparator:","`             // Адреса и подсети доверенных прокси (CIDR)
 }
 
 // LoadConfig загружает конфигурацию из флагов, переменных окружения или значений по умолчанию.
@@ -21,30 +77,350 @@ type Config struct {
 // - Указатель на структуру Config с заполненными параметрами.
 // - Ошибку, если загрузка конфигурации завершилась неудачно.
 func LoadConfig() (*Config, error) {
-	hostPort := flag.String("server-host-port", "", "Server host and port (e.g., localhost:8080)")
-	cacheSize := flag.Int("cache-size", 0, "Cache size")
-	defaultTTL := flag.Duration("default-cache-ttl", 0, "Default cache TTL (e.g., 1m, 30s)")
-	logLevel := flag.String("log-level", "", "Log level (e.g., DEBUG, INFO, WARN)")
+	return loadConfig(flag.CommandLine, os.Args[1:])
+}
+
+// loadConfig определяет флаги в fs, разбирает args и загружает конфигурацию.
+//
+// Флаг переопределяет переменную окружения, только если он явно указан в args,
+// в том числе с нулевым значением (например, -cache-size=0 или -h2c=false).
+func loadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	hostPort := fs.String("server-host-port", "", "Server host and port (e.g., localhost:8080)")
+	adminHostPort := fs.String("admin-host-port", "", "Admin server host and port for /metrics, /healthz and /debug/pprof (empty serves /metrics and /healthz on the main port)")
+	cacheSize := fs.Int("cache-size", 0, "Cache size (0 means unbounded: entries are removed only by TTL)")
+	cacheSoftLimit := fs.Int("cache-soft-limit", 0, "Entry count below cache size that triggers early cleanup and a metric (0 disables it)")
+	evictExpiredFirst := fs.Bool("evict-expired-first", false, "On overflow, remove expired entries anywhere in the cache before evicting live LRU entries")
+	prefixQuota := fs.Int("cache-prefix-quota", 0, "Max entries per key prefix; overflow evicts that prefix's LRU entry (0 disables it)")
+	prefixSeparator := fs.String("cache-prefix-separator", "", "Separator ending the key prefix used by the prefix quota (e.g., :)")
+	caseInsensitiveKeys := fs.Bool("case-insensitive-keys", false, "Treat keys case-insensitively by lower-casing them in all operations")
+	fullnessAlerts := fs.String("cache-fullness-alerts", "", "Comma-separated fill percentages that log a warning once per crossing (e.g., 80,90,100)")
+	cacheMaxBytes := fs.Int64("cache-max-bytes", 0, "Cache byte budget (0 means unlimited)")
+	compressAbove := fs.Int("cache-compress-above", 0, "Compress string and binary values larger than this many bytes (0 disables compression)")
+	cleanupInterval := fs.Duration("cache-cleanup-interval", 0, "Background cleanup interval for expired entries (0 disables it)")
+	memoryCeiling := fs.Int64("memory-ceiling", 0, "Process heap ceiling in bytes; above it least recently used entries are evicted in an emergency (0 disables it)")
+	memoryCheckInterval := fs.Duration("memory-check-interval", 0, "How often the heap is checked against the memory ceiling (e.g., 1s)")
+	snapshotInterval := fs.Duration("snapshot-interval", 0, "Serve list and export from a snapshot refreshed at this interval; data may be this stale (0 disables it)")
+	preloadURL := fs.String("preload-url", "", "Base URL of a service instance to preload the cache from before listening (e.g., http://cache-0:8080)")
+	preloadTimeout := fs.Duration("preload-timeout", 0, "Max time to wait for the preload source to become ready before failing (e.g., 30s)")
+	defaultTTL := fs.Duration("default-cache-ttl", 0, "Default cache TTL (e.g., 1m, 30s)")
+	ttlJitter := fs.Float64("cache-ttl-jitter", 0, "Random TTL spread as a fraction (e.g., 0.1 for ±10%; 0 disables it)")
+	ttlOverrides := fs.String("cache-ttl-overrides", "", "Comma-separated pattern=duration default TTLs for keys written without a TTL (e.g., session:*=30m,static:*=0)")
+	maxTTL := fs.Duration("max-ttl", 0, "Max entry TTL; longer or non-expiring TTLs are clamped to it (0 disables the cap)")
+	minTTL := fs.Duration("min-ttl", 0, "Min entry TTL; shorter positive TTLs are raised to it (0 disables the floor)")
+	logLevel := fs.String("log-level", "", "Log level (e.g., DEBUG, INFO, WARN)")
+	logAccessFormat := fs.String("log-access-format", "", "Access log format: structured or combined (Apache Combined Log Format)")
+	maxConcurrent := fs.Int("max-concurrent-requests", 0, "Max concurrent requests (0 means unlimited)")
+	maxResponseEntries := fs.Int("max-response-entries", 0, "Max entries in an unpaged list response; extra entries are dropped and truncated is set (0 means unlimited)")
+	maxWait := fs.Duration("max-wait", 0, "Max long-poll wait for GET /api/lru/{key}?wait; must be below the write timeout (0 disables long polling)")
+	readHeaderTimeout := fs.Duration("read-header-timeout", 0, "Read header timeout (e.g., 5s)")
+	readTimeout := fs.Duration("read-timeout", 0, "Read timeout (e.g., 10s)")
+	writeTimeout := fs.Duration("write-timeout", 0, "Write timeout (e.g., 10s)")
+	idleTimeout := fs.Duration("idle-timeout", 0, "Idle keep-alive timeout (e.g., 1m)")
+	shutdownGrace := fs.Duration("shutdown-grace", 0, "Max time to wait for in-flight requests on shutdown before closing connections (e.g., 10s; 0 closes them at once)")
+	slowThreshold := fs.Duration("slow-request-threshold", 0, "Log requests slower than this at WARN (e.g., 500ms; 0 disables it)")
+	rawJSONValues := fs.Bool("raw-json-values", false, "Store values as raw JSON and return them verbatim, preserving key order and number formatting")
+	jsonNumbers := fs.Bool("json-numbers", false, "Decode numbers in values as json.Number so large integers round-trip without precision loss")
+	verbosePanics := fs.Bool("verbose-panics", false, "Include panic value and stack trace in 500 responses (development only)")
+	tracingEnabled := fs.Bool("tracing", false, "Trace requests with OpenTelemetry and write spans to stdout")
+	cacheHeaders := fs.Bool("cache-headers", false, "Add X-Cache-Size and X-Cache-Capacity response headers")
+	h2cEnabled := fs.Bool("h2c", false, "Accept cleartext HTTP/2 (h2c) connections")
+	tenantHeader := fs.String("tenant-header", "", "Header with tenant ID used to namespace keys (e.g., X-Tenant-ID)")
+	trustedProxies := fs.String("trusted-proxies", "", "Comma-separated trusted proxy IPs or CIDRs (e.g., 10.0.0.0/8,127.0.0.1)")
 
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{}
 	if err := env.Parse(cfg); err != nil {
 		return nil, err
 	}
 
-	if *hostPort != "" {
-		cfg.ServerHostPort = *hostPort
+	var visitErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server-host-port":
+			cfg.ServerHostPort = *hostPort
+		case "admin-host-port":
+			cfg.AdminHostPort = *adminHostPort
+		case "cache-size":
+			cfg.CacheSize = *cacheSize
+		case "cache-soft-limit":
+			cfg.CacheSoftLimit = *cacheSoftLimit
+		case "evict-expired-first":
+			cfg.EvictExpiredFirst = *evictExpiredFirst
+		case "cache-prefix-quota":
+			cfg.CachePrefixQuota = *prefixQuota
+		case "cache-prefix-separator":
+			cfg.CachePrefixSeparator = *prefixSeparator
+		case "case-insensitive-keys":
+			cfg.CaseInsensitiveKeys = *caseInsensitiveKeys
+		case "cache-fullness-alerts":
+			cfg.CacheFullness = nil
+			for _, raw := range strings.Split(*fullnessAlerts, ",") {
+				if raw = strings.TrimSpace(raw); raw == "" {
+					continue
+				}
+				percent, err := strconv.Atoi(raw)
+				if err != nil {
+					visitErr = fmt.Errorf("invalid cache fullness threshold %q: %w", raw, err)
+					return
+				}
+				cfg.CacheFullness = append(cfg.CacheFullness, percent)
+			}
+		case "cache-max-bytes":
+			cfg.CacheMaxBytes = *cacheMaxBytes
+		case "cache-compress-above":
+			cfg.CacheCompressAbove = *compressAbove
+		case "cache-cleanup-interval":
+			cfg.CacheCleanupInterval = *cleanupInterval
+		case "memory-ceiling":
+			cfg.MemoryCeiling = *memoryCeiling
+		case "memory-check-interval":
+			cfg.MemoryCheckInterval = *memoryCheckInterval
+		case "snapshot-interval":
+			cfg.SnapshotInterval = *snapshotInterval
+		case "preload-url":
+			cfg.PreloadURL = *preloadURL
+		case "preload-timeout":
+			cfg.PreloadTimeout = *preloadTimeout
+		case "default-cache-ttl":
+			cfg.DefaultCacheTTL = *defaultTTL
+		case "cache-ttl-jitter":
+			cfg.CacheTTLJitter = *ttlJitter
+		case "cache-ttl-overrides":
+			cfg.TTLOverrides = nil
+			if *ttlOverrides != "" {
+				cfg.TTLOverrides = strings.Split(*ttlOverrides, ",")
+			}
+		case "max-ttl":
+			cfg.MaxTTL = *maxTTL
+		case "min-ttl":
+			cfg.MinTTL = *minTTL
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "log-access-format":
+			cfg.LogAccessFormat = *logAccessFormat
+		case "max-concurrent-requests":
+			cfg.MaxConcurrentRequests = *maxConcurrent
+		case "max-response-entries":
+			cfg.MaxResponseEntries = *maxResponseEntries
+		case "max-wait":
+			cfg.MaxWait = *maxWait
+		case "read-header-timeout":
+			cfg.ReadHeaderTimeout = *readHeaderTimeout
+		case "read-timeout":
+			cfg.ReadTimeout = *readTimeout
+		case "write-timeout":
+			cfg.WriteTimeout = *writeTimeout
+		case "idle-timeout":
+			cfg.IdleTimeout = *idleTimeout
+		case "shutdown-grace":
+			cfg.ShutdownGrace = *shutdownGrace
+		case "slow-request-threshold":
+			cfg.SlowRequestThreshold = *slowThreshold
+		case "raw-json-values":
+			cfg.RawJSONValues = *rawJSONValues
+		case "json-numbers":
+			cfg.JSONNumbers = *jsonNumbers
+		case "verbose-panics":
+			cfg.VerbosePanics = *verbosePanics
+		case "tracing":
+			cfg.TracingEnabled = *tracingEnabled
+		case "cache-headers":
+			cfg.CacheHeaders = *cacheHeaders
+		case "h2c":
+			cfg.H2C = *h2cEnabled
+		case "tenant-header":
+			cfg.TenantHeader = *tenantHeader
+		case "trusted-proxies":
+			cfg.TrustedProxies = nil
+			if *trustedProxies != "" {
+				cfg.TrustedProxies = strings.Split(*trustedProxies, ",")
+			}
+		}
+	})
+	if visitErr != nil {
+		return nil, visitErr
+	}
+
+	return cfg, nil
+}
+
+// Validate проверяет корректность параметров конфигурации.
+//
+// Возвращает:
+// - Ошибку с описанием первого некорректного параметра или nil.
+func (c *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(c.ServerHostPort); err != nil {
+		return fmt.Errorf("invalid server host port %q: %w", c.ServerHostPort, err)
+	}
+	if c.AdminHostPort != "" {
+		if _, _, err := net.SplitHostPort(c.AdminHostPort); err != nil {
+			return fmt.Errorf("invalid admin host port %q: %w", c.AdminHostPort, err)
+		}
+		if c.AdminHostPort == c.ServerHostPort {
+			return fmt.Errorf("admin host port must differ from server host port %q", c.ServerHostPort)
+		}
+	}
+	if c.CacheSize < 0 {
+		return fmt.Errorf("cache size cannot be negative, got %d", c.CacheSize)
+	}
+	if c.CacheSoftLimit < 0 {
+		return fmt.Errorf("cache soft limit cannot be negative, got %d", c.CacheSoftLimit)
+	}
+	if c.CacheSize > 0 && c.CacheSoftLimit >= c.CacheSize {
+		return fmt.Errorf("cache soft limit must be in [0, %d), got %d", c.CacheSize, c.CacheSoftLimit)
+	}
+	if c.CachePrefixQuota < 0 {
+		return fmt.Errorf("cache prefix quota cannot be negative, got %d", c.CachePrefixQuota)
+	}
+	if c.CachePrefixQuota > 0 && c.CachePrefixSeparator == "" {
+		return fmt.Errorf("cache prefix separator is required when prefix quota is %d", c.CachePrefixQuota)
+	}
+	for _, percent := range c.CacheFullness {
+		if percent <= 0 || percent > 100 {
+			return fmt.Errorf("cache fullness threshold must be in (0, 100], got %d", percent)
+		}
+	}
+	if c.CacheMaxBytes < 0 {
+		return fmt.Errorf("cache max bytes cannot be negative, got %d", c.CacheMaxBytes)
+	}
+	if c.CacheCompressAbove < 0 {
+		return fmt.Errorf("cache compression threshold cannot be negative, got %d", c.CacheCompressAbove)
+	}
+	if c.CacheCleanupInterval < 0 {
+		return fmt.Errorf("cache cleanup interval cannot be negative, got %s", c.CacheCleanupInterval)
+	}
+	if c.MemoryCeiling < 0 {
+		return fmt.Errorf("memory ceiling cannot be negative, got %d", c.MemoryCeiling)
 	}
-	if *cacheSize != 0 {
-		cfg.CacheSize = *cacheSize
+	if c.MemoryCeiling > 0 && c.MemoryCheckInterval <= 0 {
+		return fmt.Errorf("memory check interval must be positive when memory ceiling is set, got %s", c.MemoryCheckInterval)
 	}
-	if *defaultTTL != 0 {
-		cfg.DefaultCacheTTL = *defaultTTL
+	if c.SnapshotInterval < 0 {
+		return fmt.Errorf("snapshot interval cannot be negative, got %s", c.SnapshotInterval)
 	}
-	if *logLevel != "" {
-		cfg.LogLevel = *logLevel
+	if c.PreloadURL != "" {
+		if u, err := url.Parse(c.PreloadURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid preload url %q", c.PreloadURL)
+		}
+		if c.PreloadTimeout <= 0 {
+			return fmt.Errorf("preload timeout must be positive, got %s", c.PreloadTimeout)
+		}
 	}
+	if c.DefaultCacheTTL < 0 {
+		return fmt.Errorf("default cache ttl cannot be negative, got %s", c.DefaultCacheTTL)
+	}
+	if c.CacheTTLJitter < 0 || c.CacheTTLJitter >= 1 {
+		return fmt.Errorf("cache ttl jitter must be in [0, 1), got %g", c.CacheTTLJitter)
+	}
+	if _, err := c.TTLOverrideRules(); err != nil {
+		return err
+	}
+	if c.MaxTTL < 0 {
+		return fmt.Errorf("max ttl cannot be negative, got %s", c.MaxTTL)
+	}
+	if c.MinTTL < 0 {
+		return fmt.Errorf("min ttl cannot be negative, got %s", c.MinTTL)
+	}
+	if c.MaxTTL > 0 && c.MinTTL > c.MaxTTL {
+		return fmt.Errorf("min ttl %s cannot exceed max ttl %s", c.MinTTL, c.MaxTTL)
+	}
+	switch c.LogLevel {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		return fmt.Errorf("unknown log level %q", c.LogLevel)
+	}
+	switch c.LogAccessFormat {
+	case "", AccessLogStructured, AccessLogCombined:
+	default:
+		return fmt.Errorf("unknown access log format %q", c.LogAccessFormat)
+	}
+	if c.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("max concurrent requests cannot be negative, got %d", c.MaxConcurrentRequests)
+	}
+	if c.MaxResponseEntries < 0 {
+		return fmt.Errorf("max response entries cannot be negative, got %d", c.MaxResponseEntries)
+	}
+	if c.ReadHeaderTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		return fmt.Errorf("server timeouts cannot be negative")
+	}
+	if c.ShutdownGrace < 0 {
+		return fmt.Errorf("shutdown grace cannot be negative, got %s", c.ShutdownGrace)
+	}
+	if c.MaxWait < 0 {
+		return fmt.Errorf("max wait cannot be negative, got %s", c.MaxWait)
+	}
+	if c.WriteTimeout > 0 && c.MaxWait >= c.WriteTimeout {
+		return fmt.Errorf("max wait %s must be below write timeout %s", c.MaxWait, c.WriteTimeout)
+	}
+	if c.SlowRequestThreshold < 0 {
+		return fmt.Errorf("slow request threshold cannot be negative, got %s", c.SlowRequestThreshold)
+	}
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		return err
+	}
+	return nil
+}
 
-	return cfg, nil
+// TTLOverrideRules разбирает TTL по умолчанию для шаблонов ключей в формате
+// шаблон=длительность (например, session:*=30m). Порядок правил сохраняется:
+// применяется первый подходящий шаблон.
+//
+// Возвращает:
+// - Список правил в порядке указания.
+// - Ошибку, если правило задано некорректно.
+func (c *Config) TTLOverrideRules() ([]TTLOverride, error) {
+	var overrides []TTLOverride
+	for _, raw := range c.TTLOverrides {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		i := strings.LastIndex(raw, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid ttl override %q: expected pattern=duration", raw)
+		}
+		ttl, err := time.ParseDuration(raw[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl override %q: %w", raw, err)
+		}
+		if ttl < 0 {
+			return nil, fmt.Errorf("invalid ttl override %q: ttl cannot be negative", raw)
+		}
+		overrides = append(overrides, TTLOverride{Pattern: raw[:i], TTL: ttl})
+	}
+	return overrides, nil
+}
+
+// TrustedProxyPrefixes разбирает адреса и подсети доверенных прокси.
+// Отдельный IP-адрес преобразуется в подсеть из одного адреса.
+//
+// Возвращает:
+// - Список подсетей доверенных прокси.
+// - Ошибку, если адрес или подсеть заданы некорректно.
+func (c *Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, raw := range c.TrustedProxies {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if strings.Contains(raw, "/") {
+			prefix, err := netip.ParsePrefix(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
 }
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"flag"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -18,3 +20,199 @@ func TestLoadConfig(t *testing.T) {
 		t.Errorf("expected cache size 50, got %v", cfg.CacheSize)
 	}
 }
+
+func TestLoadConfig_FlagPrecedence(t *testing.T) {
+	t.Setenv("CACHE_SIZE", "50")
+	t.Setenv("CACHE_MAX_BYTES", "1024")
+	t.Setenv("CACHE_HEADERS", "true")
+	t.Setenv("TENANT_HEADER", "X-Tenant-ID")
+
+	// Явно указанные флаги с нулевыми значениями переопределяют переменные окружения
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, []string{"-cache-size=0", "-cache-max-bytes=0", "-cache-headers=false", "-tenant-header="})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CacheSize != 0 || cfg.CacheMaxBytes != 0 || cfg.CacheHeaders || cfg.TenantHeader != "" {
+		t.Errorf("expected explicit flags to override env, got %+v", cfg)
+	}
+
+	// Неуказанные флаги не затрагивают переменные окружения
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err = loadConfig(fs, []string{"-log-level=DEBUG"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CacheSize != 50 || cfg.CacheMaxBytes != 1024 || !cfg.CacheHeaders || cfg.TenantHeader != "X-Tenant-ID" {
+		t.Errorf("expected env values to be kept, got %+v", cfg)
+	}
+	if cfg.LogLevel != "DEBUG" {
+		t.Errorf("expected log level from flag, got %s", cfg.LogLevel)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := &Config{
+		ServerHostPort:  "localhost:8080",
+		CacheSize:       10,
+		DefaultCacheTTL: time.Minute,
+		LogLevel:        "WARN",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := *cfg
+	invalid.CacheSize = -1
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for negative cache size")
+	}
+
+	// Нулевой размер означает неограниченный кэш
+	unbounded := *cfg
+	unbounded.CacheSize = 0
+	if err := unbounded.Validate(); err != nil {
+		t.Errorf("unexpected error for unbounded cache: %v", err)
+	}
+
+	invalid = *cfg
+	invalid.ServerHostPort = "localhost"
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for address without port")
+	}
+
+	invalid = *cfg
+	invalid.AdminHostPort = cfg.ServerHostPort
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for admin address equal to server address")
+	}
+
+	invalid = *cfg
+	invalid.LogLevel = "TRACE"
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for unknown log level")
+	}
+
+	invalid = *cfg
+	invalid.LogAccessFormat = "json"
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for unknown access log format")
+	}
+
+	invalid = *cfg
+	invalid.CacheSoftLimit = 10
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for soft limit not below cache size")
+	}
+
+	invalid = *cfg
+	invalid.CachePrefixQuota = 5
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for prefix quota without separator")
+	}
+
+	invalid = *cfg
+	invalid.ShutdownGrace = -time.Second
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for negative shutdown grace")
+	}
+
+	invalid = *cfg
+	invalid.MemoryCeiling = -1
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for negative memory ceiling")
+	}
+
+	invalid = *cfg
+	invalid.MemoryCeiling = 1 << 30
+	invalid.MemoryCheckInterval = 0
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for memory ceiling without check interval")
+	}
+
+	invalid = *cfg
+	invalid.CacheFullness = []int{80, 120}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for fullness threshold above 100")
+	}
+
+	invalid = *cfg
+	invalid.CacheTTLJitter = 1
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for ttl jitter out of range")
+	}
+
+	invalid = *cfg
+	invalid.MaxTTL = -time.Second
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for negative max ttl")
+	}
+
+	invalid = *cfg
+	invalid.MinTTL = time.Hour
+	invalid.MaxTTL = time.Minute
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for min ttl above max ttl")
+	}
+
+	invalid = *cfg
+	invalid.WriteTimeout = 10 * time.Second
+	invalid.MaxWait = 10 * time.Second
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for max wait not below write timeout")
+	}
+
+	invalid = *cfg
+	invalid.PreloadURL = "cache-0:8080"
+	invalid.PreloadTimeout = time.Second
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for preload url without scheme")
+	}
+
+	invalid = *cfg
+	invalid.PreloadURL = "http://cache-0:8080"
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for zero preload timeout")
+	}
+
+	invalid = *cfg
+	invalid.TTLOverrides = []string{"session:*=30m", "static:*"}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for ttl override without duration")
+	}
+
+	invalid = *cfg
+	invalid.MaxResponseEntries = -1
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for negative max response entries")
+	}
+
+	invalid = *cfg
+	invalid.TrustedProxies = []string{"10.0.0.0/8", "not-an-ip"}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for invalid trusted proxy")
+	}
+}
+
+func TestConfig_TTLOverrideRules(t *testing.T) {
+	cfg := &Config{TTLOverrides: []string{"session:*=30m", " static:*=0s "}}
+	overrides, err := cfg.TTLOverrideRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []TTLOverride{{Pattern: "session:*", TTL: 30 * time.Minute}, {Pattern: "static:*", TTL: 0}}
+	if len(overrides) != len(expected) || overrides[0] != expected[0] || overrides[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, overrides)
+	}
+}
+
+func TestConfig_TrustedProxyPrefixes(t *testing.T) {
+	cfg := &Config{TrustedProxies: []string{"10.0.0.0/8", " 192.168.1.10 "}}
+	prefixes, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prefixes) != 2 || prefixes[0].String() != "10.0.0.0/8" || prefixes[1].String() != "192.168.1.10/32" {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+}
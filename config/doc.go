@@ -1,13 +1,43 @@
 // Package config отвечает за загрузку конфигурации приложения.
 //
 // Поддерживаемые параметры:
-// - Флаги командной строки.
+// - Флаги командной строки (явно указанный флаг переопределяет переменную окружения, даже с нулевым значением).
 // - Переменные окружения (с приоритетом флагов).
 // - Значения по умолчанию.
 //
 // Параметры включают:
 // - Адрес и порт сервера.
-// - Размер кэша.
-// - TTL для элементов.
+// - Адрес и порт служебного сервера (метрики, проверка работоспособности, профилирование).
+// - Размер кэша (0 — неограниченный кэш с удалением элементов только по TTL).
+// - Мягкий лимит числа элементов.
+// - Удаление истёкших элементов перед вытеснением по ёмкости.
+// - Квота числа элементов на префикс ключа.
+// - Регистронезависимые ключи.
+// - Пороги заполненности кэша для предупреждений.
+// - Бюджет памяти кэша в байтах.
+// - Интервал фоновой очистки истёкших элементов.
+// - Предел объёма кучи процесса с экстренным вытеснением элементов и интервал его проверки.
+// - Предварительная загрузка кэша из другого экземпляра сервиса при запуске.
+// - Интервал обновления снимка для чтения списка и экспорта без блокировки кэша.
+// - Порог размера значения для сжатия.
+// - TTL для элементов (0 — элементы без явного TTL не истекают).
+// - Случайный разброс TTL элементов.
+// - TTL по умолчанию для шаблонов ключей.
+// - Максимальное и минимальное время жизни элементов.
 // - Уровень логирования.
+// - Формат журнала доступа (структурированный или Apache Combined Log Format).
+// - Максимальное число одновременно обрабатываемых запросов.
+// - Максимальное число элементов в ответе со списком элементов.
+// - Максимальное время длительного ожидания ключа (long polling).
+// - Таймауты HTTP-сервера (чтение заголовков, чтение, запись, простой).
+// - Время ожидания активных запросов при остановке сервера.
+// - Порог времени обработки для логирования медленных запросов.
+// - Хранение значений в виде исходного JSON.
+// - Декодирование чисел в значениях без потери точности (json.Number).
+// - Подробности паники в ответах 500 (для разработки).
+// - Трассировка запросов OpenTelemetry.
+// - Заголовки с заполненностью кэша в ответах.
+// - Поддержка HTTP/2 без TLS (h2c).
+// - Заголовок для разделения ключей по арендаторам.
+// - Доверенные прокси для определения адреса клиента.
 package config
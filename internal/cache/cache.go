@@ -2,202 +2,1597 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Ошибки, которые могут возникнуть при работе с кешем
 var (
-	errEmptyKey    = errors.New("key cannot be empty")    // Ошибка для пустого ключа
-	errNegativeTTL = errors.New("ttl cannot be negative") // Ошибка для отрицательного TTL
-	errKeyNotFound = errors.New("key not found")          // Ошибка для отсутствующего ключа
-	errExpiredKey  = errors.New("key expired")            // Ошибка для истекшего ключа
-	errNilNode     = errors.New("node is nil")            // Ошибка для пустого узла
-	errEmptyCache  = errors.New("cache is empty")         // Ошибка для пустого кеша
+	errEmptyKey     = errors.New("key cannot be empty")                     // Ошибка для пустого ключа
+	errNegativeTTL  = errors.New("ttl cannot be negative")                  // Ошибка для отрицательного TTL
+	errKeyNotFound  = errors.New("key not found")                           // Ошибка для отсутствующего ключа
+	errExpiredKey   = errors.New("key expired")                             // Ошибка для истекшего ключа
+	errNilNode      = errors.New("node is nil")                             // Ошибка для пустого узла
+	errTooLarge     = errors.New("value exceeds cache byte budget")         // Ошибка для элемента больше бюджета памяти
+	errNegativeCost = errors.New("cost cannot be negative")                 // Ошибка для отрицательной стоимости элемента
+	errCostTooLarge = errors.New("cost exceeds cache capacity")             // Ошибка для элемента дороже ёмкости кеша
+	errLoaderGoexit = errors.New("cache: loader exited via runtime.Goexit") // Ошибка ожидающих, если загрузчик завершил горутину
+
+	// ErrNegativeCached возвращается при чтении ключа, для которого закешировано отсутствие значения.
+	ErrNegativeCached = errors.New("key is negatively cached")
+	// ErrClosed возвращается при обращении к кешу после вызова Close.
+	ErrClosed = errors.New("cache is closed")
+	// ErrNilValue возвращается при записи значения nil: такое значение неотличимо
+	// от отсутствия значения, поэтому для него предназначена PutNegative.
+	ErrNilValue = errors.New("value cannot be nil")
+	// ErrPreconditionFailed возвращается, если не выполнено условие операции (например, версия элемента).
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrKeyExists возвращается записью с PutOptions.IfAbsent, если актуальный элемент уже существует.
+	ErrKeyExists = errors.New("key already exists")
+	// ErrEmptyCache возвращается операциями над всем кешем, если в нём нет элементов.
+	ErrEmptyCache = errors.New("cache is empty")
 )
 
-// Node представляет собой элемент в кеше, содержащий ключ, значение, время жизни (TTL),
-// а также ссылки на предыдущий и следующий элементы в двусвязном списке.
-type Node struct {
-	key   string      // Ключ элемента в кеше
-	value interface{} // Значение элемента
-	TTL   time.Time   // Время истечения срока жизни элемента
-	prev  *Node       // Указатель на предыдущий элемент в списке
-	next  *Node       // Указатель на следующий элемент в списке
+// IsNotFound сообщает, означает ли err отсутствие элемента: ключ не найден, его TTL
+// истёк или для него закешировано отсутствие значения (ErrNegativeCached).
+func IsNotFound(err error) bool {
+	return errors.Is(err, errKeyNotFound) || errors.Is(err, errExpiredKey) || errors.Is(err, ErrNegativeCached)
+}
+
+// Node представляет собой элемент в кеше, содержащий ключ, значение, время жизни (TTL),
+// а также ссылки на предыдущий и следующий элементы в двусвязном списке.
+type Node struct {
+	key        string        // Ключ элемента в кеше; разделяет байты с ключом карты cache
+	value      interface{}   // Значение элемента
+	TTL        time.Time     // Время истечения срока жизни элемента (нулевое — бессрочный элемент)
+	dirty      bool          // Признак несохранённых изменений (write-back)
+	negative   bool          // Признак отрицательной записи (отсутствие значения)
+	size       int64         // Оценка размера элемента в байтах
+	cost       int64         // Стоимость элемента в ёмкости кеша (см. PutOptions.Cost)
+	version    uint64        // Версия элемента, увеличивается при каждой записи
+	modifiedAt time.Time     // Время последней записи элемента
+	promotedAt time.Time     // Время последнего перемещения элемента в начало списка
+	accessedAt atomic.Int64  // Время последнего чтения или записи элемента (Unix, наносекунды)
+	protected  bool          // Элемент находится в защищённом сегменте (см. WithSegmentedLRU)
+	compressed bool          // Значение хранится в сжатом виде (см. WithCompression)
+	sliding    bool          // TTL продлевается на lifetime при каждом успешном чтении
+	lifetime   time.Duration // Исходное время жизни элемента, заданное при записи
+	tags       Tags          // Метаданные элемента (nil — без метаданных)
+	prev       *Node         // Указатель на предыдущий элемент в списке
+	next       *Node         // Указатель на следующий элемент в списке
+}
+
+// LRUCache представляет собой структуру кеша с алгоритмом LRU, поддерживающего TTL для элементов.
+type LRUCache struct {
+	head              *Node                      // Указатель на первый элемент в списке (испытательный сегмент в режиме SLRU)
+	tail              *Node                      // Указатель на последний элемент в списке
+	protectedHead     *Node                      // Указатель на первый элемент защищённого сегмента
+	protectedTail     *Node                      // Указатель на последний элемент защищённого сегмента
+	protectedLen      int                        // Число элементов в защищённом сегменте
+	protectedCap      int                        // Ёмкость защищённого сегмента (0 — обычный LRU)
+	cache             map[string]*Node           // Карта для хранения элементов кеша по ключу
+	capacity          int                        // Ёмкость кеша: максимальная суммарная стоимость элементов
+	usedCost          int64                      // Суммарная стоимость элементов (при стоимости 1 — их число)
+	defaultTTL        time.Duration              // Значение по умолчанию для TTL
+	onEvict           EvictFunc                  // Обработчик вытеснения «грязных» элементов
+	evictRate         float64                    // Максимальная частота вызовов onEvict в секунду (0 — без ограничения)
+	evictBurst        int                        // Допустимый всплеск вызовов onEvict сверх частоты
+	evictQueueSize    int                        // Размер очереди элементов, ожидающих вызова onEvict
+	evictQueue        chan evictedEntry          // Очередь элементов для onEvict при ограничении частоты
+	evictMutex        sync.RWMutex               // Упорядочивает постановку в evictQueue относительно Close
+	negativeTTL       time.Duration              // TTL по умолчанию для отрицательных записей
+	promoteInterval   time.Duration              // Минимальный интервал между перемещениями элемента при Get
+	promotions        chan *Node                 // Отложенные перемещения узлов в начало списка после Get
+	maxBytes          int64                      // Бюджет памяти в байтах (0 — без ограничения)
+	usedBytes         int64                      // Суммарный оценочный размер элементов в байтах
+	evictionStats     EvictionStats              // Статистика вытеснения по ёмкости
+	version           uint64                     // Счётчик версий, увеличивается при каждой записи и удалении
+	tombstones        []tombstone                // Записи об удалённых ключах в порядке версий (см. Changes)
+	changesHorizon    uint64                     // Наибольшая версия забытого удаления; изменения до неё недоступны
+	mutex             sync.RWMutex               // Мьютекс для безопасного доступа к кешу
+	calls             map[string]*call           // Выполняющиеся вызовы загрузчика в GetOrCompute
+	callsMutex        sync.Mutex                 // Мьютекс для доступа к calls
+	waiters           map[string][]chan struct{} // Ожидающие появления ключа вызовы WaitGet
+	waitersMutex      sync.Mutex                 // Мьютекс для доступа к waiters
+	cleanupInterval   time.Duration              // Интервал фоновой очистки истёкших элементов (0 — отключена)
+	snapshotInterval  time.Duration              // Интервал обновления снимка для чтения (0 — режим отключён)
+	snapshot          atomic.Pointer[Snapshot]   // Последний снимок элементов (nil — режим отключён)
+	memoryCeiling     uint64                     // Предельный объём кучи процесса в байтах (0 — без ограничения)
+	memoryInterval    time.Duration              // Интервал проверки объёма кучи при включённом пределе
+	heapUsage         func() uint64              // Источник текущего объёма кучи (по умолчанию runtime.MemStats)
+	compressThreshold int                        // Размер значения, начиная с которого оно сжимается (0 — без сжатия)
+	ttlJitter         float64                    // Доля случайного разброса TTL (0 — без разброса)
+	maxTTL            time.Duration              // Максимальное время жизни элемента (0 — без ограничения)
+	minTTL            time.Duration              // Минимальное время жизни элемента с ненулевым TTL (0 — без ограничения)
+	ttlOverrides      []ttlOverride              // TTL по умолчанию для шаблонов ключей (см. WithTTLOverride)
+	softLimit         int                        // Мягкий лимит числа элементов (0 — отключён)
+	expiredFirst      bool                       // При переполнении сначала удаляются истёкшие элементы (см. WithExpiredFirst)
+	staleWindow       time.Duration              // Время хранения истёкших элементов для чтения устаревших значений (см. WithStaleWindow)
+	lookups           lookupWindow               // Попадания и промахи чтения за скользящее окно (см. HitRatio)
+	now               func() time.Time           // Источник текущего времени для TTL (по умолчанию time.Now)
+	prefixSeparator   string                     // Разделитель, завершающий префикс пространства ключей
+	prefixQuota       int                        // Максимальное число элементов с одним префиксом (0 — без квоты)
+	prefixCounts      map[string]int             // Число элементов по префиксам при включённой квоте
+	log               *slog.Logger               // Логгер вытеснений и удалений элементов (nil — без логирования)
+	lastEvictionWarn  time.Time                  // Время последнего предупреждения о вытеснении по ёмкости
+	suppressedWarns   int                        // Число вытеснений, не залогированных с последнего предупреждения
+	caseInsensitive   bool                       // Ключи приводятся к нижнему регистру (см. WithCaseInsensitiveKeys)
+	fullness          []int                      // Пороги заполненности в процентах от ёмкости по возрастанию
+	fullnessReported  int                        // Число нижних порогов, о пересечении которых уже сообщено
+	fullnessCrossings []uint64                   // Число пересечений каждого порога заполненности
+	closed            atomic.Bool                // Признак закрытого кеша
+	closeOnce         sync.Once                  // Гарантирует однократное закрытие
+	done              chan struct{}              // Закрывается при вызове Close для остановки фоновых горутин
+	background        sync.WaitGroup             // Выполняющиеся фоновые горутины
+}
+
+// Item описывает элемент кеша.
+type Item struct {
+	Key        string      // Ключ элемента
+	Value      interface{} // Значение элемента
+	ExpiresAt  time.Time   // Время истечения срока жизни элемента
+	Version    uint64      // Версия элемента, меняется при каждой записи
+	ModifiedAt time.Time   // Время последней записи элемента
+	TTLClamped bool        // TTL записи был уменьшен до максимального (см. WithMaxTTL)
+	Tags       Tags        // Метаданные элемента; не изменяются после записи и не должны изменяться получателем
+}
+
+// Tags — произвольные строковые метаданные элемента (например, подсказки Cache-Control
+// или источник значения). Теги хранятся рядом со значением, не влияют на него и
+// заменяются вместе со значением при каждой записи.
+type Tags map[string]string
+
+// promotionBufferSize — число отложенных перемещений узлов, накапливаемых до применения.
+const promotionBufferSize = 64
+
+// evictionWarnInterval — минимальный интервал между предупреждениями о вытеснении по ёмкости.
+const evictionWarnInterval = 10 * time.Second
+
+// fullnessHysteresis — на сколько процентных пунктов заполненность должна опуститься ниже
+// порога, чтобы его повторное пересечение снова считалось событием.
+const fullnessHysteresis = 5
+
+// LoaderFunc загружает значение элемента при отсутствии его в кеше.
+type LoaderFunc func(ctx context.Context) (interface{}, error)
+
+// call описывает выполняющийся вызов загрузчика для ключа.
+type call struct {
+	done     chan struct{} // Закрывается по завершении загрузки
+	value    interface{}   // Загруженное значение
+	err      error         // Ошибка загрузки или записи в кеш
+	panicked *loaderPanic  // Паника загрузчика (nil — загрузчик завершился без паники)
+}
+
+// loaderPanic передаёт ожидающим вызовам GetOrCompute панику загрузчика вместе
+// со стеком вызовов, на котором она произошла.
+type loaderPanic struct {
+	value interface{} // Значение паники
+	stack []byte      // Стек вызовов загрузчика в момент паники
+}
+
+func (p *loaderPanic) Error() string {
+	return fmt.Sprintf("cache: loader panicked: %v\n\n%s", p.value, p.stack)
+}
+
+// ttlOverride задаёт TTL по умолчанию для ключей, соответствующих шаблону.
+type ttlOverride struct {
+	pattern string        // Шаблон ключа; «*» соответствует любой последовательности символов
+	ttl     time.Duration // TTL для ключей, записанных без явного TTL
+}
+
+// EvictFunc вызывается при вытеснении элемента из кеша.
+type EvictFunc func(key string, value interface{})
+
+// EvictReason описывает причину удаления элемента из кеша.
+type EvictReason string
+
+// Причины удаления элемента из кеша.
+const (
+	EvictReasonDeleted  EvictReason = "deleted"  // Явное удаление (Evict, Pop)
+	EvictReasonExpired  EvictReason = "expired"  // Истечение TTL
+	EvictReasonCapacity EvictReason = "capacity" // Вытеснение по ёмкости, бюджету памяти или квоте префикса
+)
+
+// Option задаёт дополнительный параметр LRU-кеша.
+type Option func(*LRUCache)
+
+// WithOnEvict задаёт обработчик, вызываемый при вытеснении «грязных» элементов
+// по ёмкости или по истечении TTL. Обработчик вызывается синхронно после освобождения
+// мьютекса, поэтому может обращаться к кешу, а с WithEvictionRateLimit — асинхронно
+// с ограничением частоты. Для «чистых» элементов обработчик не вызывается.
+func WithOnEvict(fn EvictFunc) Option {
+	return func(c *LRUCache) {
+		c.onEvict = fn
+	}
+}
+
+// WithPromotionThrottle задаёт минимальный интервал между перемещениями элемента
+// в начало списка при чтении. Если элемент перемещался позднее, чем interval назад,
+// Get не изменяет порядок списка и выполняется под блокировкой на чтение.
+// Это снижает конкуренцию за мьютекс при интенсивном чтении ценой точности LRU.
+// Значение 0 (по умолчанию) означает перемещение при каждом чтении.
+func WithPromotionThrottle(interval time.Duration) Option {
+	return func(c *LRUCache) {
+		c.promoteInterval = interval
+	}
+}
+
+// WithNegativeTTL задаёт TTL по умолчанию для отрицательных записей, добавляемых PutNegative.
+// Обычно он короче TTL значений. Если не задан, используется TTL по умолчанию кеша.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(c *LRUCache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithMaxBytes задаёт бюджет памяти кеша в байтах. Размер элемента оценивается как сумма
+// длины ключа и размера значения (для составных значений — длины их JSON-представления).
+// При превышении бюджета вытесняются давно использованные элементы; элемент, размер
+// которого превышает весь бюджет, отклоняется. Значение 0 отключает ограничение.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(c *LRUCache) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithCleanupInterval включает фоновую очистку: раз в interval из кеша удаляются
+// истёкшие элементы, даже если к ним никто не обращается. Горутина очистки
+// останавливается методом Close. Значение 0 (по умолчанию) отключает очистку.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(c *LRUCache) {
+		c.cleanupInterval = interval
+	}
+}
+
+// WithTTLJitter включает случайный разброс времени жизни элементов на ±fraction
+// (например, 0.1 — ±10%), чтобы элементы, записанные с одинаковым TTL, истекали
+// не одновременно и не вызывали волну промахов. Значение вне интервала [0, 1)
+// отключает разброс, как и 0 (по умолчанию).
+func WithTTLJitter(fraction float64) Option {
+	return func(c *LRUCache) {
+		if fraction < 0 || fraction >= 1 {
+			fraction = 0
+		}
+		c.ttlJitter = fraction
+	}
+}
+
+// WithMaxTTL ограничивает время жизни элементов сверху: запрошенный TTL или TTL по умолчанию,
+// превышающий maxTTL, заменяется на maxTTL, а элементы без срока жизни истекают через maxTTL.
+// Так ни один клиент не может закрепить элемент в кеше навсегда. Ограничение применяется
+// и после разброса WithTTLJitter. Значение 0 (по умолчанию) отключает ограничение.
+func WithMaxTTL(maxTTL time.Duration) Option {
+	return func(c *LRUCache) {
+		if maxTTL < 0 {
+			maxTTL = 0
+		}
+		c.maxTTL = maxTTL
+	}
+}
+
+// WithTTLOverride задаёт TTL по умолчанию для ключей, соответствующих шаблону pattern,
+// в котором «*» обозначает любую, в том числе пустую, последовательность символов
+// (например, "session:*"). TTL применяется вместо TTL по умолчанию кеша к элементам,
+// записанным без явного TTL; 0 означает бессрочный элемент. Параметр можно указать
+// несколько раз — используется первый подходящий шаблон в порядке указания.
+// Ограничения WithMinTTL и WithMaxTTL применяются и к этому TTL.
+func WithTTLOverride(pattern string, ttl time.Duration) Option {
+	return func(c *LRUCache) {
+		if pattern == "" || ttl < 0 {
+			return
+		}
+		c.ttlOverrides = append(c.ttlOverrides, ttlOverride{pattern: pattern, ttl: ttl})
+	}
+}
+
+// WithMinTTL ограничивает время жизни элементов снизу: положительный TTL меньше minTTL
+// (запрошенный или по умолчанию) увеличивается до minTTL, чтобы почти нулевые TTL
+// не приводили к немедленному истечению и повторной загрузке. Нулевой TTL по-прежнему
+// означает TTL по умолчанию, а явно заданный нулевой TTL — немедленное истечение.
+// При одновременном WithMaxTTL приоритет у максимального ограничения.
+// Значение 0 (по умолчанию) отключает ограничение.
+func WithMinTTL(minTTL time.Duration) Option {
+	return func(c *LRUCache) {
+		if minTTL < 0 {
+			minTTL = 0
+		}
+		c.minTTL = minTTL
+	}
+}
+
+// WithClock заменяет источник текущего времени, по которому вычисляются TTL,
+// давность и время изменения элементов. Предназначен для детерминированных тестов,
+// в которых время продвигается вручную. Фоновая очистка (WithCleanupInterval)
+// по-прежнему запускается по реальному таймеру.
+func WithClock(now func() time.Time) Option {
+	return func(c *LRUCache) {
+		if now != nil {
+			c.now = now
+		}
+	}
+}
+
+// WithLogger включает предупреждения о вытеснении элементов по ёмкости или бюджету
+// памяти: такие вытеснения означают, что кеш мал для рабочего набора и доля попаданий
+// падает. Предупреждения выводятся не чаще раза в evictionWarnInterval с ключом
+// вытесненного элемента, заполненностью кеша и числом пропущенных с прошлого раза вытеснений.
+// Каждое удаление элемента дополнительно записывается на уровне DEBUG с ключом
+// и причиной удаления (поле reason, см. EvictReason).
+func WithLogger(log *slog.Logger) Option {
+	return func(c *LRUCache) {
+		c.log = log
+	}
+}
+
+// WithCaseInsensitiveKeys включает регистронезависимые ключи: во всех операциях
+// ключи и префиксы приводятся к нижнему регистру, поэтому "User:1" и "user:1"
+// обозначают один элемент. Ключи в результатах (GetAll, Items, Item.Key)
+// возвращаются в нижнем регистре.
+func WithCaseInsensitiveKeys(enabled bool) Option {
+	return func(c *LRUCache) {
+		c.caseInsensitive = enabled
+	}
+}
+
+// WithFullnessThresholds задаёт пороги заполненности кеша в процентах от ёмкости
+// (например, 80, 90, 100). При пересечении порога снизу вверх выводится предупреждение
+// (см. WithLogger) и увеличивается счётчик FullnessCrossings — один раз на пересечение,
+// а не на каждую запись. Повторно порог срабатывает, только когда заполненность опустится
+// ниже него на fullnessHysteresis процентных пунктов, что исключает дребезг на границе.
+// Значения вне диапазона (0, 100] игнорируются.
+func WithFullnessThresholds(percents ...int) Option {
+	return func(c *LRUCache) {
+		c.fullness = nil
+		for _, p := range percents {
+			if p > 0 && p <= 100 {
+				c.fullness = append(c.fullness, p)
+			}
+		}
+		sort.Ints(c.fullness)
+		c.fullnessReported = 0
+		c.fullnessCrossings = make([]uint64, len(c.fullness))
+	}
+}
+
+// WithPrefixQuota ограничивает число элементов в одном пространстве ключей — префиксе
+// до первого вхождения separator включительно (например, "tenant:" для ключа "tenant:key").
+// При превышении квоты вытесняется наименее недавно использованный элемент того же
+// префикса, а не всего кеша, поэтому один активный арендатор не вытесняет данные
+// остальных. Ключи без separator квотой не ограничиваются. Значение limit 0 отключает квоту.
+func WithPrefixQuota(separator string, limit int) Option {
+	return func(c *LRUCache) {
+		if separator == "" || limit <= 0 {
+			return
+		}
+		c.prefixSeparator = separator
+		c.prefixQuota = limit
+		c.prefixCounts = make(map[string]int)
+	}
+}
+
+// WithSoftLimit задаёт мягкий лимит числа элементов ниже ёмкости кеша. При каждом
+// превышении лимита кеш заранее удаляет истёкшие элементы и увеличивает счётчик
+// EvictionStats.SoftLimitReached, предупреждая о приближении к вытеснению по ёмкости.
+// Значение 0 (по умолчанию) отключает мягкий лимит.
+func WithSoftLimit(limit int) Option {
+	return func(c *LRUCache) {
+		c.softLimit = limit
+	}
+}
+
+// WithExpiredFirst включает удаление истёкших элементов перед вытеснением по ёмкости:
+// при переполнении кеш сначала удаляет все истёкшие элементы в любом месте списка
+// и вытесняет актуальные давно использованные элементы, только если места всё ещё
+// не хватает. Это повышает долю попаданий ценой обхода всего списка при каждом
+// переполнении. По умолчанию отключено, и вытесняется конец списка.
+func WithExpiredFirst(enabled bool) Option {
+	return func(c *LRUCache) {
+		c.expiredFirst = enabled
+	}
+}
+
+// WithSegmentedLRU включает сегментированный LRU (SLRU): новые элементы попадают
+// в испытательный сегмент и переходят в защищённый только при повторном обращении.
+// Вытесняются в первую очередь элементы испытательного сегмента, поэтому однократное
+// чтение большого числа ключей (сканирование) не вытесняет часто используемые элементы.
+// protectedRatio задаёт долю ёмкости кеша под защищённый сегмент; при его переполнении
+// давно использованные элементы возвращаются в испытательный сегмент.
+// Значение вне интервала (0, 1) заменяется на 0.8.
+func WithSegmentedLRU(protectedRatio float64) Option {
+	return func(c *LRUCache) {
+		if protectedRatio <= 0 || protectedRatio >= 1 {
+			protectedRatio = 0.8
+		}
+		c.protectedCap = int(float64(c.capacity) * protectedRatio)
+		if c.protectedCap < 1 {
+			c.protectedCap = 1
+		}
+	}
+}
+
+// EvictionStats содержит статистику вытеснения элементов по ёмкости и бюджету памяти.
+type EvictionStats struct {
+	Passes               uint64 // Число проходов вытеснения, удаливших хотя бы один элемент
+	Evicted              uint64 // Общее число вытесненных элементов
+	LastPassEvicted      int    // Число элементов, вытесненных за последний проход
+	SoftLimitReached     uint64 // Число превышений мягкого лимита числа элементов (см. WithSoftLimit)
+	MemoryCeilingReached uint64 // Число экстренных вытеснений при превышении предела памяти (см. WithMemoryCeiling)
+}
+
+// AgeStats описывает давность данных в кеше.
+type AgeStats struct {
+	OldestAge  time.Duration // Время с последнего чтения или записи наименее недавно использованного элемента
+	NextExpiry time.Time     // Ближайшее время истечения срока жизни элемента (нулевое — истекающих элементов нет)
+}
+
+// TTLBucketBounds — верхние границы интервалов оставшегося времени жизни в TTLHistogram.
+var TTLBucketBounds = []time.Duration{time.Second, 10 * time.Second, time.Minute, time.Hour}
+
+// TTLHistogram описывает распределение актуальных элементов по оставшемуся времени жизни.
+type TTLHistogram struct {
+	Buckets []int // Число элементов с оставшимся TTL меньше границы TTLBucketBounds с тем же индексом и не меньше предыдущей
+	Longer  int   // Число элементов с оставшимся TTL не меньше последней границы
+	Never   int   // Число бессрочных элементов
+}
+
+// PutOptions содержит дополнительные параметры записи элемента в кеш.
+type PutOptions struct {
+	TTL         time.Duration // Время жизни элемента; 0 — значение по умолчанию
+	ExplicitTTL bool          // TTL задан явно: 0 означает немедленное истечение, а не значение по умолчанию
+	Sliding     bool          // Скользящий TTL: каждое успешное чтение продлевает жизнь элемента на TTL
+	Dirty       bool          // Элемент содержит несохранённые изменения
+	// Cost — стоимость элемента в ёмкости кеша (0 — стоимость 1). Ёмкость ограничивает
+	// суммарную стоимость элементов, поэтому дорогой элемент вытесняет несколько дешёвых.
+	// Стоимость может отражать любую меру веса, например затраты на получение значения.
+	// Элемент дороже ёмкости кеша не записывается.
+	Cost int64
+	// IfVersion — ожидаемая версия существующего элемента (0 — без условия).
+	// Если элемент отсутствует или его версия отличается, возвращается ErrPreconditionFailed.
+	IfVersion uint64
+	// IfAbsent — записать элемент, только если ключ отсутствует (истёкшие и отрицательные
+	// записи считаются отсутствующими). Иначе кеш не изменяется, а PutWithOptions возвращает
+	// текущий элемент вместе с ErrKeyExists — проверка и чтение выполняются атомарно.
+	IfAbsent bool
+	// Tags — метаданные элемента. Кеш хранит копию, а запись без тегов удаляет прежние теги.
+	// Размер тегов учитывается в бюджете памяти (см. WithMaxBytes).
+	Tags Tags
+
+	negative bool // Запись об отсутствии значения (см. PutNegative)
+}
+
+// EvictOptions содержит условия удаления элемента из кеша. Если условие задано,
+// а элемент отсутствует или условие не выполнено, возвращается ErrPreconditionFailed.
+type EvictOptions struct {
+	IfVersion         uint64    // Ожидаемая версия элемента (0 — без условия)
+	IfUnmodifiedSince time.Time // Элемент не должен изменяться позже этого момента (нулевое — без условия)
+}
+
+// NewLRUCache создает новый LRU кеш с заданной емкостью и значением по умолчанию для TTL.
+// Если defaultTTL равен 0, элементы, записанные без TTL, не истекают.
+// Возвращает указатель на новый объект LRUCache.
+//
+// Если capacity не больше 0, кеш работает в неограниченном режиме (словарь с TTL):
+// ёмкость и стоимость элементов игнорируются, элементы не вытесняются по ёмкости и
+// удаляются только по истечении TTL или явно. Список элементов при этом не
+// перестраивается при чтении и обновлении и хранит лишь порядок первой записи
+// для перечисления (Items, Recent, Least). Бюджет памяти (WithMaxBytes), если задан,
+// по-прежнему действует и вытесняет давно записанные элементы. Для своевременного
+// освобождения памяти от истёкших элементов следует задать WithCleanupInterval.
+func NewLRUCache(capacity int, defaultTTL time.Duration, opts ...Option) *LRUCache {
+	c := &LRUCache{
+		cache:      make(map[string]*Node),
+		calls:      make(map[string]*call),
+		waiters:    make(map[string][]chan struct{}),
+		promotions: make(chan *Node, promotionBufferSize),
+		now:        time.Now,
+		done:       make(chan struct{}),
+		capacity:   capacity,
+		defaultTTL: defaultTTL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.evictRate > 0 && c.onEvict != nil {
+		c.evictQueue = make(chan evictedEntry, c.evictQueueSize)
+		c.background.Add(1)
+		go c.runEvictions()
+	}
+	if c.cleanupInterval > 0 {
+		c.background.Add(1)
+		go c.runCleanup(c.cleanupInterval)
+	}
+	if c.snapshotInterval > 0 {
+		_, _ = c.RefreshSnapshot(context.Background())
+		c.background.Add(1)
+		go c.runSnapshots(c.snapshotInterval)
+	}
+	if c.memoryCeiling > 0 && c.memoryInterval > 0 {
+		if c.heapUsage == nil {
+			c.heapUsage = readHeapAlloc
+		}
+		c.background.Add(1)
+		go c.runMemoryCeiling(c.memoryInterval)
+	}
+	return c
+}
+
+// Close останавливает фоновые горутины кеша и дожидается их завершения.
+// После закрытия операции с кешем возвращают ErrClosed. Повторный вызов безопасен.
+func (c *LRUCache) Close() error {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		close(c.done)
+	})
+	if c.evictQueue != nil {
+		// Дожидаемся записей, начавших постановку в очередь до закрытия: последующие
+		// записи видят closed и вызывают обработчик вытеснения синхронно
+		c.evictMutex.Lock()
+		c.evictMutex.Unlock() //nolint:staticcheck // пустая критическая секция — барьер
+	}
+	c.background.Wait()
+	if c.evictQueue != nil {
+		c.flushEvicted()
+	}
+	return nil
+}
+
+// runCleanup периодически удаляет истёкшие элементы до закрытия кеша.
+func (c *LRUCache) runCleanup(interval time.Duration) {
+	defer c.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.removeExpired()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// removeExpired удаляет из кеша все истёкшие элементы.
+func (c *LRUCache) removeExpired() {
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+	}()
+	c.drainPromotions()
+	c.purgeExpired(nil, &evicted)
+}
+
+// purgeExpired удаляет все истёкшие элементы, кроме keep, с учётом WithStaleWindow. Вызывается под блокировкой на запись.
+func (c *LRUCache) purgeExpired(keep *Node, evicted *[]*Node) {
+	now := c.now()
+	for node := c.front(); node != nil; {
+		next := c.nextNode(node)
+		if node != keep && c.removable(node, now) {
+			c.removeElement(node, EvictReasonExpired, evicted)
+		}
+		node = next
+	}
+}
+
+// checkOpen возвращает ошибку контекста или ErrClosed, если операцию выполнять нельзя.
+func (c *LRUCache) checkOpen(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// segment возвращает указатели на начало и конец списка, которому принадлежит узел.
+func (c *LRUCache) segment(node *Node) (head, tail **Node) {
+	if node.protected {
+		return &c.protectedHead, &c.protectedTail
+	}
+	return &c.head, &c.tail
+}
+
+// addNode добавляет новый узел в начало его списка.
+func (c *LRUCache) addNode(node *Node) {
+	head, tail := c.segment(node)
+	node.next = *head
+	if *head != nil {
+		(*head).prev = node
+	}
+	*head = node
+	if *tail == nil {
+		*tail = node
+	}
+	if node.protected {
+		c.protectedLen++
+	}
+}
+
+// moveToHead перемещает указанный узел в начало списка (в начало списка недавно использованных элементов).
+// В режиме SLRU узел переводится в защищённый сегмент, а при его переполнении давно
+// использованный элемент защищённого сегмента возвращается в испытательный.
+func (c *LRUCache) moveToHead(node *Node) {
+	c.removeNode(node)
+	if c.protectedCap > 0 {
+		node.protected = true
+	}
+	c.addNode(node)
+
+	if c.protectedLen > c.protectedCap && c.protectedCap > 0 {
+		demoted := c.protectedTail
+		c.removeNode(demoted)
+		demoted.protected = false
+		c.addNode(demoted)
+	}
+}
+
+// removeNode удаляет узел из списка.
+func (c *LRUCache) removeNode(node *Node) {
+	head, tail := c.segment(node)
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		*head = node.next
+	}
+
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		*tail = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+	if node.protected {
+		c.protectedLen--
+	}
+}
+
+// front возвращает недавно использованный элемент: начало защищённого сегмента,
+// а если он пуст — начало основного списка.
+func (c *LRUCache) front() *Node {
+	if c.protectedHead != nil {
+		return c.protectedHead
+	}
+	return c.head
+}
+
+// nextNode возвращает следующий за node элемент в порядке от недавно использованных
+// к давно использованным. После защищённого сегмента следует испытательный.
+func (c *LRUCache) nextNode(node *Node) *Node {
+	if node.next != nil || !node.protected {
+		return node.next
+	}
+	return c.head
+}
+
+// back возвращает давно использованный элемент: конец основного списка,
+// а если он пуст — конец защищённого сегмента.
+func (c *LRUCache) back() *Node {
+	if c.tail != nil {
+		return c.tail
+	}
+	return c.protectedTail
+}
+
+// prevNode возвращает предшествующий node элемент в порядке от недавно использованных
+// к давно использованным. Перед испытательным сегментом следует защищённый.
+func (c *LRUCache) prevNode(node *Node) *Node {
+	if node.prev != nil || node.protected {
+		return node.prev
+	}
+	return c.protectedTail
+}
+
+// victim возвращает элемент для вытеснения: конец испытательного сегмента,
+// а если в нём остался только keep — конец защищённого сегмента.
+func (c *LRUCache) victim(keep *Node) *Node {
+	if c.tail != nil && c.tail != keep {
+		return c.tail
+	}
+	if c.protectedTail != keep {
+		return c.protectedTail
+	}
+	return nil
+}
+
+// schedulePromotion откладывает перемещение узла в начало списка до ближайшей операции
+// под блокировкой на запись. Если буфер отложенных перемещений заполнен, накопленные
+// перемещения применяются сразу. Вызывается без удержания мьютекса.
+func (c *LRUCache) schedulePromotion(node *Node) {
+	select {
+	case c.promotions <- node:
+	default:
+		c.mutex.Lock()
+		c.drainPromotions()
+		c.promote(node, c.now())
+		c.mutex.Unlock()
+	}
+}
+
+// drainPromotions применяет отложенные перемещения узлов в порядке чтения.
+// Должен вызываться под блокировкой на запись.
+func (c *LRUCache) drainPromotions() {
+	now := c.now()
+	for {
+		select {
+		case node := <-c.promotions:
+			c.promote(node, now)
+		default:
+			return
+		}
+	}
+}
+
+// flushPromotions применяет отложенные перемещения, если они есть.
+// Вызывается без удержания мьютекса перед чтением порядка списка под блокировкой на чтение.
+func (c *LRUCache) flushPromotions() {
+	if len(c.promotions) == 0 {
+		return
+	}
+	c.mutex.Lock()
+	c.drainPromotions()
+	c.mutex.Unlock()
+}
+
+// promote перемещает узел в начало списка, если он всё ещё находится в кеше.
+// Должен вызываться под блокировкой на запись.
+func (c *LRUCache) promote(node *Node, now time.Time) {
+	if c.cache[node.key] != node {
+		return
+	}
+	node.promotedAt = now
+	c.moveToHead(node)
+}
+
+// removeElement удаляет узел из карты и списка; reason — причина удаления для журнала.
+// Если узел «грязный», он добавляется в список вытесненных для вызова обработчика.
+func (c *LRUCache) removeElement(node *Node, reason EvictReason, evicted *[]*Node) {
+	delete(c.cache, node.key)
+	if prefix, ok := c.keyPrefix(node.key); ok {
+		if c.prefixCounts[prefix]--; c.prefixCounts[prefix] == 0 {
+			delete(c.prefixCounts, prefix)
+		}
+	}
+	c.removeNode(node)
+	c.usedBytes -= node.size
+	c.usedCost -= node.cost
+	c.addTombstone(node.key)
+	if c.log != nil {
+		c.log.Debug("Entry removed from cache", "key", node.key, "reason", reason)
+	}
+	if evicted != nil && node.dirty {
+		*evicted = append(*evicted, node)
+	}
+}
+
+// normalizeKey приводит ключ или префикс ключа к виду, в котором он хранится в кеше.
+func (c *LRUCache) normalizeKey(key string) string {
+	if c.caseInsensitive {
+		return strings.ToLower(key)
+	}
+	return key
+}
+
+// keyPrefix возвращает префикс пространства ключей для квоты WithPrefixQuota.
+// Возвращает false, если квота отключена или ключ не содержит разделителя.
+func (c *LRUCache) keyPrefix(key string) (string, bool) {
+	if c.prefixQuota == 0 {
+		return "", false
+	}
+	i := strings.Index(key, c.prefixSeparator)
+	if i < 0 {
+		return "", false
+	}
+	return key[:i+len(c.prefixSeparator)], true
+}
+
+// evictPrefix вытесняет наименее недавно использованный элемент с префиксом prefix.
+// Вызывается под блокировкой на запись, когда префикс исчерпал квоту.
+func (c *LRUCache) evictPrefix(prefix string, evicted *[]*Node) {
+	for node := c.back(); node != nil; node = c.prevNode(node) {
+		if strings.HasPrefix(node.key, prefix) {
+			c.removeElement(node, EvictReasonCapacity, evicted)
+			c.evictionStats.Passes++
+			c.evictionStats.Evicted++
+			c.evictionStats.LastPassEvicted = 1
+			return
+		}
+	}
+}
+
+// evictOverflow за один проход вытесняет давно использованные элементы, пока их суммарная
+// стоимость и суммарный размер не уложатся в ёмкость и бюджет памяти. Узел keep, только что
+// записанный в начало списка, не вытесняется. Должен вызываться под блокировкой на запись.
+func (c *LRUCache) evictOverflow(keep *Node, evicted *[]*Node) {
+	if c.expiredFirst && c.overflowed() {
+		c.purgeExpired(keep, evicted)
+	}
+	count := 0
+	for c.overflowed() {
+		node := c.victim(keep)
+		if node == nil {
+			break
+		}
+		c.removeElement(node, EvictReasonCapacity, evicted)
+		c.warnEviction(node.key)
+		count++
+	}
+	if count > 0 {
+		c.evictionStats.Passes++
+		c.evictionStats.Evicted += uint64(count)
+		c.evictionStats.LastPassEvicted = count
+	}
+}
+
+// warnEviction логирует вытеснение элемента по ёмкости не чаще evictionWarnInterval.
+// Вызывается под блокировкой на запись.
+func (c *LRUCache) warnEviction(key string) {
+	if c.log == nil {
+		return
+	}
+	now := c.now()
+	if !c.lastEvictionWarn.IsZero() && now.Sub(c.lastEvictionWarn) < evictionWarnInterval {
+		c.suppressedWarns++
+		return
+	}
+	c.log.Warn("Entry evicted due to capacity pressure",
+		"key", key,
+		"reason", EvictReasonCapacity,
+		"size", len(c.cache),
+		"capacity", c.capacity,
+		"used_cost", c.usedCost,
+		"used_bytes", c.usedBytes,
+		"max_bytes", c.maxBytes,
+		"suppressed", c.suppressedWarns,
+	)
+	c.lastEvictionWarn = now
+	c.suppressedWarns = 0
+}
+
+// checkFullness отмечает пересечение порогов заполненности после добавления элемента.
+// Вызывается под блокировкой на запись.
+func (c *LRUCache) checkFullness() {
+	if len(c.fullness) == 0 || c.capacity <= 0 {
+		return
+	}
+	percent := int(c.usedCost * 100 / int64(c.capacity))
+	for c.fullnessReported > 0 && percent < c.fullness[c.fullnessReported-1]-fullnessHysteresis {
+		c.fullnessReported--
+	}
+	for c.fullnessReported < len(c.fullness) && percent >= c.fullness[c.fullnessReported] {
+		c.fullnessCrossings[c.fullnessReported]++
+		if c.log != nil {
+			c.log.Warn("Cache fullness threshold crossed",
+				"threshold_percent", c.fullness[c.fullnessReported],
+				"size", len(c.cache),
+				"capacity", c.capacity,
+			)
+		}
+		c.fullnessReported++
+	}
+}
+
+// overflowed сообщает, превышены ли ёмкость или бюджет памяти кеша.
+func (c *LRUCache) overflowed() bool {
+	return (!c.unbounded() && c.usedCost > int64(c.capacity)) || (c.maxBytes > 0 && c.usedBytes > c.maxBytes)
+}
+
+// notifyEvicted вызывает обработчик вытеснения для переданных узлов.
+// Должен вызываться без удержания мьютекса.
+func (c *LRUCache) notifyEvicted(nodes []*Node) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, node := range nodes {
+		if c.evictQueue != nil {
+			c.enqueueEvicted(evictedEntry{key: node.key, value: nodeValue(node)})
+			continue
+		}
+		c.onEvict(node.key, nodeValue(node))
+	}
+}
+
+// Put добавляет новый элемент в кеш с заданным ключом, значением и TTL.
+// Если элемент с таким ключом уже существует, его значение обновляется и TTL сбрасывается.
+// Если кеш переполнен, удаляется наименее недавно использованный элемент.
+func (c *LRUCache) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	_, _, err := c.PutWithOptions(ctx, key, value, PutOptions{TTL: ttl})
+	return err
+}
+
+// PutWithOptions добавляет элемент в кеш с дополнительными параметрами записи.
+// Поведение аналогично Put; признак Dirty определяет, будет ли вызван обработчик
+// вытеснения при удалении элемента по ёмкости или TTL.
+// Возвращает записанный элемент и true, если элемент был создан, или false, если обновлён существующий.
+func (c *LRUCache) PutWithOptions(ctx context.Context, key string, value interface{}, opts PutOptions) (item Item, created bool, err error) {
+	ttl := opts.TTL
+	key = c.normalizeKey(key)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := c.checkOpen(ctx); err != nil {
+		return Item{}, false, err
+	}
+
+	if key == "" {
+		return Item{}, false, errEmptyKey
+	}
+
+	if value == nil && !opts.negative {
+		return Item{}, false, ErrNilValue
+	}
+
+	if ttl < 0 {
+		return Item{}, false, errNegativeTTL
+	}
+
+	cost := opts.Cost
+	if cost < 0 {
+		return Item{}, false, errNegativeCost
+	}
+	if cost == 0 {
+		cost = 1
+	}
+	if !c.unbounded() && cost > int64(c.capacity) {
+		return Item{}, false, errCostTooLarge
+	}
+
+	stored, compressed := c.compressValue(value)
+	tags := cloneTags(opts.Tags)
+	size := sizeOf(key, stored) + tags.size()
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return Item{}, false, errTooLarge
+	}
+
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+		if err == nil && !opts.negative {
+			c.notifyWaiters(key)
+		}
+	}()
+	c.drainPromotions()
+
+	node, exists := c.cache[key]
+	if opts.IfVersion != 0 && (!exists || node.version != opts.IfVersion) {
+		return Item{}, false, ErrPreconditionFailed
+	}
+	if opts.IfAbsent && exists && !node.negative && !node.expired(c.now()) {
+		return nodeItem(node), false, ErrKeyExists
+	}
+
+	lifetime, clamped := c.getTTL(key, ttl, opts.ExplicitTTL)
+	now := c.now()
+	// Нулевой TTL по умолчанию означает бессрочный элемент
+	var expiresAt time.Time
+	if lifetime > 0 || opts.ExplicitTTL {
+		expiresAt = now.Add(lifetime)
+	}
+
+	c.version++
+	if exists {
+		node.value = stored
+		node.compressed = compressed
+		node.TTL = expiresAt
+		node.sliding = opts.Sliding
+		node.lifetime = lifetime
+		node.tags = tags
+		node.dirty = opts.Dirty
+		node.negative = opts.negative
+		node.modifiedAt = now
+		node.promotedAt = now
+		node.touch(now)
+		c.usedBytes += size - node.size
+		node.size = size
+		c.usedCost += cost - node.cost
+		node.cost = cost
+		node.version = c.version
+		if !c.unbounded() {
+			c.moveToHead(node)
+		}
+		c.evictOverflow(node, &evicted)
+		// Новая стоимость элемента меняет заполненность так же, как добавление
+		c.checkFullness()
+		return Item{Key: key, Value: value, ExpiresAt: node.TTL, Version: node.version, ModifiedAt: node.modifiedAt, TTLClamped: clamped, Tags: cloneTags(tags)}, false, nil
+	}
+
+	// Ключ копируется, чтобы кеш не удерживал буфер, частью которого может быть переданная
+	// строка (например, тело запроса); карта и узел разделяют эту единственную копию
+	key = strings.Clone(key)
+	newNode := &Node{
+		key:        key,
+		value:      stored,
+		compressed: compressed,
+		TTL:        expiresAt,
+		sliding:    opts.Sliding,
+		lifetime:   lifetime,
+		tags:       tags,
+		dirty:      opts.Dirty,
+		negative:   opts.negative,
+		modifiedAt: now,
+		promotedAt: now,
+		size:       size,
+		cost:       cost,
+		version:    c.version,
+	}
+	newNode.touch(now)
+	if prefix, ok := c.keyPrefix(key); ok {
+		if c.prefixCounts[prefix] >= c.prefixQuota {
+			c.evictPrefix(prefix, &evicted)
+		}
+		c.prefixCounts[prefix]++
+	}
+	c.cache[key] = newNode
+	c.addNode(newNode)
+	c.usedBytes += size
+	c.usedCost += cost
+	if c.softLimit > 0 && len(c.cache) == c.softLimit+1 {
+		c.evictionStats.SoftLimitReached++
+		c.purgeExpired(newNode, &evicted)
+	}
+	c.evictOverflow(newNode, &evicted)
+	c.checkFullness()
+	return Item{Key: key, Value: value, ExpiresAt: newNode.TTL, Version: newNode.version, ModifiedAt: newNode.modifiedAt, TTLClamped: clamped, Tags: cloneTags(tags)}, true, nil
+}
+
+// PutNegative добавляет в кеш отрицательную запись: ключ помечается как отсутствующий
+// в источнике данных. До истечения TTL Get по этому ключу возвращает ErrNegativeCached,
+// позволяя не обращаться к источнику повторно. Если ttl равен 0, используется
+// значение WithNegativeTTL, а при его отсутствии — TTL по умолчанию.
+func (c *LRUCache) PutNegative(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = c.negativeTTL
+	}
+	_, _, err := c.PutWithOptions(ctx, key, nil, PutOptions{TTL: ttl, negative: true})
+	return err
+}
+
+// TouchMany продлевает срок жизни всех актуальных элементов из keys на ttl от текущего
+// момента под одной блокировкой. Если ttl равен 0, используется значение по умолчанию
+// для ключа (см. WithTTLOverride). Отсутствующие, истёкшие и отрицательные записи
+// пропускаются: отсутствие значения кешируется только на заданный при записи срок.
+// Значения и порядок элементов в списке не изменяются, а версия продлённого элемента
+// увеличивается, как при записи, чтобы новый срок жизни был виден по ETag и в Changes.
+// Возвращает число продлённых элементов.
+func (c *LRUCache) TouchMany(ctx context.Context, keys []string, ttl time.Duration) (touched int, err error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return 0, err
+	}
+
+	if ttl < 0 {
+		return 0, errNegativeTTL
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	for _, key := range keys {
+		key = c.normalizeKey(key)
+		node, exists := c.cache[key]
+		if !exists || node == nil || node.negative || node.expired(now) {
+			continue
+		}
+		lifetime, _ := c.getTTL(key, ttl, false)
+		var expiresAt time.Time
+		if lifetime > 0 {
+			expiresAt = now.Add(lifetime)
+		}
+		node.TTL = expiresAt
+		node.lifetime = lifetime
+		c.version++
+		node.version = c.version
+		touched++
+	}
+	return touched, nil
+}
+
+// TouchIfBelow продлевает срок жизни элемента до newTTL от текущего момента, только если
+// до его истечения осталось меньше minRemaining. Это позволяет продлевать горячие ключи
+// заранее (refresh-ahead), не перезаписывая срок жизни при каждом обращении: проверка
+// выполняется под блокировкой на чтение, и блокировка на запись захватывается только
+// для продления. Если newTTL равен 0, используется значение по умолчанию для ключа.
+// Бессрочные элементы не продлеваются. Значение, версия и положение элемента в списке
+// не изменяются. Для отсутствующего или истёкшего элемента возвращается ошибка.
+func (c *LRUCache) TouchIfBelow(ctx context.Context, key string, minRemaining, newTTL time.Duration) (extended bool, err error) {
+	key = c.normalizeKey(key)
+	if err := c.checkOpen(ctx); err != nil {
+		return false, err
+	}
+
+	if key == "" {
+		return false, errEmptyKey
+	}
+	if minRemaining < 0 || newTTL < 0 {
+		return false, errNegativeTTL
+	}
+
+	c.mutex.RLock()
+	extend, err := c.belowRemaining(key, minRemaining)
+	c.mutex.RUnlock()
+	if !extend {
+		return false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Элемент мог быть продлён или удалён между снятием блокировки на чтение и захватом блокировки на запись
+	if extend, err := c.belowRemaining(key, minRemaining); !extend {
+		return false, err
+	}
+	node := c.cache[key]
+	lifetime, _ := c.getTTL(key, newTTL, false)
+	var expiresAt time.Time
+	if lifetime > 0 {
+		expiresAt = c.now().Add(lifetime)
+	}
+	node.TTL = expiresAt
+	node.lifetime = lifetime
+	return true, nil
+}
+
+// belowRemaining сообщает, осталось ли до истечения элемента key меньше minRemaining.
+// Вызывается под блокировкой.
+func (c *LRUCache) belowRemaining(key string, minRemaining time.Duration) (bool, error) {
+	node, exists := c.cache[key]
+	if !exists || node == nil {
+		return false, errKeyNotFound
+	}
+	now := c.now()
+	if node.expired(now) {
+		return false, errExpiredKey
+	}
+	return !node.TTL.IsZero() && node.TTL.Sub(now) < minRemaining, nil
+}
+
+// Get возвращает значение по ключу из кеша. Также возвращается время истечения срока жизни элемента (TTL).
+// Найденный элемент перемещается в начало списка с учётом WithPromotionThrottle.
+// Если элемент не найден или его TTL истек, возвращается ошибка.
+// Для отрицательной записи возвращается ErrNegativeCached.
+//
+// Актуальный элемент читается под блокировкой на чтение, а его перемещение в начало
+// списка откладывается и применяется пакетно ближайшей операцией под блокировкой на запись.
+// Элементы со скользящим TTL (PutOptions.Sliding) читаются под блокировкой на запись,
+// так как чтение продлевает их срок жизни.
+func (c *LRUCache) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
+	item, err := c.GetItem(ctx, key)
+	return item.Value, item.ExpiresAt, err
+}
+
+// GetItem возвращает элемент по ключу вместе с его версией. Поведение аналогично Get.
+func (c *LRUCache) GetItem(ctx context.Context, key string) (Item, error) {
+	item, err := c.getItem(ctx, key)
+	c.recordLookup(err)
+	return item, err
+}
+
+// getItem выполняет чтение элемента для GetItem без учёта в статистике попаданий.
+func (c *LRUCache) getItem(ctx context.Context, key string) (Item, error) {
+	key = c.normalizeKey(key)
+	if err := c.checkOpen(ctx); err != nil {
+		return Item{}, err
+	}
+
+	if key == "" {
+		return Item{}, errEmptyKey
+	}
+
+	// Быстрый путь: элемент актуален и чтение не изменяет его TTL
+	c.mutex.RLock()
+	if node, exists := c.cache[key]; exists && node != nil && !node.sliding {
+		now := c.now()
+		if !node.expired(now) {
+			item, err := nodeResult(node)
+			node.touch(now)
+			promote := c.needsPromotion(node, now)
+			c.mutex.RUnlock()
+			if promote {
+				c.schedulePromotion(node)
+			}
+			return item, err
+		}
+	}
+	c.mutex.RUnlock()
+
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+	}()
+	c.drainPromotions()
+
+	node, exists := c.cache[key]
+	if !exists {
+		return Item{}, errKeyNotFound
+	}
+
+	if now := c.now(); node.expired(now) {
+		if c.removable(node, now) {
+			c.removeElement(node, EvictReasonExpired, &evicted)
+		}
+		return Item{}, errExpiredKey
+	}
+
+	if node == nil {
+		return Item{}, errNilNode
+	}
+
+	// Элемент мог быть обновлён между снятием блокировки на чтение и захватом блокировки на запись
+	now := c.now()
+	node.touch(now)
+	if c.needsPromotion(node, now) {
+		c.promote(node, now)
+	}
+	if node.sliding && !node.TTL.IsZero() {
+		node.TTL = now.Add(node.lifetime)
+	}
+
+	return nodeResult(node)
+}
+
+// nodeResult возвращает результат чтения узла с учётом отрицательных записей.
+func nodeResult(node *Node) (Item, error) {
+	if node.negative {
+		return Item{Key: node.key, ExpiresAt: node.TTL, Version: node.version, ModifiedAt: node.modifiedAt}, ErrNegativeCached
+	}
+	return nodeItem(node), nil
 }
 
-// LRUCache представляет собой структуру кеша с алгоритмом LRU, поддерживающего TTL для элементов.
-type LRUCache struct {
-	head       *Node            // Указатель на первый элемент в списке
-	tail       *Node            // Указатель на последний элемент в списке
-	cache      map[string]*Node // Карта для хранения элементов кеша по ключу
-	capacity   int              // Максимальная ёмкость кеша
-	defaultTTL time.Duration    // Значение по умолчанию для TTL
-	mutex      sync.RWMutex     // Мьютекс для безопасного доступа к кешу
+// nodeItem возвращает копию данных узла.
+func nodeItem(node *Node) Item {
+	return Item{Key: node.key, Value: nodeValue(node), ExpiresAt: node.TTL, Version: node.version, ModifiedAt: node.modifiedAt, Tags: cloneTags(node.tags)}
 }
 
-// NewLRUCache создает новый LRU кеш с заданной емкостью и значением по умолчанию для TTL.
-// Возвращает указатель на новый объект LRUCache.
-func NewLRUCache(capacity int, defaultTTL time.Duration) *LRUCache {
-	return &LRUCache{
-		cache:      make(map[string]*Node),
-		capacity:   capacity,
-		defaultTTL: defaultTTL,
+// GetMany возвращает актуальные элементы по набору ключей так же, как GetItem.
+// Отсутствующие, истёкшие и отрицательные записи в результат не попадают.
+func (c *LRUCache) GetMany(ctx context.Context, keys []string) (map[string]Item, error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]Item, len(keys))
+	for _, key := range keys {
+		item, err := c.GetItem(ctx, key)
+		if err == nil {
+			items[key] = item
+			continue
+		}
+		// Промах не прерывает выборку, а отмена контекста и закрытие кеша — прерывают
+		if err := c.checkOpen(ctx); err != nil {
+			return nil, err
+		}
 	}
+	return items, nil
 }
 
-// addNode добавляет новый узел в начало списка.
-func (c *LRUCache) addNode(node *Node) {
-	node.next = c.head
-	if c.head != nil {
-		c.head.prev = node
+// ExpiresAtMany возвращает время истечения срока жизни актуальных элементов по набору
+// ключей под одной блокировкой на чтение (нулевое время — бессрочный элемент).
+// Отсутствующие, истёкшие и отрицательные записи в результат не попадают. В отличие
+// от GetMany, элементы не перемещаются в начало списка, а скользящий TTL не продлевается.
+func (c *LRUCache) ExpiresAtMany(ctx context.Context, keys []string) (map[string]time.Time, error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, err
 	}
-	c.head = node
-	if c.tail == nil {
-		c.tail = node
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	now := c.now()
+	expiresAt := make(map[string]time.Time, len(keys))
+	for _, key := range keys {
+		node, exists := c.cache[c.normalizeKey(key)]
+		if !exists || node.negative || node.expired(now) {
+			continue
+		}
+		expiresAt[key] = node.TTL
 	}
+	return expiresAt, nil
 }
 
-// moveToHead перемещает указанный узел в начало списка (в начало списка недавно использованных элементов).
-func (c *LRUCache) moveToHead(node *Node) {
-	c.removeNode(node)
-	c.addNode(node)
+// GetOrCompute возвращает значение по ключу, а при его отсутствии или истечении TTL
+// вызывает loader и сохраняет результат в кеш с заданным TTL.
+// Одновременные промахи по одному ключу приводят к единственному вызову loader:
+// остальные вызовы ожидают его завершения и получают тот же результат или ошибку.
+// Загрузчик выполняется с контекстом вызова, инициировавшего загрузку, но без его отмены:
+// отмена инициатора не прерывает загрузку для остальных ожидающих. Если загрузчик
+// паникует, паника передаётся инициатору, а ожидающие вызовы паникуют с ошибкой,
+// содержащей её значение и стек; следующий промах снова вызывает загрузчик.
+func (c *LRUCache) GetOrCompute(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return c.getOrLoad(ctx, key, func(ctx context.Context) (interface{}, time.Duration, error) {
+		value, err := loader(ctx)
+		return value, ttl, err
+	})
 }
 
-// removeNode удаляет узел из списка.
-func (c *LRUCache) removeNode(node *Node) {
-	if node.prev != nil {
-		node.prev.next = node.next
-	} else {
-		c.head = node.next
+// getOrLoad реализует GetOrCompute для загрузчика, возвращающего также TTL значения.
+// Если загрузчик возвращает ErrNegativeCached, в кеш добавляется отрицательная запись с этим TTL.
+// Отрицательная запись в кеше возвращается как ErrNegativeCached без вызова загрузчика.
+func (c *LRUCache) getOrLoad(ctx context.Context, key string, loader func(ctx context.Context) (interface{}, time.Duration, error)) (interface{}, error) {
+	key = c.normalizeKey(key)
+	value, _, err := c.Get(ctx, key)
+	if err == nil || errors.Is(err, ErrNegativeCached) {
+		return value, err
 	}
-
-	if node.next != nil {
-		node.next.prev = node.prev
-	} else {
-		c.tail = node.prev
+	if !errors.Is(err, errKeyNotFound) && !errors.Is(err, errExpiredKey) {
+		return nil, err
 	}
-	node.prev = nil
-	node.next = nil
-}
 
-// Put добавляет новый элемент в кеш с заданным ключом, значением и TTL.
-// Если элемент с таким ключом уже существует, его значение обновляется и TTL сбрасывается.
-// Если кеш переполнен, удаляется наименее недавно использованный элемент.
-func (c *LRUCache) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	if ctx == nil {
-		ctx = context.Background()
+	c.callsMutex.Lock()
+	if cl, exists := c.calls[key]; exists {
+		c.callsMutex.Unlock()
+		select {
+		case <-cl.done:
+			if cl.panicked != nil {
+				panic(cl.panicked)
+			}
+			return cl.value, cl.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
-	if err := ctx.Err(); err != nil {
-		return err
+	// Загрузка могла завершиться между промахом и захватом мьютекса. Промах уже учтён
+	// в статистике попаданий, поэтому повторное чтение в ней не учитывается
+	if item, err := c.getItem(ctx, key); err == nil || errors.Is(err, ErrNegativeCached) {
+		c.callsMutex.Unlock()
+		return item.Value, err
 	}
 
-	if key == "" {
-		return errEmptyKey
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.callsMutex.Unlock()
+
+	c.runLoader(context.WithoutCancel(ctx), key, cl, loader)
+	return cl.value, cl.err
+}
+
+// runLoader выполняет загрузку для вызова cl и сохраняет результат в кеш. Вызов
+// удаляется из calls и завершается и при панике загрузчика: паника запоминается
+// для ожидающих и передаётся дальше. Если загрузчик завершил горутину через
+// runtime.Goexit, ожидающие получают ошибку, а Goexit продолжается без паники.
+func (c *LRUCache) runLoader(ctx context.Context, key string, cl *call, loader func(ctx context.Context) (interface{}, time.Duration, error)) {
+	completed := false
+	defer func() {
+		var rec interface{}
+		if !completed {
+			// recover возвращает nil только при runtime.Goexit: panic(nil) приходит как *runtime.PanicNilError
+			if rec = recover(); rec == nil {
+				cl.err = errLoaderGoexit
+			} else {
+				cl.panicked = &loaderPanic{value: rec, stack: debug.Stack()}
+				cl.err = cl.panicked
+			}
+		}
+		c.callsMutex.Lock()
+		delete(c.calls, key)
+		c.callsMutex.Unlock()
+		close(cl.done)
+		if rec != nil {
+			panic(rec)
+		}
+	}()
+
+	var ttl time.Duration
+	cl.value, ttl, cl.err = loader(ctx)
+	switch {
+	case cl.err == nil:
+		cl.err = c.Put(ctx, key, cl.value, ttl)
+	case errors.Is(cl.err, ErrNegativeCached):
+		// Загрузчик сообщил об отсутствии значения, которое нужно закешировать
+		if err := c.PutNegative(ctx, key, ttl); err != nil {
+			cl.err = err
+		}
 	}
+	completed = true
+}
 
-	if ttl < 0 {
-		return errNegativeTTL
+// GetAll возвращает все ключи и значения из кеша. Отрицательные записи не возвращаются.
+func (c *LRUCache) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, nil, err
 	}
 
+	var evicted []*Node
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+	}()
 
-	if node, exists := c.cache[key]; exists {
-		node.value = value
-		node.TTL = time.Now().Add(c.getTTL(ttl))
-		c.moveToHead(node)
-		return nil
+	if len(c.cache) == 0 {
+		return nil, nil, ErrEmptyCache
 	}
+	c.drainPromotions()
 
-	if len(c.cache) >= c.capacity {
-		if c.tail == nil {
-			return errNilNode
+	now := c.now()
+	for node := c.front(); node != nil; {
+		next := c.nextNode(node)
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		default:
+			if node.expired(now) {
+				c.removeElement(node, EvictReasonExpired, &evicted)
+			} else if !node.negative {
+				keys = append(keys, node.key)
+				values = append(values, nodeValue(node))
+			}
+			node = next
 		}
-		delete(c.cache, c.tail.key)
-		c.removeNode(c.tail)
 	}
+	return keys, values, nil
+}
 
-	newNode := &Node{
-		key:   key,
-		value: value,
-		TTL:   time.Now().Add(c.getTTL(ttl)),
+// Items возвращает снимок актуальных элементов кеша в порядке от недавно использованных
+// к давно использованным. Снимок содержит ссылки на значения и строится под блокировкой
+// на чтение, поэтому последующая обработка элементов не блокирует запись в кеш.
+// Истёкшие и отрицательные записи в снимок не попадают.
+func (c *LRUCache) Items(ctx context.Context) ([]Item, error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, err
 	}
-	c.cache[key] = newNode
-	c.addNode(newNode)
-	return nil
-}
 
-// Get возвращает значение по ключу из кеша. Также возвращается время истечения срока жизни элемента (TTL).
-// Если элемент не найден или его TTL истек, возвращается ошибка.
-func (c *LRUCache) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
-	if err := ctx.Err(); err != nil {
-		return nil, time.Time{}, err
+	c.flushPromotions()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	items := make([]Item, 0, len(c.cache))
+	now := c.now()
+	for node := c.front(); node != nil; node = c.nextNode(node) {
+		if node.expired(now) || node.negative {
+			continue
+		}
+		items = append(items, nodeItem(node))
 	}
+	return items, nil
+}
 
-	if key == "" {
-		return nil, time.Time{}, errEmptyKey
+// Range вызывает fn для каждого актуального элемента кеша в порядке списка LRU — от недавно
+// использованных к давно использованным — и прекращает обход, если fn вернула false.
+// Истёкшие и отрицательные записи пропускаются, порядок элементов обход не меняет.
+//
+// Обход выполняется под блокировкой кеша на чтение, поэтому fn должна быть быстрой и
+// не должна вызывать методы кеша: запись из fn приведёт к взаимоблокировке. Чтобы изменить
+// кеш по результатам обхода (например, выборочно удалить элементы), соберите ключи в fn
+// и обработайте их после возврата из Range либо используйте снимок Items.
+// При отмене ctx обход прерывается и возвращается ошибка контекста.
+func (c *LRUCache) Range(ctx context.Context, fn func(key string, value interface{}, expiresAt time.Time) bool) error {
+	if err := c.checkOpen(ctx); err != nil {
+		return err
 	}
 
+	c.flushPromotions()
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	node, exists := c.cache[key]
-	if !exists {
-		return nil, time.Time{}, errKeyNotFound
+	now := c.now()
+	for node := c.front(); node != nil; node = c.nextNode(node) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if node.expired(now) || node.negative {
+			continue
+		}
+		if !fn(node.key, nodeValue(node), node.TTL) {
+			return nil
+		}
 	}
+	return nil
+}
 
-	if time.Now().After(node.TTL) {
-		delete(c.cache, key)
-		return nil, time.Time{}, errExpiredKey
+// FindByValue возвращает ключи актуальных элементов, значения которых удовлетворяют
+// match, в порядке от недавно использованных к давно использованным. Метод предназначен
+// для отладки, когда ключ элемента неизвестен: он просматривает весь кеш. Как и в Items,
+// элементы копируются под блокировкой на чтение, а match вызывается уже без неё, поэтому
+// может быть медленной и обращаться к кешу. Порядок элементов обход не меняет.
+// При отмене ctx поиск прерывается и возвращается ошибка контекста.
+func (c *LRUCache) FindByValue(ctx context.Context, match func(interface{}) bool) ([]string, error) {
+	items, err := c.Items(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if node == nil {
-		return nil, time.Time{}, errNilNode
+	var keys []string
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if match(item.Value) {
+			keys = append(keys, item.Key)
+		}
 	}
-
-	return node.value, node.TTL, nil
+	return keys, nil
 }
 
-// GetAll возвращает все ключи и значения из кеша.
-func (c *LRUCache) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
-	if err := ctx.Err(); err != nil {
-		return nil, nil, err
+// Recent возвращает до n недавно использованных актуальных элементов, ключи которых
+// начинаются с prefix, в порядке от недавно использованных к давно использованным.
+// Список обходится от начала под блокировкой на чтение и только до набора n элементов,
+// поэтому это дешевле Items для просмотра «горячих» ключей.
+// Истёкшие и отрицательные записи пропускаются.
+func (c *LRUCache) Recent(ctx context.Context, n int, prefix string) ([]Item, error) {
+	prefix = c.normalizeKey(prefix)
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, err
 	}
 
+	c.flushPromotions()
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	if len(c.cache) == 0 {
-		return nil, nil, errEmptyCache
+	var items []Item
+	now := c.now()
+	for node := c.front(); node != nil && len(items) < n; node = c.nextNode(node) {
+		if node.expired(now) || node.negative || !strings.HasPrefix(node.key, prefix) {
+			continue
+		}
+		items = append(items, nodeItem(node))
 	}
+	return items, nil
+}
 
-	now := time.Now()
-	for node := c.head; node != nil; {
-		next := node.next
-		select {
-		case <-ctx.Done():
-			return nil, nil, ctx.Err()
-		default:
-			if now.After(node.TTL) {
-				delete(c.cache, node.key)
-				c.removeNode(node)
-			} else {
-				keys = append(keys, node.key)
-				values = append(values, node.value)
-			}
-			node = next
+// Least возвращает до n давно использованных актуальных элементов, ключи которых
+// начинаются с prefix, в порядке от давно использованных к недавно использованным —
+// это ближайшие кандидаты на вытеснение. Список обходится с конца только до набора
+// n элементов; встреченные по пути истёкшие элементы удаляются.
+// Отрицательные записи пропускаются.
+func (c *LRUCache) Least(ctx context.Context, n int, prefix string) ([]Item, error) {
+	prefix = c.normalizeKey(prefix)
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, err
+	}
+
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+	}()
+	c.drainPromotions()
+
+	var items []Item
+	now := c.now()
+	for node := c.back(); node != nil && len(items) < n; {
+		prev := c.prevNode(node)
+		switch {
+		case node.expired(now):
+			c.removeElement(node, EvictReasonExpired, &evicted)
+		case !node.negative && strings.HasPrefix(node.key, prefix):
+			items = append(items, nodeItem(node))
 		}
+		node = prev
 	}
-	return keys, values, nil
+	return items, nil
 }
 
 // Evict удаляет элемент из кеша по ключу и возвращает его значение.
 // Если элемент не найден, возвращается ошибка.
 func (c *LRUCache) Evict(ctx context.Context, key string) (value interface{}, err error) {
-	if err := ctx.Err(); err != nil {
+	return c.EvictWithOptions(ctx, key, EvictOptions{})
+}
+
+// EvictWithOptions удаляет элемент из кеша, только если выполнены условия opts,
+// и возвращает его значение. Это позволяет не удалить элемент, обновлённый другим
+// клиентом после принятия решения об удалении.
+func (c *LRUCache) EvictWithOptions(ctx context.Context, key string, opts EvictOptions) (value interface{}, err error) {
+	key = c.normalizeKey(key)
+	if err := c.checkOpen(ctx); err != nil {
 		return nil, err
 	}
 
@@ -209,7 +1604,11 @@ func (c *LRUCache) Evict(ctx context.Context, key string) (value interface{}, er
 	defer c.mutex.Unlock()
 
 	node, exists := c.cache[key]
+	conditional := opts.IfVersion != 0 || !opts.IfUnmodifiedSince.IsZero()
 	if !exists {
+		if conditional {
+			return nil, ErrPreconditionFailed
+		}
 		return nil, errKeyNotFound
 	}
 
@@ -217,14 +1616,61 @@ func (c *LRUCache) Evict(ctx context.Context, key string) (value interface{}, er
 		return nil, errNilNode
 	}
 
-	delete(c.cache, key)
-	c.removeNode(node)
-	return node.value, nil
+	if opts.IfVersion != 0 && node.version != opts.IfVersion {
+		return nil, ErrPreconditionFailed
+	}
+	if !opts.IfUnmodifiedSince.IsZero() && node.modifiedAt.After(opts.IfUnmodifiedSince) {
+		return nil, ErrPreconditionFailed
+	}
+
+	c.removeElement(node, EvictReasonDeleted, nil)
+	return nodeValue(node), nil
+}
+
+// Pop атомарно возвращает и удаляет элемент по ключу за один захват блокировки,
+// поэтому из нескольких одновременных вызовов значение получает только один.
+// Подходит для одноразовых токенов и очередей задач. Для отсутствующего или
+// истёкшего ключа возвращается ошибка; отрицательная запись не удаляется,
+// и возвращается ErrNegativeCached.
+func (c *LRUCache) Pop(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
+	key = c.normalizeKey(key)
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if key == "" {
+		return nil, time.Time{}, errEmptyKey
+	}
+
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+	}()
+	c.drainPromotions()
+
+	node, exists := c.cache[key]
+	if !exists {
+		return nil, time.Time{}, errKeyNotFound
+	}
+
+	if node.expired(c.now()) {
+		c.removeElement(node, EvictReasonExpired, &evicted)
+		return nil, time.Time{}, errExpiredKey
+	}
+
+	if node.negative {
+		return nil, node.TTL, ErrNegativeCached
+	}
+
+	c.removeElement(node, EvictReasonDeleted, nil)
+	return nodeValue(node), node.TTL, nil
 }
 
 // EvictAll очищает весь кеш.
 func (c *LRUCache) EvictAll(ctx context.Context) error {
-	if err := ctx.Err(); err != nil {
+	if err := c.checkOpen(ctx); err != nil {
 		return err
 	}
 
@@ -232,18 +1678,296 @@ func (c *LRUCache) EvictAll(ctx context.Context) error {
 	defer c.mutex.Unlock()
 
 	if len(c.cache) == 0 {
-		return errEmptyCache
+		return ErrEmptyCache
 	}
 
+	// Элементы удаляются без removeElement, поэтому в журнал пишется одна сводная запись
+	if c.log != nil {
+		c.log.Debug("All entries removed from cache", "count", len(c.cache), "reason", EvictReasonDeleted)
+	}
 	c.cache = make(map[string]*Node)
+	if c.prefixCounts != nil {
+		c.prefixCounts = make(map[string]int)
+	}
 	c.head, c.tail = nil, nil
+	c.protectedHead, c.protectedTail, c.protectedLen = nil, nil, 0
+	c.usedBytes = 0
+	c.usedCost = 0
+	// Удаления всех ключей не записываются по отдельности: клиентам Changes нужна полная синхронизация
+	c.version++
+	c.changesHorizon = c.version
+	c.tombstones = nil
+	c.drainPromotions()
 	return nil
 }
 
-// getTTL возвращает TTL для элемента. Если TTL равен 0, используется значение по умолчанию.
-func (c *LRUCache) getTTL(ttl time.Duration) time.Duration {
-	if ttl == 0 {
-		return c.defaultTTL
+// unbounded сообщает, работает ли кеш в неограниченном режиме без вытеснения по ёмкости
+// (см. NewLRUCache).
+func (c *LRUCache) unbounded() bool {
+	return c.capacity <= 0
+}
+
+// needsPromotion сообщает, нужно ли переместить узел в начало списка при чтении.
+// В неограниченном режиме порядок списка не поддерживается, и узлы не перемещаются.
+func (c *LRUCache) needsPromotion(node *Node, now time.Time) bool {
+	if c.unbounded() {
+		return false
+	}
+	if c.promoteInterval <= 0 {
+		return !c.atHead(node)
+	}
+	return !c.atHead(node) && now.Sub(node.promotedAt) >= c.promoteInterval
+}
+
+// atHead сообщает, находится ли узел в начале списка недавно использованных элементов.
+// В режиме SLRU это начало защищённого сегмента.
+func (c *LRUCache) atHead(node *Node) bool {
+	if c.protectedCap > 0 {
+		return node.protected && c.protectedHead == node
+	}
+	return c.head == node
+}
+
+// CaseInsensitiveKeys сообщает, приводятся ли ключи к нижнему регистру (см. WithCaseInsensitiveKeys).
+func (c *LRUCache) CaseInsensitiveKeys() bool {
+	return c.caseInsensitive
+}
+
+// Closed сообщает, закрыт ли кеш вызовом Close.
+func (c *LRUCache) Closed() bool {
+	return c.closed.Load()
+}
+
+// Len возвращает текущее число элементов в кеше, включая ещё не удалённые истёкшие.
+func (c *LRUCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.cache)
+}
+
+// Capacity возвращает максимальную ёмкость кеша (0 или меньше — неограниченный режим).
+func (c *LRUCache) Capacity() int {
+	return c.capacity
+}
+
+// UsedCost возвращает суммарную стоимость элементов кеша (см. PutOptions.Cost).
+// Если все элементы записаны со стоимостью по умолчанию, она равна их числу.
+func (c *LRUCache) UsedCost() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.usedCost
+}
+
+// MaxBytes возвращает бюджет памяти кеша в байтах (0 — без ограничения).
+func (c *LRUCache) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+// UsedBytes возвращает суммарный оценочный размер элементов кеша в байтах.
+func (c *LRUCache) UsedBytes() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.usedBytes
+}
+
+// AgeStats возвращает давность наименее недавно использованного актуального элемента
+// и ближайшее время истечения срока жизни. Время истечения ищется полным проходом
+// по кешу, поэтому стоимость вызова линейна по числу элементов. Отрицательные записи
+// не учитываются.
+func (c *LRUCache) AgeStats(ctx context.Context) (AgeStats, error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return AgeStats{}, err
+	}
+
+	c.flushPromotions()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var stats AgeStats
+	now := c.now()
+	for node := c.back(); node != nil; node = c.prevNode(node) {
+		if !node.negative && !node.expired(now) {
+			stats.OldestAge = now.Sub(node.lastAccess())
+			break
+		}
+	}
+	for node := c.front(); node != nil; node = c.nextNode(node) {
+		if node.negative || node.TTL.IsZero() || node.expired(now) {
+			continue
+		}
+		if stats.NextExpiry.IsZero() || node.TTL.Before(stats.NextExpiry) {
+			stats.NextExpiry = node.TTL
+		}
+	}
+	return stats, nil
+}
+
+// TTLHistogram распределяет актуальные элементы по оставшемуся времени жизни
+// (см. TTLBucketBounds), показывая, как кеш будет стареть. Вычисляется обходом списка;
+// отрицательные записи не учитываются.
+func (c *LRUCache) TTLHistogram(ctx context.Context) (TTLHistogram, error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return TTLHistogram{}, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	hist := TTLHistogram{Buckets: make([]int, len(TTLBucketBounds))}
+	now := c.now()
+	for node := c.front(); node != nil; node = c.nextNode(node) {
+		switch {
+		case node.negative:
+		case node.TTL.IsZero():
+			hist.Never++
+		case node.expired(now):
+		default:
+			remaining := node.TTL.Sub(now)
+			i := 0
+			for i < len(TTLBucketBounds) && remaining >= TTLBucketBounds[i] {
+				i++
+			}
+			if i == len(TTLBucketBounds) {
+				hist.Longer++
+			} else {
+				hist.Buckets[i]++
+			}
+		}
+	}
+	return hist, nil
+}
+
+// FullnessCrossings возвращает число пересечений каждого порога заполненности
+// (см. WithFullnessThresholds) по значению порога в процентах.
+func (c *LRUCache) FullnessCrossings() map[int]uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	crossings := make(map[int]uint64, len(c.fullness))
+	for i, threshold := range c.fullness {
+		crossings[threshold] = c.fullnessCrossings[i]
+	}
+	return crossings
+}
+
+// EvictionStats возвращает статистику вытеснения элементов по ёмкости и бюджету памяти.
+func (c *LRUCache) EvictionStats() EvictionStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.evictionStats
+}
+
+// expired сообщает, истёк ли срок жизни узла к моменту now. Бессрочные узлы не истекают.
+func (n *Node) expired(now time.Time) bool {
+	return !n.TTL.IsZero() && now.After(n.TTL)
+}
+
+// touch запоминает now как время последнего обращения к узлу. Вызывается и под
+// блокировкой на чтение, поэтому значение хранится атомарно.
+func (n *Node) touch(now time.Time) {
+	n.accessedAt.Store(now.UnixNano())
+}
+
+// lastAccess возвращает время последнего чтения или записи узла. В отличие от
+// promotedAt, оно обновляется и при чтении элемента, который не нужно перемещать.
+func (n *Node) lastAccess() time.Time {
+	return time.Unix(0, n.accessedAt.Load())
+}
+
+// removable сообщает, можно ли удалить истёкший узел к моменту now: узел хранится
+// ещё staleWindow после истечения, чтобы его значение можно было прочитать через GetStale.
+func (c *LRUCache) removable(node *Node, now time.Time) bool {
+	return node.expired(now.Add(-c.staleWindow))
+}
+
+// getTTL возвращает TTL для элемента с ключом key. Если TTL равен 0 и не задан явно
+// (explicit), используется TTL первого подходящего шаблона WithTTLOverride, а при его
+// отсутствии — значение по умолчанию. К результату применяется разброс WithTTLJitter,
+// после чего положительный TTL ограничивается снизу значением WithMinTTL и сверху —
+// значением WithMaxTTL; clamped сообщает, что запрошенный TTL или TTL по умолчанию
+// превышал максимальное ограничение либо превысил его после разброса.
+func (c *LRUCache) getTTL(key string, ttl time.Duration, explicit bool) (lifetime time.Duration, clamped bool) {
+	if ttl == 0 && !explicit {
+		ttl = c.defaultTTLFor(key)
+		if ttl == 0 && c.maxTTL > 0 {
+			// Элемент без срока жизни истекает через maxTTL
+			return c.maxTTL, true
+		}
+	}
+	clamped = c.maxTTL > 0 && ttl > c.maxTTL
+	lifetime = c.jitterTTL(ttl)
+	if lifetime > 0 && lifetime < c.minTTL {
+		lifetime = c.minTTL
+	}
+	if c.maxTTL > 0 && lifetime > c.maxTTL {
+		lifetime = c.maxTTL
+		clamped = true
+	}
+	return lifetime, clamped
+}
+
+// defaultTTLFor возвращает TTL по умолчанию для ключа с учётом шаблонов WithTTLOverride.
+func (c *LRUCache) defaultTTLFor(key string) time.Duration {
+	for _, override := range c.ttlOverrides {
+		if matchKeyPattern(c.normalizeKey(override.pattern), key) {
+			return override.ttl
+		}
+	}
+	return c.defaultTTL
+}
+
+// matchKeyPattern сообщает, соответствует ли ключ шаблону, в котором «*» обозначает
+// любую, в том числе пустую, последовательность символов. Остальные символы, включая «/»,
+// сравниваются буквально.
+func matchKeyPattern(pattern, key string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == key
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	return strings.HasSuffix(key, parts[len(parts)-1])
+}
+
+// jitterTTL случайно изменяет ненулевой TTL в пределах ±ttlJitter от его значения.
+func (c *LRUCache) jitterTTL(ttl time.Duration) time.Duration {
+	if c.ttlJitter == 0 || ttl <= 0 {
+		return ttl
+	}
+	delta := float64(ttl) * c.ttlJitter * (2*rand.Float64() - 1)
+	return ttl + time.Duration(delta)
+}
+
+// sizeOf оценивает размер элемента в байтах как сумму длины ключа и размера значения.
+// Для строк, срезов байт и сжатых значений учитывается их длина, для чисел и логических значений — 8 байт,
+// для остальных значений — длина JSON-представления.
+func sizeOf(key string, value interface{}) int64 {
+	size := int64(len(key))
+	switch v := value.(type) {
+	case nil:
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	case json.RawMessage:
+		size += int64(len(v))
+	case compressedValue:
+		size += int64(len(v.data))
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		size += 8
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			size += int64(len(data))
+		}
 	}
-	return ttl
+	return size
 }
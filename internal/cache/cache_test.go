@@ -1,12 +1,46 @@
 package cache
 
 import (
+	"bytes"
+	"cache_service/config"
 	"context"
 	"errors"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
+// fakeClock — управляемый вручную источник времени для детерминированных тестов TTL.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newFakeClock создаёт источник времени, остановленный на фиксированном моменте.
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Now возвращает текущее время источника.
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance продвигает время источника на d.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
 func TestLRUCache_PutAndGet(t *testing.T) {
 	c := NewLRUCache(2, 1*time.Minute)
 
@@ -40,7 +74,8 @@ func TestLRUCache_PutAndGet(t *testing.T) {
 }
 
 func TestLRUCache_KeyExpired(t *testing.T) {
-	c := NewLRUCache(1, 1*time.Millisecond)
+	clock := newFakeClock()
+	c := NewLRUCache(1, 1*time.Millisecond, WithClock(clock.Now))
 
 	// Добавляем элемент
 	err := c.Put(context.Background(), "key1", "value1", 0)
@@ -48,8 +83,14 @@ func TestLRUCache_KeyExpired(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Ждём, чтобы TTL истёк
-	time.Sleep(2 * time.Millisecond)
+	// Ровно в момент истечения элемент ещё актуален
+	clock.Advance(time.Millisecond)
+	if _, _, err := c.Get(context.Background(), "key1"); err != nil {
+		t.Fatalf("expected entry to be live at its expiry time, got %v", err)
+	}
+
+	// Продвигаем время за пределы TTL без ожидания
+	clock.Advance(time.Nanosecond)
 
 	// Проверяем истечение
 	_, _, err = c.Get(context.Background(), "key1")
@@ -80,12 +121,13 @@ func TestLRUCache_EvictAll(t *testing.T) {
 }
 
 func TestLRUCache_GetAll_RemoveExpired(t *testing.T) {
-	cache := NewLRUCache(3, 1*time.Second)
+	clock := newFakeClock()
+	cache := NewLRUCache(3, 1*time.Second, WithClock(clock.Now))
 
 	_ = cache.Put(context.Background(), "key1", "value1", 500*time.Millisecond)
 	_ = cache.Put(context.Background(), "key2", "value2", 2*time.Second)
 
-	time.Sleep(1 * time.Second)
+	clock.Advance(1 * time.Second)
 
 	keys, _, err := cache.GetAll(context.Background())
 	if err != nil {
@@ -96,3 +138,2289 @@ func TestLRUCache_GetAll_RemoveExpired(t *testing.T) {
 		t.Errorf("expected 1 valid key (key2), got keys=%v", keys)
 	}
 }
+
+func TestLRUCache_OnEvictDirtyOnly(t *testing.T) {
+	var evictedKeys []string
+	c := NewLRUCache(2, 1*time.Minute, WithOnEvict(func(key string, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}))
+
+	_, _, _ = c.PutWithOptions(context.Background(), "dirty", "value1", PutOptions{Dirty: true})
+	_ = c.Put(context.Background(), "clean", "value2", 0)
+
+	// Вытесняем оба элемента по ёмкости
+	_ = c.Put(context.Background(), "key3", "value3", 0)
+	_ = c.Put(context.Background(), "key4", "value4", 0)
+
+	if len(evictedKeys) != 1 || evictedKeys[0] != "dirty" {
+		t.Errorf("expected callback only for dirty key, got %v", evictedKeys)
+	}
+}
+
+func TestLRUCache_OnEvictDirtyExpired(t *testing.T) {
+	var evictedKeys []string
+	c := NewLRUCache(3, 1*time.Minute, WithOnEvict(func(key string, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}))
+
+	_, _, _ = c.PutWithOptions(context.Background(), "dirty", "value1", PutOptions{TTL: time.Millisecond, Dirty: true})
+	_ = c.Put(context.Background(), "clean", "value2", time.Millisecond)
+
+	time.Sleep(2 * time.Millisecond)
+
+	_, _, err := c.Get(context.Background(), "dirty")
+	if !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected errExpiredKey, got %v", err)
+	}
+	_, _, _ = c.Get(context.Background(), "clean")
+
+	if len(evictedKeys) != 1 || evictedKeys[0] != "dirty" {
+		t.Errorf("expected callback only for dirty key, got %v", evictedKeys)
+	}
+}
+
+func TestLRUCache_GetPromotes(t *testing.T) {
+	c := NewLRUCache(2, 1*time.Minute)
+
+	_ = c.Put(context.Background(), "key1", "value1", 0)
+	_ = c.Put(context.Background(), "key2", "value2", 0)
+
+	// Чтение перемещает key1 в начало, поэтому вытесняется key2
+	_, _, _ = c.Get(context.Background(), "key1")
+	_ = c.Put(context.Background(), "key3", "value3", 0)
+
+	if _, _, err := c.Get(context.Background(), "key1"); err != nil {
+		t.Errorf("expected key1 to survive, got %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "key2"); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected key2 to be evicted, got %v", err)
+	}
+}
+
+func TestLRUCache_PromotionThrottle(t *testing.T) {
+	c := NewLRUCache(2, 1*time.Minute, WithPromotionThrottle(time.Minute))
+
+	_ = c.Put(context.Background(), "key1", "value1", 0)
+	_ = c.Put(context.Background(), "key2", "value2", 0)
+
+	// key1 только что был помещён в начало списка, поэтому чтение его не перемещает
+	_, _, _ = c.Get(context.Background(), "key1")
+	if c.tail.key != "key1" {
+		t.Fatalf("expected key1 to stay at tail, got %s", c.tail.key)
+	}
+
+	_ = c.Put(context.Background(), "key3", "value3", 0)
+	if _, _, err := c.Get(context.Background(), "key1"); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected key1 to be evicted, got %v", err)
+	}
+}
+
+func TestLRUCache_GetOrComputeSingleFlight(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "computed", nil
+	}
+
+	const goroutines = 50
+	var wg sync.WaitGroup
+	results := make([]interface{}, goroutines)
+	errs := make([]error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.GetOrCompute(context.Background(), "key1", 0, loader)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("expected loader to run once, ran %d times", n)
+	}
+	for i := 0; i < goroutines; i++ {
+		if errs[i] != nil || results[i] != "computed" {
+			t.Errorf("goroutine %d: expected computed, got %v (err %v)", i, results[i], errs[i])
+		}
+	}
+
+	// Значение сохранено в кеш
+	val, _, err := c.Get(context.Background(), "key1")
+	if err != nil || val != "computed" {
+		t.Errorf("expected cached value, got %v (err %v)", val, err)
+	}
+}
+
+func TestLRUCache_GetOrComputeLoaderError(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	errLoad := errors.New("load failed")
+
+	_, err := c.GetOrCompute(context.Background(), "key1", 0, func(ctx context.Context) (interface{}, error) {
+		return nil, errLoad
+	})
+	if !errors.Is(err, errLoad) {
+		t.Errorf("expected loader error, got %v", err)
+	}
+
+	_, _, err = c.Get(context.Background(), "key1")
+	if !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected errKeyNotFound, got %v", err)
+	}
+}
+
+func TestLRUCache_GetOrComputeLoaderPanic(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	var loads atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicking := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		close(started)
+		<-release
+		panic("source exploded")
+	}
+
+	leaderPanic := make(chan interface{}, 1)
+	go func() {
+		defer func() { leaderPanic <- recover() }()
+		_, _ = c.GetOrCompute(context.Background(), "key1", 0, panicking)
+	}()
+	<-started
+
+	// Ожидающий вызов присоединяется к загрузке и получает панику вместо вечного ожидания
+	waiterPanic := make(chan interface{}, 1)
+	go func() {
+		defer func() { waiterPanic <- recover() }()
+		_, _ = c.GetOrCompute(context.Background(), "key1", 0, func(ctx context.Context) (interface{}, error) {
+			loads.Add(1)
+			return "unexpected", nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if rec := <-leaderPanic; rec != "source exploded" {
+		t.Errorf("expected leader to re-panic with the loader value, got %v", rec)
+	}
+	select {
+	case rec := <-waiterPanic:
+		if err, ok := rec.(error); !ok || !strings.Contains(err.Error(), "source exploded") {
+			t.Errorf("expected waiter to panic with the loader panic, got %v", rec)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to be released after the loader panicked")
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("expected loader to run once, ran %d times", n)
+	}
+
+	// Ключ не заблокирован: следующий промах снова вызывает загрузчик
+	value, err := c.GetOrCompute(context.Background(), "key1", 0, func(ctx context.Context) (interface{}, error) {
+		return "recovered", nil
+	})
+	if err != nil || value != "recovered" {
+		t.Errorf("expected a fresh load after the panic, got %v (err %v)", value, err)
+	}
+}
+
+func TestLRUCache_GetOrComputeLoaderGoexit(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	defer c.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leaderDone := make(chan interface{}, 1)
+	go func() {
+		// Goexit выполняет отложенные вызовы, но recover в них возвращает nil
+		defer func() { leaderDone <- recover() }()
+		_, _ = c.GetOrCompute(context.Background(), "key1", 0, func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			runtime.Goexit()
+			return nil, nil
+		})
+	}()
+	<-started
+
+	// Ожидающий вызов получает ошибку, а не панику
+	type result struct {
+		err error
+		rec interface{}
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		var err error
+		defer func() { waiter <- result{err: err, rec: recover()} }()
+		_, err = c.GetOrCompute(context.Background(), "key1", 0, func(ctx context.Context) (interface{}, error) {
+			return "unexpected", nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if rec := <-leaderDone; rec != nil {
+		t.Errorf("expected leader to exit without a panic, got %v", rec)
+	}
+	select {
+	case res := <-waiter:
+		if res.rec != nil {
+			t.Errorf("expected waiter not to panic, got %v", res.rec)
+		}
+		if !errors.Is(res.err, errLoaderGoexit) {
+			t.Errorf("expected errLoaderGoexit, got %v", res.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to be released after the loader exited")
+	}
+
+	// Ключ не заблокирован: следующий промах снова вызывает загрузчик
+	value, err := c.GetOrCompute(context.Background(), "key1", 0, func(ctx context.Context) (interface{}, error) {
+		return "recovered", nil
+	})
+	if err != nil || value != "recovered" {
+		t.Errorf("expected a fresh load after Goexit, got %v (err %v)", value, err)
+	}
+}
+
+func TestLRUCache_GetOrComputeLeaderCancelled(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return "computed", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		_, _ = c.GetOrCompute(ctx, "key1", 0, loader)
+	}()
+	<-started
+
+	waiterResult := make(chan interface{}, 1)
+	go func() {
+		value, _ := c.GetOrCompute(context.Background(), "key1", 0, loader)
+		waiterResult <- value
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Отмена инициатора не прерывает загрузку для остальных ожидающих
+	cancel()
+	close(release)
+	<-leaderDone
+	if value := <-waiterResult; value != "computed" {
+		t.Errorf("expected waiter to get the loaded value, got %v", value)
+	}
+	if value, _, err := c.Get(context.Background(), "key1"); err != nil || value != "computed" {
+		t.Errorf("expected value to be cached, got %v (err %v)", value, err)
+	}
+}
+
+func TestLRUCache_PutNegative(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute, WithNegativeTTL(50*time.Millisecond))
+
+	if err := c.PutNegative(context.Background(), "missing", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	val, expiresAt, err := c.Get(context.Background(), "missing")
+	if !errors.Is(err, ErrNegativeCached) {
+		t.Errorf("expected ErrNegativeCached, got %v", err)
+	}
+	if val != nil {
+		t.Errorf("expected nil value, got %v", val)
+	}
+	if time.Until(expiresAt) > 50*time.Millisecond {
+		t.Errorf("expected negative TTL to be used, expires at %v", expiresAt)
+	}
+
+	// Отрицательные записи не попадают в GetAll
+	_ = c.Put(context.Background(), "key1", "value1", 0)
+	keys, _, err := c.GetAll(context.Background())
+	if err != nil || len(keys) != 1 || keys[0] != "key1" {
+		t.Errorf("expected only key1 in GetAll, got %v (err %v)", keys, err)
+	}
+
+	// После истечения TTL отрицательная запись удаляется
+	time.Sleep(60 * time.Millisecond)
+	_, _, err = c.Get(context.Background(), "missing")
+	if !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected errExpiredKey, got %v", err)
+	}
+}
+
+func TestLRUCache_PutWithOptionsCreated(t *testing.T) {
+	c := NewLRUCache(2, 1*time.Minute)
+
+	_, created, err := c.PutWithOptions(context.Background(), "key1", "value1", PutOptions{})
+	if err != nil || !created {
+		t.Errorf("expected created=true, got %v (err %v)", created, err)
+	}
+
+	_, created, err = c.PutWithOptions(context.Background(), "key1", "value2", PutOptions{})
+	if err != nil || created {
+		t.Errorf("expected created=false, got %v (err %v)", created, err)
+	}
+}
+
+func TestLRUCache_Items(t *testing.T) {
+	c := NewLRUCache(3, 1*time.Minute)
+
+	_ = c.Put(context.Background(), "key1", "value1", 0)
+	_ = c.Put(context.Background(), "expired", "value2", time.Millisecond)
+	_ = c.Put(context.Background(), "key3", "value3", 0)
+	time.Sleep(2 * time.Millisecond)
+
+	items, err := c.Items(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].Key != "key3" || items[1].Key != "key1" {
+		t.Errorf("expected [key3 key1], got %+v", items)
+	}
+}
+
+func TestLRUCache_Range(t *testing.T) {
+	c := NewLRUCache(5, 1*time.Minute)
+	_ = c.Put(context.Background(), "key1", "value1", 0)
+	_ = c.Put(context.Background(), "expired", "value2", time.Millisecond)
+	_ = c.Put(context.Background(), "key3", "value3", 0)
+	_ = c.PutNegative(context.Background(), "missing", 0)
+	_ = c.Put(context.Background(), "key5", "value5", 0)
+	time.Sleep(2 * time.Millisecond)
+
+	// Обходятся все актуальные элементы в порядке списка, истёкшие и отрицательные пропускаются
+	var keys []string
+	err := c.Range(context.Background(), func(key string, value interface{}, expiresAt time.Time) bool {
+		if value != "value"+key[len(key)-1:] || expiresAt.IsZero() {
+			t.Errorf("unexpected entry %s=%v expiring at %v", key, value, expiresAt)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(keys, ",") != "key5,key3,key1" {
+		t.Errorf("expected [key5 key3 key1], got %v", keys)
+	}
+}
+
+func TestLRUCache_RangeStop(t *testing.T) {
+	c := NewLRUCache(5, 1*time.Minute)
+	for i := 0; i < 5; i++ {
+		_ = c.Put(context.Background(), "key"+strconv.Itoa(i), i, 0)
+	}
+
+	// Обход прекращается, как только fn возвращает false
+	visited := 0
+	err := c.Range(context.Background(), func(string, interface{}, time.Time) bool {
+		visited++
+		return visited < 2
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if visited != 2 {
+		t.Errorf("expected 2 visited entries, got %d", visited)
+	}
+
+	// После обхода кеш доступен для записи
+	if err := c.Put(context.Background(), "after", "value", 0); err != nil {
+		t.Errorf("unexpected error after range: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Range(ctx, func(string, interface{}, time.Time) bool { return true }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestLRUCache_FindByValue(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now))
+	_ = c.Put(context.Background(), "a", "target", 0)
+	_ = c.Put(context.Background(), "b", "other", 0)
+	_ = c.Put(context.Background(), "c", "target", 0)
+	_ = c.Put(context.Background(), "expired", "target", time.Second)
+	clock.Advance(2 * time.Second)
+
+	// Находятся только актуальные элементы с точно совпадающим значением
+	keys, err := c.FindByValue(context.Background(), func(v interface{}) bool { return v == "target" })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(keys, ","); got != "c,a" {
+		t.Errorf("expected keys c,a in LRU order, got %q", got)
+	}
+
+	// Поиск не меняет порядок элементов
+	items, _ := c.Items(context.Background())
+	if items[0].Key != "c" || items[len(items)-1].Key != "a" {
+		t.Errorf("expected LRU order to be unchanged, got %+v", items)
+	}
+}
+
+func TestLRUCache_Tags(t *testing.T) {
+	c := NewLRUCache(10, time.Minute)
+	tags := Tags{"source": "db", "cache-control": "no-transform"}
+	put, _, err := c.PutWithOptions(context.Background(), "a", "value", PutOptions{Tags: tags})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Кеш хранит копию тегов: изменение исходной и возвращённых карт не затрагивает элемент
+	tags["source"] = "changed"
+	put.Tags["source"] = "changed"
+	item, err := c.GetItem(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if item.Value != "value" || item.Tags["source"] != "db" || item.Tags["cache-control"] != "no-transform" {
+		t.Errorf("expected tags to round-trip without affecting the value, got %+v", item)
+	}
+	item.Tags["source"] = "changed"
+	if item, _ := c.GetItem(context.Background(), "a"); item.Tags["source"] != "db" {
+		t.Errorf("expected returned tags to be a copy, got %v", item.Tags)
+	}
+
+	// Запись без тегов удаляет прежние теги
+	_ = c.Put(context.Background(), "a", "value", 0)
+	if item, _ := c.GetItem(context.Background(), "a"); item.Tags != nil {
+		t.Errorf("expected tags to be cleared on overwrite, got %v", item.Tags)
+	}
+}
+
+func TestLRUCache_GetByTag(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now))
+	put := func(key, source string, ttl time.Duration) {
+		_, _, _ = c.PutWithOptions(context.Background(), key, key, PutOptions{TTL: ttl, Tags: Tags{"source": source}})
+	}
+	put("a", "db", 0)
+	put("b", "api", 0)
+	put("c", "db", 0)
+	put("expired", "db", time.Second)
+	_ = c.Put(context.Background(), "untagged", "value", 0)
+	clock.Advance(2 * time.Second)
+
+	items, err := c.GetByTag(context.Background(), "source", "db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].Key != "c" || items[1].Key != "a" {
+		t.Errorf("expected live entries c, a tagged source=db, got %+v", items)
+	}
+	if items, _ := c.GetByTag(context.Background(), "source", "cdn"); len(items) != 0 {
+		t.Errorf("expected no entries for an unknown tag value, got %+v", items)
+	}
+}
+
+func BenchmarkLRUCache_GetParallel(b *testing.B) {
+	const keys = 1024
+	c := NewLRUCache(keys, 1*time.Minute)
+	for i := 0; i < keys; i++ {
+		_ = c.Put(context.Background(), strconv.Itoa(i), i, 0)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _, _ = c.Get(context.Background(), strconv.Itoa(i%keys))
+			i++
+		}
+	})
+}
+
+// BenchmarkLRUCache_PutGetModes сравнивает запись и чтение в режиме LRU и в неограниченном
+// режиме без перестроения списка.
+func BenchmarkLRUCache_PutGetModes(b *testing.B) {
+	const keys = 1024
+	for _, mode := range []struct {
+		name     string
+		capacity int
+	}{
+		{"LRU", keys},
+		{"Unbounded", 0},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			c := NewLRUCache(mode.capacity, time.Minute)
+			for i := 0; i < keys; i++ {
+				_ = c.Put(context.Background(), strconv.Itoa(i), i, 0)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := strconv.Itoa(i % keys)
+				_ = c.Put(context.Background(), key, i, 0)
+				_, _, _ = c.Get(context.Background(), strconv.Itoa((i*7)%keys))
+			}
+		})
+	}
+}
+
+// BenchmarkLRUCache_LongKeysMemory оценивает память на элемент для длинных ключей,
+// вырезанных из больших буферов (например, тел запросов).
+func BenchmarkLRUCache_LongKeysMemory(b *testing.B) {
+	const entries = 1000
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		c := NewLRUCache(entries, time.Minute)
+		for j := 0; j < entries; j++ {
+			body := strconv.Itoa(j) + strings.Repeat("k", 16<<10)
+			_ = c.Put(context.Background(), body[:1<<10], j, 0)
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/entries, "B/entry")
+		runtime.KeepAlive(c)
+	}
+}
+
+// checkListInvariants проверяет согласованность карты и двусвязных списков кеша.
+func checkListInvariants(t *testing.T, c *LRUCache) {
+	t.Helper()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	count, protected := 0, 0
+	segments := []struct {
+		head, tail *Node
+		protected  bool
+	}{
+		{c.head, c.tail, false},
+		{c.protectedHead, c.protectedTail, true},
+	}
+	for _, seg := range segments {
+		var prev *Node
+		for node := seg.head; node != nil; node = node.next {
+			if node.prev != prev {
+				t.Fatalf("broken prev link at key %s", node.key)
+			}
+			if c.cache[node.key] != node {
+				t.Fatalf("list node %s is not in the map", node.key)
+			}
+			if node.protected != seg.protected {
+				t.Fatalf("node %s is in the wrong segment", node.key)
+			}
+			if seg.protected {
+				protected++
+			}
+			prev = node
+			count++
+		}
+		if prev != seg.tail {
+			t.Fatal("tail does not match the last list node")
+		}
+	}
+	if protected != c.protectedLen {
+		t.Fatalf("protected segment has %d nodes, counter is %d", protected, c.protectedLen)
+	}
+	if count != len(c.cache) {
+		t.Fatalf("list has %d nodes, map has %d entries", count, len(c.cache))
+	}
+	if !c.unbounded() && count > c.capacity {
+		t.Fatalf("cache holds %d entries, capacity is %d", count, c.capacity)
+	}
+}
+
+func TestLRUCache_ConcurrentAccess(t *testing.T) {
+	c := NewLRUCache(16, 1*time.Minute)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := strconv.Itoa((g*31 + i) % 32)
+				switch i % 5 {
+				case 0:
+					_ = c.Put(context.Background(), key, i, 0)
+				case 1:
+					_, _ = c.Evict(context.Background(), key)
+				default:
+					if val, _, err := c.Get(context.Background(), key); err == nil && val == nil {
+						t.Errorf("unexpected nil value for key %s", key)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	checkListInvariants(t, c)
+}
+
+func TestLRUCache_SegmentedLRUScanResistance(t *testing.T) {
+	// Горячие ключи читаются повторно, затем выполняется сканирование однократно записанных ключей
+	run := func(opts ...Option) (survived int) {
+		c := NewLRUCache(10, 1*time.Minute, opts...)
+		for i := 0; i < 5; i++ {
+			_ = c.Put(context.Background(), "hot"+strconv.Itoa(i), i, 0)
+			_, _, _ = c.Get(context.Background(), "hot"+strconv.Itoa(i))
+		}
+		for i := 0; i < 20; i++ {
+			_ = c.Put(context.Background(), "scan"+strconv.Itoa(i), i, 0)
+		}
+		checkListInvariants(t, c)
+		for i := 0; i < 5; i++ {
+			if _, _, err := c.Get(context.Background(), "hot"+strconv.Itoa(i)); err == nil {
+				survived++
+			}
+		}
+		return survived
+	}
+
+	if n := run(); n != 0 {
+		t.Errorf("expected plain LRU to lose hot keys, %d survived", n)
+	}
+	if n := run(WithSegmentedLRU(0.5)); n != 5 {
+		t.Errorf("expected SLRU to keep all hot keys, %d survived", n)
+	}
+}
+
+func TestLRUCache_SegmentedLRUDemotion(t *testing.T) {
+	c := NewLRUCache(4, 1*time.Minute, WithSegmentedLRU(0.5))
+
+	// Защищённый сегмент вмещает два элемента; третий повторно прочитанный вытесняет key1 в испытательный
+	for _, key := range []string{"key1", "key2", "key3"} {
+		_ = c.Put(context.Background(), key, key, 0)
+		_, _, _ = c.Get(context.Background(), key)
+	}
+	_ = c.Put(context.Background(), "key4", "key4", 0)
+	checkListInvariants(t, c)
+
+	if c.protectedLen != 2 {
+		t.Fatalf("expected 2 protected entries, got %d", c.protectedLen)
+	}
+	items, _ := c.Items(context.Background())
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	if got := strings.Join(keys, ","); got != "key3,key2,key4,key1" {
+		t.Errorf("unexpected order %s", got)
+	}
+
+	// Новый элемент вытесняет давно использованный элемент испытательного сегмента
+	_ = c.Put(context.Background(), "key5", "key5", 0)
+	if _, _, err := c.Get(context.Background(), "key1"); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected key1 to be evicted, got %v", err)
+	}
+}
+
+func TestLRUCache_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	c := NewLRUCache(10, 10*time.Millisecond, WithCleanupInterval(5*time.Millisecond))
+
+	// Горутина очистки удаляет истёкший элемент без обращений к нему
+	_ = c.Put(context.Background(), "key1", "value1", 0)
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c.Len() != 0 {
+		t.Fatal("expected janitor to remove expired entry")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %v", err)
+	}
+
+	// Горутина очистки завершена
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected janitor goroutine to stop, have %d goroutines, had %d", n, before)
+	}
+
+	if err := c.Put(context.Background(), "key2", "value2", 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Put, got %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "key2"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Get, got %v", err)
+	}
+}
+
+func TestLRUCache_PutExplicitTTL(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	// Явный нулевой TTL не заменяется значением по умолчанию
+	_, _, _ = c.PutWithOptions(context.Background(), "zero", "value", PutOptions{ExplicitTTL: true})
+	time.Sleep(time.Millisecond)
+	if _, _, err := c.Get(context.Background(), "zero"); !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected errExpiredKey, got %v", err)
+	}
+
+	_, _, _ = c.PutWithOptions(context.Background(), "default", "value", PutOptions{})
+	if _, expiresAt, _ := c.Get(context.Background(), "default"); time.Until(expiresAt) < 59*time.Second {
+		t.Errorf("expected default TTL, got %s", time.Until(expiresAt))
+	}
+}
+
+func TestLRUCache_NoDefaultTTL(t *testing.T) {
+	c := NewLRUCache(10, 0)
+
+	// Без TTL по умолчанию элемент без явного TTL не истекает
+	_ = c.Put(context.Background(), "forever", "value", 0)
+	_ = c.Put(context.Background(), "short", "value", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	value, expiresAt, err := c.Get(context.Background(), "forever")
+	if err != nil || value != "value" {
+		t.Fatalf("expected entry to survive, got %v, %v", value, err)
+	}
+	if !expiresAt.IsZero() {
+		t.Errorf("expected zero expiry time, got %s", expiresAt)
+	}
+
+	// Явный TTL по-прежнему соблюдается
+	if _, _, err := c.Get(context.Background(), "short"); !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected errExpiredKey, got %v", err)
+	}
+	if keys, _, _ := c.GetAll(context.Background()); len(keys) != 1 || keys[0] != "forever" {
+		t.Errorf("expected only the non-expiring key, got %v", keys)
+	}
+}
+
+func TestLRUCache_SlidingTTL(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	ttl := 50 * time.Millisecond
+
+	_, _, _ = c.PutWithOptions(context.Background(), "session", "value", PutOptions{TTL: ttl, Sliding: true})
+	_, _, _ = c.PutWithOptions(context.Background(), "idle", "value", PutOptions{TTL: ttl, Sliding: true})
+
+	// Регулярные чтения продлевают жизнь элемента далеко за исходный TTL
+	for i := 0; i < 8; i++ {
+		time.Sleep(ttl / 2)
+		if _, _, err := c.Get(context.Background(), "session"); err != nil {
+			t.Fatalf("expected sliding entry to survive after %s, got %v", time.Duration(i+1)*ttl/2, err)
+		}
+	}
+
+	// Элемент без обращений истекает по исходному TTL
+	if _, _, err := c.Get(context.Background(), "idle"); !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected idle entry to expire, got %v", err)
+	}
+}
+
+func TestLRUCache_Recent(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		_ = c.Put(context.Background(), key, key, 0)
+	}
+	// Чтение перемещает a в начало списка
+	_, _, _ = c.Get(context.Background(), "a")
+
+	keysOf := func(items []Item) string {
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, item.Key)
+		}
+		return strings.Join(keys, ",")
+	}
+
+	items, err := c.Recent(context.Background(), 2, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := keysOf(items); got != "a,d" {
+		t.Errorf("expected a,d, got %s", got)
+	}
+
+	// n больше числа элементов возвращает все элементы
+	items, _ = c.Recent(context.Background(), 100, "")
+	if got := keysOf(items); got != "a,d,c,b" {
+		t.Errorf("expected a,d,c,b, got %s", got)
+	}
+
+	_ = c.Put(context.Background(), "x:1", 1, 0)
+	items, _ = c.Recent(context.Background(), 100, "x:")
+	if got := keysOf(items); got != "x:1" {
+		t.Errorf("expected only prefixed keys, got %s", got)
+	}
+}
+
+func TestLRUCache_Least(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	_ = c.Put(context.Background(), "expired", "value", time.Millisecond)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		_ = c.Put(context.Background(), key, key, 0)
+	}
+	// Чтение перемещает a в начало списка
+	_, _, _ = c.Get(context.Background(), "a")
+	time.Sleep(5 * time.Millisecond)
+
+	items, err := c.Least(context.Background(), 3, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	if got := strings.Join(keys, ","); got != "b,c,d" {
+		t.Errorf("expected b,c,d in reverse recency order, got %s", got)
+	}
+
+	// Истёкший элемент в конце списка удалён
+	if c.Len() != 4 {
+		t.Errorf("expected expired tail entry to be purged, have %d entries", c.Len())
+	}
+	checkListInvariants(t, c)
+}
+
+func TestLRUCache_Cost(t *testing.T) {
+	c := NewLRUCache(10, time.Minute)
+	put := func(key string, cost int64) {
+		t.Helper()
+		if _, _, err := c.PutWithOptions(context.Background(), key, "v", PutOptions{Cost: cost}); err != nil {
+			t.Fatalf("unexpected error for %s: %v", key, err)
+		}
+		if used := c.UsedCost(); used > 10 {
+			t.Fatalf("expected total cost within capacity after %s, got %d", key, used)
+		}
+	}
+
+	// Стоимость по умолчанию равна 1, и ёмкость ограничивает число элементов
+	for i := 0; i < 4; i++ {
+		put("cheap"+strconv.Itoa(i), 0)
+	}
+	put("mid", 3)
+	if used := c.UsedCost(); used != 7 {
+		t.Fatalf("expected total cost 7, got %d", used)
+	}
+
+	// Дорогой элемент вытесняет давно использованные, пока суммарная стоимость не уложится в ёмкость
+	put("expensive", 6)
+	if used := c.UsedCost(); used != 10 {
+		t.Errorf("expected total cost 10, got %d", used)
+	}
+	for _, key := range []string{"cheap0", "cheap1", "cheap2"} {
+		if _, _, err := c.Get(context.Background(), key); !errors.Is(err, errKeyNotFound) {
+			t.Errorf("expected %s to be evicted, got %v", key, err)
+		}
+	}
+	for _, key := range []string{"cheap3", "mid", "expensive"} {
+		if _, _, err := c.Get(context.Background(), key); err != nil {
+			t.Errorf("expected %s to survive, got %v", key, err)
+		}
+	}
+
+	// Обновление элемента учитывает новую стоимость
+	put("expensive", 1)
+	if used := c.UsedCost(); used != 5 {
+		t.Errorf("expected total cost 5 after update, got %d", used)
+	}
+	_, _ = c.Evict(context.Background(), "mid")
+	if used := c.UsedCost(); used != 2 {
+		t.Errorf("expected total cost 2 after delete, got %d", used)
+	}
+
+	// Элемент дороже ёмкости и отрицательная стоимость отклоняются
+	if _, _, err := c.PutWithOptions(context.Background(), "huge", "v", PutOptions{Cost: 11}); !errors.Is(err, errCostTooLarge) {
+		t.Errorf("expected errCostTooLarge, got %v", err)
+	}
+	if _, _, err := c.PutWithOptions(context.Background(), "bad", "v", PutOptions{Cost: -1}); !errors.Is(err, errNegativeCost) {
+		t.Errorf("expected errNegativeCost, got %v", err)
+	}
+}
+
+func TestLRUCache_MaxBytesBatchEviction(t *testing.T) {
+	c := NewLRUCache(100, 1*time.Minute, WithMaxBytes(100))
+
+	// Пять элементов по 15 байт: ключ 2 байта и значение 13 байт
+	for i := 0; i < 5; i++ {
+		_ = c.Put(context.Background(), "k"+strconv.Itoa(i), "thirteen-char", 0)
+	}
+	if used := c.UsedBytes(); used != 75 {
+		t.Fatalf("expected 75 used bytes, got %d", used)
+	}
+
+	// Большое значение требует вытеснения нескольких элементов за один проход
+	large := strings.Repeat("x", 58)
+	if err := c.Put(context.Background(), "big", large, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := c.EvictionStats()
+	if stats.Passes != 1 || stats.LastPassEvicted != 3 || stats.Evicted != 3 {
+		t.Errorf("expected one pass evicting 3 entries, got %+v", stats)
+	}
+	if used := c.UsedBytes(); used > 100 {
+		t.Errorf("expected used bytes within budget, got %d", used)
+	}
+	for _, key := range []string{"k0", "k1", "k2"} {
+		if _, _, err := c.Get(context.Background(), key); !errors.Is(err, errKeyNotFound) {
+			t.Errorf("expected %s to be evicted, got %v", key, err)
+		}
+	}
+	for _, key := range []string{"k3", "k4", "big"} {
+		if _, _, err := c.Get(context.Background(), key); err != nil {
+			t.Errorf("expected %s to survive, got %v", key, err)
+		}
+	}
+
+	// Элемент больше всего бюджета отклоняется
+	if err := c.Put(context.Background(), "huge", strings.Repeat("x", 200), 0); !errors.Is(err, errTooLarge) {
+		t.Errorf("expected errTooLarge, got %v", err)
+	}
+}
+
+func TestLRUCache_PutIfVersion(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	item, _, err := c.PutWithOptions(context.Background(), "key1", "value1", PutOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Совпадающая версия
+	updated, _, err := c.PutWithOptions(context.Background(), "key1", "value2", PutOptions{IfVersion: item.Version})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Version == item.Version {
+		t.Error("expected version to change after update")
+	}
+
+	// Устаревшая версия
+	_, _, err = c.PutWithOptions(context.Background(), "key1", "value3", PutOptions{IfVersion: item.Version})
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	// Отсутствующий ключ
+	_, _, err = c.PutWithOptions(context.Background(), "missing", "value", PutOptions{IfVersion: 1})
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	current, err := c.GetItem(context.Background(), "key1")
+	if err != nil || current.Value != "value2" || current.Version != updated.Version {
+		t.Errorf("unexpected item %+v (err %v)", current, err)
+	}
+}
+
+func TestLRUCache_PutIfAbsent(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now))
+
+	item, created, err := c.PutWithOptions(context.Background(), "key1", "value1", PutOptions{IfAbsent: true})
+	if err != nil || !created {
+		t.Fatalf("expected key to be created, got %v, %v", created, err)
+	}
+
+	// Существующий ключ не перезаписывается, а возвращается его текущее значение
+	current, created, err := c.PutWithOptions(context.Background(), "key1", "value2", PutOptions{IfAbsent: true})
+	if !errors.Is(err, ErrKeyExists) || created {
+		t.Fatalf("expected ErrKeyExists, got %v, %v", created, err)
+	}
+	if current.Value != "value1" || current.Version != item.Version {
+		t.Errorf("expected current item value1 v%d, got %+v", item.Version, current)
+	}
+	if value, _, _ := c.Get(context.Background(), "key1"); value != "value1" {
+		t.Errorf("expected value to stay value1, got %v", value)
+	}
+
+	// Истёкший элемент и отрицательная запись считаются отсутствующими
+	clock.Advance(2 * time.Minute)
+	if _, _, err := c.PutWithOptions(context.Background(), "key1", "value3", PutOptions{IfAbsent: true}); err != nil {
+		t.Errorf("expected expired key to be overwritten, got %v", err)
+	}
+	_ = c.PutNegative(context.Background(), "negative", time.Minute)
+	if _, _, err := c.PutWithOptions(context.Background(), "negative", "value", PutOptions{IfAbsent: true}); err != nil {
+		t.Errorf("expected negative entry to be overwritten, got %v", err)
+	}
+}
+
+func TestLRUCache_TouchMany(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	_ = c.Put(context.Background(), "a", "a", 50*time.Millisecond)
+	_ = c.Put(context.Background(), "b", "b", 50*time.Millisecond)
+	_ = c.Put(context.Background(), "expired", "value", time.Millisecond)
+	_ = c.PutNegative(context.Background(), "negative", 50*time.Millisecond)
+	before, _ := c.GetItem(context.Background(), "a")
+	time.Sleep(5 * time.Millisecond)
+
+	touched, err := c.TouchMany(context.Background(), []string{"a", "b", "missing", "expired", "negative"}, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if touched != 2 {
+		t.Errorf("expected 2 touched keys, got %d", touched)
+	}
+	// Новый срок жизни меняет версию элемента
+	if after, _ := c.GetItem(context.Background(), "a"); after.Version <= before.Version {
+		t.Errorf("expected version to increase after touch, got %d then %d", before.Version, after.Version)
+	}
+
+	// Продлённые элементы переживают исходный TTL
+	time.Sleep(60 * time.Millisecond)
+	for _, key := range []string{"a", "b"} {
+		if _, expiresAt, err := c.Get(context.Background(), key); err != nil || time.Until(expiresAt) < 59*time.Minute {
+			t.Errorf("expected %s to be extended, got %s, %v", key, time.Until(expiresAt), err)
+		}
+	}
+	if _, _, err := c.Get(context.Background(), "missing"); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected missing key not to be created, got %v", err)
+	}
+	// Отрицательная запись не продлевается и истекает в исходный срок
+	if _, _, err := c.Get(context.Background(), "negative"); !errors.Is(err, errExpiredKey) && !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected negative entry to expire on schedule, got %v", err)
+	}
+
+	if _, err := c.TouchMany(context.Background(), []string{"a"}, -time.Second); !errors.Is(err, errNegativeTTL) {
+		t.Errorf("expected errNegativeTTL, got %v", err)
+	}
+}
+
+func TestLRUCache_TouchIfBelow(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now))
+	_ = c.Put(context.Background(), "key", "value", 10*time.Minute)
+	_, before, _ := c.Get(context.Background(), "key")
+
+	// Осталось больше порога: срок жизни не меняется
+	extended, err := c.TouchIfBelow(context.Background(), "key", 5*time.Minute, time.Hour)
+	if err != nil || extended {
+		t.Fatalf("expected no-op above the threshold, got %v, %v", extended, err)
+	}
+	if _, expiresAt, _ := c.Get(context.Background(), "key"); !expiresAt.Equal(before) {
+		t.Errorf("expected expiry to stay %s, got %s", before, expiresAt)
+	}
+
+	// Осталось меньше порога: срок жизни продлевается до newTTL от текущего момента
+	clock.Advance(6 * time.Minute)
+	extended, err = c.TouchIfBelow(context.Background(), "key", 5*time.Minute, time.Hour)
+	if err != nil || !extended {
+		t.Fatalf("expected extension below the threshold, got %v, %v", extended, err)
+	}
+	if _, expiresAt, _ := c.Get(context.Background(), "key"); !expiresAt.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("expected expiry in an hour, got %s", expiresAt.Sub(clock.Now()))
+	}
+
+	if _, err := c.TouchIfBelow(context.Background(), "missing", time.Minute, time.Hour); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected errKeyNotFound, got %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err := c.TouchIfBelow(context.Background(), "key", time.Minute, time.Hour); !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected errExpiredKey, got %v", err)
+	}
+	if _, err := c.TouchIfBelow(context.Background(), "key", time.Minute, -time.Second); !errors.Is(err, errNegativeTTL) {
+		t.Errorf("expected errNegativeTTL, got %v", err)
+	}
+
+	// Бессрочный элемент не продлевается
+	c = NewLRUCache(10, 0)
+	_ = c.Put(context.Background(), "forever", "value", 0)
+	if extended, err := c.TouchIfBelow(context.Background(), "forever", time.Hour, time.Minute); err != nil || extended {
+		t.Errorf("expected no-op for a non-expiring entry, got %v, %v", extended, err)
+	}
+}
+
+func TestLRUCache_TTLJitter(t *testing.T) {
+	const n = 200
+	ttl := time.Minute
+	c := NewLRUCache(n, ttl, WithTTLJitter(0.1))
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		_ = c.Put(context.Background(), "key"+strconv.Itoa(i), i, ttl)
+	}
+	end := time.Now()
+
+	// Время истечения разбросано в пределах ±10% от TTL
+	lowest, highest := end.Add(ttl), start
+	for i := 0; i < n; i++ {
+		_, expiresAt, err := c.Get(context.Background(), "key"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expiresAt.Before(start.Add(ttl*9/10)) || expiresAt.After(end.Add(ttl*11/10)) {
+			t.Errorf("expiry %s outside of jitter window", expiresAt.Sub(start))
+		}
+		if expiresAt.Before(lowest) {
+			lowest = expiresAt
+		}
+		if expiresAt.After(highest) {
+			highest = expiresAt
+		}
+	}
+	if spread := highest.Sub(lowest); spread < ttl/10 {
+		t.Errorf("expected expiry times to spread over at least %s, got %s", ttl/10, spread)
+	}
+}
+
+func TestLRUCache_MaxTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, 0, WithClock(clock.Now), WithMaxTTL(time.Hour))
+
+	// TTL в пределах ограничения сохраняется без изменений
+	item, _, err := c.PutWithOptions(context.Background(), "within", "value", PutOptions{TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !item.ExpiresAt.Equal(clock.Now().Add(time.Minute)) || item.TTLClamped {
+		t.Errorf("expected unclamped expiry in 1m, got %+v", item)
+	}
+
+	// Больший TTL уменьшается до ограничения
+	item, _, _ = c.PutWithOptions(context.Background(), "above", "value", PutOptions{TTL: 24 * time.Hour})
+	if !item.ExpiresAt.Equal(clock.Now().Add(time.Hour)) || !item.TTLClamped {
+		t.Errorf("expected expiry clamped to 1h, got %+v", item)
+	}
+	if _, expiresAt, _ := c.Get(context.Background(), "above"); !expiresAt.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("expected stored expiry clamped to 1h, got %v", expiresAt)
+	}
+
+	// Бессрочный элемент по умолчанию также получает максимальный TTL
+	item, _, _ = c.PutWithOptions(context.Background(), "forever", "value", PutOptions{})
+	if !item.ExpiresAt.Equal(clock.Now().Add(time.Hour)) || !item.TTLClamped {
+		t.Errorf("expected non-expiring entry clamped to 1h, got %+v", item)
+	}
+	clock.Advance(time.Hour + time.Second)
+	if _, _, err := c.Get(context.Background(), "forever"); err == nil {
+		t.Errorf("expected clamped entry to expire, got %v", err)
+	}
+}
+
+func TestLRUCache_MaxTTLJitter(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, 0, WithClock(clock.Now), WithMaxTTL(time.Hour), WithTTLJitter(0.5))
+
+	// TTL чуть ниже ограничения после разброса может его превысить: такой элемент
+	// тоже считается ограниченным
+	clampedSeen := false
+	for i := 0; i < 100; i++ {
+		item, _, err := c.PutWithOptions(context.Background(), "key", "value", PutOptions{TTL: 59 * time.Minute})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		atMax := item.ExpiresAt.Equal(clock.Now().Add(time.Hour))
+		if atMax != item.TTLClamped {
+			t.Fatalf("expected clamped flag to match expiry at the limit, got %+v", item)
+		}
+		clampedSeen = clampedSeen || atMax
+	}
+	if !clampedSeen {
+		t.Error("expected jitter to push some TTLs over the limit")
+	}
+}
+
+func TestLRUCache_MinTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now), WithMinTTL(10*time.Second))
+
+	// TTL ниже минимального увеличивается до него
+	item, _, err := c.PutWithOptions(context.Background(), "short", "value", PutOptions{TTL: time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !item.ExpiresAt.Equal(clock.Now().Add(10 * time.Second)) {
+		t.Errorf("expected expiry raised to 10s, got %v", item.ExpiresAt.Sub(clock.Now()))
+	}
+
+	// Обычный TTL и нулевой TTL (значение по умолчанию) не меняются
+	item, _, _ = c.PutWithOptions(context.Background(), "normal", "value", PutOptions{TTL: 30 * time.Second})
+	if !item.ExpiresAt.Equal(clock.Now().Add(30 * time.Second)) {
+		t.Errorf("expected expiry in 30s, got %v", item.ExpiresAt.Sub(clock.Now()))
+	}
+	item, _, _ = c.PutWithOptions(context.Background(), "default", "value", PutOptions{})
+	if !item.ExpiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("expected default expiry in 1m, got %v", item.ExpiresAt.Sub(clock.Now()))
+	}
+
+	// Явно заданный нулевой TTL по-прежнему означает немедленное истечение
+	item, _, _ = c.PutWithOptions(context.Background(), "expire", "value", PutOptions{ExplicitTTL: true})
+	if !item.ExpiresAt.Equal(clock.Now()) {
+		t.Errorf("expected immediate expiry, got %v", item.ExpiresAt.Sub(clock.Now()))
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	cfg := &config.Config{
+		CacheMaxBytes:       1024,
+		CaseInsensitiveKeys: true,
+		TTLOverrides:        []string{"session:*=30m"},
+	}
+	opts, err := ConfigOptions(cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := NewLRUCache(10, time.Minute, opts...)
+	defer c.Close()
+
+	// Опции из конфигурации применяются к кэшу
+	if c.maxBytes != 1024 {
+		t.Errorf("expected max bytes 1024, got %d", c.maxBytes)
+	}
+	if !c.caseInsensitive {
+		t.Error("expected case-insensitive keys")
+	}
+	if got := c.defaultTTLFor("session:1"); got != 30*time.Minute {
+		t.Errorf("expected 30m TTL override, got %s", got)
+	}
+
+	// Некорректное правило TTL возвращает ошибку
+	cfg.TTLOverrides = []string{"static:*"}
+	if _, err := ConfigOptions(cfg, nil); err == nil {
+		t.Error("expected error for invalid ttl override")
+	}
+}
+
+func TestLRUCache_TTLOverride(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now),
+		WithTTLOverride("session:*", 30*time.Minute),
+		WithTTLOverride("static:*/v*", 0),
+		WithTTLOverride("session:admin", time.Hour),
+	)
+	expiry := func(key string, ttl time.Duration) time.Duration {
+		t.Helper()
+		item, _, err := c.PutWithOptions(context.Background(), key, "value", PutOptions{TTL: ttl})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if item.ExpiresAt.IsZero() {
+			return 0
+		}
+		return item.ExpiresAt.Sub(clock.Now())
+	}
+
+	// Ключ, подходящий под шаблон, без явного TTL получает TTL шаблона
+	if got := expiry("session:42", 0); got != 30*time.Minute {
+		t.Errorf("expected 30m for session key, got %s", got)
+	}
+	// Применяется первый подходящий шаблон
+	if got := expiry("session:admin", 0); got != 30*time.Minute {
+		t.Errorf("expected the first matching pattern, got %s", got)
+	}
+	// Нулевой TTL шаблона означает бессрочный элемент
+	if got := expiry("static:css/v2/app.css", 0); got != 0 {
+		t.Errorf("expected non-expiring static key, got %s", got)
+	}
+	// Явный TTL и неподходящие ключи используют обычные правила
+	if got := expiry("session:42", 10*time.Second); got != 10*time.Second {
+		t.Errorf("expected explicit TTL to win, got %s", got)
+	}
+	if got := expiry("user:1", 0); got != time.Minute {
+		t.Errorf("expected default TTL for unmatched key, got %s", got)
+	}
+}
+
+func TestMatchKeyPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"session:*", "session:1", true},
+		{"session:*", "session:", true},
+		{"session:*", "user:1", false},
+		{"*:tmp", "job/1:tmp", true},
+		{"a*b*c", "abxbc", true},
+		{"a*b*c", "acb", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	} {
+		if got := matchKeyPattern(tc.pattern, tc.key); got != tc.want {
+			t.Errorf("matchKeyPattern(%q, %q) = %v, want %v", tc.pattern, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestLRUCache_ExpiredFirst(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(3, 0, WithClock(clock.Now), WithExpiredFirst(true))
+	_ = c.Put(context.Background(), "tail", "value", 0)
+	_ = c.Put(context.Background(), "stale", "value", time.Second)
+	_ = c.Put(context.Background(), "head", "value", 0)
+	clock.Advance(2 * time.Second)
+
+	// При переполнении удаляется истёкший элемент из середины списка, а не актуальный конец
+	_ = c.Put(context.Background(), "new", "value", 0)
+	if _, _, err := c.Get(context.Background(), "tail"); err != nil {
+		t.Errorf("expected live tail to survive, got %v", err)
+	}
+	if c.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", c.Len())
+	}
+	if stats := c.EvictionStats(); stats.Evicted != 0 {
+		t.Errorf("expected no capacity evictions, got %d", stats.Evicted)
+	}
+
+	// Без истёкших элементов вытесняется конец списка
+	_ = c.Put(context.Background(), "another", "value", 0)
+	if _, _, err := c.Get(context.Background(), "head"); err == nil {
+		t.Error("expected least recently used entry to be evicted")
+	}
+}
+
+func TestLRUCache_SoftLimit(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute, WithSoftLimit(3))
+	_ = c.Put(context.Background(), "stale", "value", time.Millisecond)
+	_ = c.Put(context.Background(), "a", "a", 0)
+	_ = c.Put(context.Background(), "b", "b", 0)
+	time.Sleep(5 * time.Millisecond)
+
+	// Превышение мягкого лимита удаляет истёкшие элементы заранее
+	_ = c.Put(context.Background(), "c", "c", 0)
+	if got := c.EvictionStats().SoftLimitReached; got != 1 {
+		t.Errorf("expected soft limit to be reached once, got %d", got)
+	}
+	if c.Len() != 3 {
+		t.Errorf("expected expired entry to be purged, got %d entries", c.Len())
+	}
+	if got := c.EvictionStats().Evicted; got != 0 {
+		t.Errorf("expected no capacity evictions, got %d", got)
+	}
+
+	// Пока число элементов выше лимита, повторные записи не считаются новым превышением
+	_ = c.Put(context.Background(), "d", "d", 0)
+	_ = c.Put(context.Background(), "e", "e", 0)
+	if got := c.EvictionStats().SoftLimitReached; got != 2 {
+		t.Errorf("expected 2 soft limit crossings, got %d", got)
+	}
+}
+
+func TestLRUCache_PrefixQuota(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(10, 1*time.Minute, WithPrefixQuota(":", 2))
+	_ = c.Put(ctx, "b:1", "1", 0)
+	_ = c.Put(ctx, "a:1", "1", 0)
+	_ = c.Put(ctx, "a:2", "2", 0)
+	_ = c.Put(ctx, "plain", "value", 0)
+
+	// Превышение квоты вытесняет самый старый элемент префикса "a:", а не глобальный LRU "b:1"
+	_ = c.Put(ctx, "a:3", "3", 0)
+	if _, _, err := c.Get(ctx, "a:1"); err == nil {
+		t.Error("expected a:1 to be evicted by prefix quota")
+	}
+	for _, key := range []string{"b:1", "a:2", "a:3", "plain"} {
+		if _, _, err := c.Get(ctx, key); err != nil {
+			t.Errorf("expected %s to be kept, got %v", key, err)
+		}
+	}
+	if got := c.EvictionStats().Evicted; got != 1 {
+		t.Errorf("expected 1 eviction, got %d", got)
+	}
+
+	// Удаление освобождает место в квоте префикса
+	_, _ = c.Evict(ctx, "a:2")
+	_ = c.Put(ctx, "a:4", "4", 0)
+	if _, _, err := c.Get(ctx, "a:3"); err != nil {
+		t.Errorf("expected a:3 to be kept after delete, got %v", err)
+	}
+
+	// Обновление существующего ключа не расходует квоту
+	_ = c.Put(ctx, "a:4", "updated", 0)
+	if c.Len() != 4 {
+		t.Errorf("expected 4 entries, got %d", c.Len())
+	}
+}
+
+func TestLRUCache_GetMany(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	_ = c.Put(context.Background(), "a", "1", 0)
+	_ = c.Put(context.Background(), "b", "2", 0)
+	_ = c.Put(context.Background(), "expired", "value", time.Millisecond)
+	_ = c.PutNegative(context.Background(), "negative", 0)
+	time.Sleep(5 * time.Millisecond)
+
+	items, err := c.GetMany(context.Background(), []string{"a", "b", "missing", "expired", "negative"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items["a"].Value != "1" || items["b"].Value != "2" {
+		t.Errorf("expected only a and b, got %+v", items)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetMany(ctx, []string{"a"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestLRUCache_AgeStats(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	if stats, err := c.AgeStats(context.Background()); err != nil || stats.OldestAge != 0 || !stats.NextExpiry.IsZero() {
+		t.Fatalf("expected zero stats for empty cache, got %+v, %v", stats, err)
+	}
+
+	// Отрицательная запись не учитывается ни в возрасте, ни во времени истечения
+	_ = c.PutNegative(context.Background(), "missing", 10*time.Second)
+	time.Sleep(20 * time.Millisecond)
+	_ = c.Put(context.Background(), "old", "value", time.Hour)
+	written := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	_ = c.Put(context.Background(), "new", "value", 30*time.Second)
+
+	stats, err := c.AgeStats(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Возраст наименее недавно использованного элемента совпадает со временем с его записи
+	if maxAge := time.Since(written); stats.OldestAge < 20*time.Millisecond || stats.OldestAge > maxAge+time.Millisecond {
+		t.Errorf("expected oldest age between 20ms and %s, got %s", maxAge, stats.OldestAge)
+	}
+	if until := time.Until(stats.NextExpiry); until <= 29*time.Second || until > 30*time.Second {
+		t.Errorf("expected next expiry in about 30s, got %s", until)
+	}
+}
+
+func TestLRUCache_AgeStatsRead(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Hour, WithClock(clock.Now))
+	_ = c.Put(context.Background(), "key", "value", 0)
+
+	// Чтение единственного элемента, стоящего в начале списка, не перемещает его,
+	// но сбрасывает возраст
+	clock.Advance(10 * time.Minute)
+	if stats, _ := c.AgeStats(context.Background()); stats.OldestAge != 10*time.Minute {
+		t.Fatalf("expected oldest age 10m before the read, got %s", stats.OldestAge)
+	}
+	if _, _, err := c.Get(context.Background(), "key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats, _ := c.AgeStats(context.Background()); stats.OldestAge != 0 {
+		t.Errorf("expected oldest age to reset after the read, got %s", stats.OldestAge)
+	}
+}
+
+func TestLRUCache_WaitGet(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		value, _, err := c.WaitGet(context.Background(), "job")
+		results <- result{value, err}
+	}()
+
+	// Ожидающий вызов разблокируется записью из другой горутины
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		_ = c.Put(context.Background(), "other", "ignored", 0)
+		_ = c.Put(context.Background(), "job", "done", 0)
+	}()
+
+	select {
+	case res := <-results:
+		if res.err != nil || res.value != "done" {
+			t.Errorf("expected done, got %v, %v", res.value, res.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitGet did not unblock after Put")
+	}
+
+	// Существующий ключ возвращается без ожидания
+	if value, _, err := c.WaitGet(context.Background(), "job"); err != nil || value != "done" {
+		t.Errorf("expected done, got %v, %v", value, err)
+	}
+
+	// Истечение дедлайна прерывает ожидание
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.WaitGet(ctx, "missing"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if len(c.waiters) != 0 {
+		t.Errorf("expected waiters to be cleaned up, got %d", len(c.waiters))
+	}
+
+	// Закрытие кеша завершает ожидание
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = c.Close()
+	}()
+	if _, _, err := c.WaitGet(context.Background(), "missing"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestLRUCache_NilValue(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+
+	// Значение nil неотличимо от отсутствия значения и отклоняется
+	if err := c.Put(context.Background(), "key", nil, 0); !errors.Is(err, ErrNilValue) {
+		t.Errorf("expected ErrNilValue, got %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "key"); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected key not to be stored, got %v", err)
+	}
+
+	// Отсутствие значения записывается явно через PutNegative
+	if err := c.PutNegative(context.Background(), "key", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "key"); !errors.Is(err, ErrNegativeCached) {
+		t.Errorf("expected ErrNegativeCached, got %v", err)
+	}
+}
+
+func TestLRUCache_EvictionWarning(t *testing.T) {
+	var buf bytes.Buffer
+	clock := newFakeClock()
+	c := NewLRUCache(2, 0, WithClock(clock.Now), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	// Первое вытеснение по ёмкости логируется с ключом и заполненностью кеша
+	for i := 0; i < 3; i++ {
+		_ = c.Put(context.Background(), "key"+strconv.Itoa(i), "value", 0)
+	}
+	if got := strings.Count(buf.String(), "Entry evicted due to capacity pressure"); got != 1 {
+		t.Fatalf("expected 1 warning, got %d: %s", got, buf.String())
+	}
+	for _, attr := range []string{"level=WARN", "key=key0", "size=2", "capacity=2"} {
+		if !strings.Contains(buf.String(), attr) {
+			t.Errorf("expected %s in warning, got %s", attr, buf.String())
+		}
+	}
+
+	// Последующие вытеснения в пределах интервала не логируются
+	for i := 3; i < 100; i++ {
+		_ = c.Put(context.Background(), "key"+strconv.Itoa(i), "value", 0)
+	}
+	if got := strings.Count(buf.String(), "Entry evicted"); got != 1 {
+		t.Errorf("expected warnings to be rate limited, got %d", got)
+	}
+
+	// После интервала предупреждение выводится снова с числом пропущенных вытеснений
+	clock.Advance(evictionWarnInterval)
+	_ = c.Put(context.Background(), "next", "value", 0)
+	if got := strings.Count(buf.String(), "Entry evicted"); got != 2 {
+		t.Errorf("expected a second warning after the interval, got %d", got)
+	}
+	if !strings.Contains(buf.String(), "suppressed=97") {
+		t.Errorf("expected suppressed count in warning, got %s", buf.String())
+	}
+
+	// Истечение TTL и удаление не считаются вытеснением по ёмкости
+	buf.Reset()
+	clock.Advance(evictionWarnInterval)
+	_, _ = c.Evict(context.Background(), "next")
+	if buf.Len() != 0 {
+		t.Errorf("expected no warning for explicit delete, got %s", buf.String())
+	}
+}
+
+func TestLRUCache_RemovalReason(t *testing.T) {
+	var buf bytes.Buffer
+	clock := newFakeClock()
+	log := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := NewLRUCache(10, 0, WithClock(clock.Now), WithLogger(log))
+
+	// Истёкший элемент удаляется при чтении с причиной expired
+	_ = c.Put(context.Background(), "stale", "value", time.Second)
+	clock.Advance(2 * time.Second)
+	_, _, _ = c.Get(context.Background(), "stale")
+	if !strings.Contains(buf.String(), "key=stale reason=expired") {
+		t.Errorf("expected expired reason for stale, got %s", buf.String())
+	}
+
+	// Явное удаление записывается с причиной deleted
+	buf.Reset()
+	_ = c.Put(context.Background(), "key", "value", 0)
+	_, _ = c.Evict(context.Background(), "key")
+	if !strings.Contains(buf.String(), "key=key reason=deleted") {
+		t.Errorf("expected deleted reason for key, got %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "reason=expired") {
+		t.Errorf("unexpected expired reason for explicit delete: %s", buf.String())
+	}
+
+	// Полная очистка и замена содержимого записываются одной строкой с числом элементов
+	buf.Reset()
+	_ = c.Put(context.Background(), "a", "value", 0)
+	_ = c.Put(context.Background(), "b", "value", 0)
+	_ = c.EvictAll(context.Background())
+	_ = c.Put(context.Background(), "c", "value", 0)
+	_ = c.ReplaceAll(context.Background(), []Item{{Key: "d", Value: "value"}})
+	for _, want := range []string{"count=2 reason=deleted", "count=1 reason=deleted"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in bulk removal log, got %s", want, buf.String())
+		}
+	}
+}
+
+func TestLRUCache_Changes(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(2, 0, WithClock(clock.Now))
+	ctx := context.Background()
+	keysOf := func(changes []Change) string {
+		var keys []string
+		for _, change := range changes {
+			key := change.Key
+			if change.Deleted {
+				key = "-" + key
+			}
+			keys = append(keys, key)
+		}
+		return strings.Join(keys, ",")
+	}
+
+	_ = c.Put(ctx, "a", 1, 0)
+	_ = c.Put(ctx, "b", 2, time.Second)
+	changes, v1, err := c.Changes(ctx, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keysOf(changes) != "a,b" {
+		t.Errorf("expected initial sync [a b], got %s", keysOf(changes))
+	}
+
+	// Запись, вытеснение и удаление попадают в изменения и увеличивают версию
+	_ = c.Put(ctx, "a", 10, 0)
+	clock.Advance(2 * time.Second)
+	_ = c.Put(ctx, "c", 3, 0)
+	_ = c.Put(ctx, "d", 4, 0)
+	_, _ = c.Evict(ctx, "d")
+	changes, v2, err := c.Changes(ctx, v1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keysOf(changes) != "c,-b,-a,-d" {
+		t.Errorf("expected [c -b -a -d], got %s", keysOf(changes))
+	}
+	if v2 <= v1 {
+		t.Errorf("expected version to advance past %d, got %d", v1, v2)
+	}
+
+	// Без изменений список пуст, а версия не меняется
+	if changes, v3, _ := c.Changes(ctx, v2); len(changes) != 0 || v3 != v2 {
+		t.Errorf("expected no changes at version %d, got %s at %d", v2, keysOf(changes), v3)
+	}
+
+	// Удаление, записанное заново, возвращается как актуальный ключ
+	_ = c.Put(ctx, "d", 5, 0)
+	if changes, _, _ := c.Changes(ctx, v1); keysOf(changes) != "c,-b,-a,d" {
+		t.Errorf("expected [c -b -a d], got %s", keysOf(changes))
+	}
+
+	// После истечения срока хранения удалений требуется полная синхронизация
+	clock.Advance(tombstoneRetention + time.Second)
+	if _, _, err := c.Changes(ctx, v1); !errors.Is(err, ErrChangesExpired) {
+		t.Errorf("expected ErrChangesExpired, got %v", err)
+	}
+	_ = c.EvictAll(ctx)
+	if _, _, err := c.Changes(ctx, v2); !errors.Is(err, ErrChangesExpired) {
+		t.Errorf("expected ErrChangesExpired after EvictAll, got %v", err)
+	}
+}
+
+func TestLRUCache_KeyStoredOnce(t *testing.T) {
+	c := NewLRUCache(10, time.Minute, WithCaseInsensitiveKeys(true))
+
+	// Ключ — часть большого буфера, который кеш не должен удерживать
+	body := strings.Repeat("k", 4096) + "/tail"
+	key := body[:1024]
+	if err := c.Put(context.Background(), key, "v", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, _, err := c.Get(context.Background(), strings.Repeat("K", 1024)); err != nil || value != "v" {
+		t.Fatalf("expected lookup by long key to succeed, got %v, %v", value, err)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for mapKey, node := range c.cache {
+		if unsafe.StringData(mapKey) != unsafe.StringData(node.key) {
+			t.Error("expected map and node to share the key bytes")
+		}
+		if unsafe.StringData(node.key) == unsafe.StringData(body) {
+			t.Error("expected key to be copied out of the caller's buffer")
+		}
+	}
+}
+
+func TestLRUCache_Unbounded(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(0, time.Minute, WithClock(clock.Now))
+
+	// Ёмкость не ограничивает число элементов и их стоимость
+	for i := 0; i < 1000; i++ {
+		_ = c.Put(context.Background(), "k"+strconv.Itoa(i), i, 0)
+	}
+	if _, _, err := c.PutWithOptions(context.Background(), "costly", "v", PutOptions{Cost: 1 << 20, TTL: time.Hour}); err != nil {
+		t.Fatalf("unexpected error for costly entry: %v", err)
+	}
+	if c.Len() != 1001 {
+		t.Fatalf("expected 1001 entries, got %d", c.Len())
+	}
+	if stats := c.EvictionStats(); stats.Evicted != 0 {
+		t.Errorf("expected no capacity evictions, got %+v", stats)
+	}
+
+	// Чтение и обновление не меняют порядок списка
+	_, _, _ = c.Get(context.Background(), "k0")
+	_ = c.Put(context.Background(), "k1", "updated", 0)
+	least, _ := c.Least(context.Background(), 2, "")
+	if len(least) != 2 || least[0].Key != "k0" || least[1].Key != "k1" {
+		t.Errorf("expected insertion order k0, k1 at the back, got %+v", least)
+	}
+	checkListInvariants(t, c)
+
+	// Элементы удаляются только по истечении TTL
+	clock.Advance(time.Minute + time.Second)
+	if _, _, err := c.Get(context.Background(), "k0"); err == nil {
+		t.Error("expected k0 to expire")
+	}
+	if value, _, err := c.Get(context.Background(), "costly"); err != nil || value != "v" {
+		t.Errorf("expected costly entry to survive, got %v, %v", value, err)
+	}
+	keys, _, _ := c.GetAll(context.Background())
+	if len(keys) != 1 || keys[0] != "costly" {
+		t.Errorf("expected only costly entry to remain, got %d keys", len(keys))
+	}
+}
+
+func TestLRUCache_Snapshot(t *testing.T) {
+	c := NewLRUCache(10, time.Minute, WithSnapshotInterval(time.Hour))
+	defer c.Close()
+
+	// Начальный снимок снимается при создании кеша
+	if snapshot := c.Snapshot(); snapshot == nil || len(snapshot.Items) != 0 {
+		t.Fatalf("expected empty initial snapshot, got %+v", snapshot)
+	}
+
+	_ = c.Put(context.Background(), "a", "1", 0)
+	if len(c.Snapshot().Items) != 0 {
+		t.Error("expected snapshot to ignore writes until refresh")
+	}
+	if _, err := c.RefreshSnapshot(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if items := c.Snapshot().Items; len(items) != 1 || items[0].Key != "a" {
+		t.Errorf("expected snapshot with a after refresh, got %+v", items)
+	}
+
+	// Чтение снимка не ждёт освобождения блокировки кеша на запись
+	c.mutex.Lock()
+	read := make(chan *Snapshot)
+	go func() {
+		read <- c.Snapshot()
+	}()
+	select {
+	case snapshot := <-read:
+		if len(snapshot.Items) != 1 {
+			t.Errorf("expected last refreshed snapshot, got %+v", snapshot.Items)
+		}
+	case <-time.After(time.Second):
+		t.Error("snapshot read blocked by the write lock")
+	}
+	c.mutex.Unlock()
+
+	// Без режима снимков Snapshot возвращает nil
+	if NewLRUCache(10, time.Minute).Snapshot() != nil {
+		t.Error("expected nil snapshot when the mode is disabled")
+	}
+}
+
+func TestLRUCache_FullnessThresholds(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewLRUCache(10, 0, WithFullnessThresholds(80, 90), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	put := func(from, to int) {
+		for i := from; i < to; i++ {
+			_ = c.Put(context.Background(), "key"+strconv.Itoa(i), "value", 0)
+		}
+	}
+
+	// Ниже первого порога событий нет
+	put(0, 7)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no events below 80%%, got %s", buf.String())
+	}
+
+	// Каждый порог даёт ровно одно событие, повторные записи выше порога — ни одного
+	put(7, 10)
+	_ = c.Put(context.Background(), "key9", "updated", 0)
+	if got := strings.Count(buf.String(), "Cache fullness threshold crossed"); got != 2 {
+		t.Fatalf("expected 2 events, got %d: %s", got, buf.String())
+	}
+	for _, attr := range []string{"threshold_percent=80 size=8", "threshold_percent=90 size=9"} {
+		if !strings.Contains(buf.String(), attr) {
+			t.Errorf("expected %q in events, got %s", attr, buf.String())
+		}
+	}
+
+	// Колебание у границы порога не приводит к повторному событию
+	buf.Reset()
+	_, _ = c.Evict(context.Background(), "key9")
+	_, _ = c.Evict(context.Background(), "key8")
+	put(8, 10)
+	if buf.Len() != 0 {
+		t.Errorf("expected no events while flapping around the threshold, got %s", buf.String())
+	}
+
+	// После заметного снижения заполненности порог срабатывает снова
+	for i := 3; i < 10; i++ {
+		_, _ = c.Evict(context.Background(), "key"+strconv.Itoa(i))
+	}
+	put(3, 8)
+	if got := strings.Count(buf.String(), "threshold_percent=80"); got != 1 {
+		t.Errorf("expected the 80%% threshold to re-arm, got %s", buf.String())
+	}
+
+	// Увеличение стоимости существующего элемента тоже пересекает порог
+	buf.Reset()
+	_, _, _ = c.PutWithOptions(context.Background(), "key0", "value", PutOptions{Cost: 2})
+	if !strings.Contains(buf.String(), "threshold_percent=90") {
+		t.Errorf("expected the 90%% threshold to be crossed by an update, got %s", buf.String())
+	}
+
+	crossings := c.FullnessCrossings()
+	if crossings[80] != 2 || crossings[90] != 2 {
+		t.Errorf("unexpected crossings: %v", crossings)
+	}
+}
+
+func TestLRUCache_TTLHistogram(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, 0, WithClock(clock.Now))
+	ttls := map[string]time.Duration{
+		"half-second": 500 * time.Millisecond,
+		"five":        5 * time.Second,
+		"thirty":      30 * time.Second,
+		"ten-minutes": 10 * time.Minute,
+		"two-hours":   2 * time.Hour,
+		"expired":     time.Millisecond,
+	}
+	for key, ttl := range ttls {
+		_ = c.Put(context.Background(), key, "value", ttl)
+	}
+	_ = c.Put(context.Background(), "never", "value", 0)
+	_ = c.PutNegative(context.Background(), "missing", 5*time.Second)
+	clock.Advance(2 * time.Millisecond)
+
+	hist, err := c.TTLHistogram(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Каждый элемент попадает в интервал по оставшемуся TTL, истёкшие и отрицательные не учитываются
+	want := []int{1, 1, 1, 1}
+	for i, count := range want {
+		if hist.Buckets[i] != count {
+			t.Errorf("bucket < %s: expected %d, got %d", TTLBucketBounds[i], count, hist.Buckets[i])
+		}
+	}
+	if hist.Longer != 1 || hist.Never != 1 {
+		t.Errorf("expected 1 longer and 1 never, got %+v", hist)
+	}
+
+	// Со временем элементы перемещаются в меньшие интервалы
+	clock.Advance(5 * time.Minute)
+	hist, _ = c.TTLHistogram(context.Background())
+	if hist.Buckets[2] != 0 || hist.Buckets[3] != 1 || hist.Longer != 1 {
+		t.Errorf("unexpected histogram after 5 minutes: %+v", hist)
+	}
+}
+
+func TestLRUCache_CaseInsensitiveKeys(t *testing.T) {
+	ctx := context.Background()
+
+	// По умолчанию ключи, различающиеся регистром, — разные элементы
+	c := NewLRUCache(10, 1*time.Minute)
+	_ = c.Put(ctx, "User:1", "upper", 0)
+	_ = c.Put(ctx, "user:1", "lower", 0)
+	if value, _, _ := c.Get(ctx, "User:1"); value != "upper" || c.Len() != 2 {
+		t.Errorf("expected distinct keys by default, got %v with %d entries", value, c.Len())
+	}
+
+	// В регистронезависимом режиме ключ нормализуется во всех операциях
+	c = NewLRUCache(10, 1*time.Minute, WithCaseInsensitiveKeys(true))
+	_ = c.Put(ctx, "User:1", "first", 0)
+	_ = c.Put(ctx, "USER:1", "second", 0)
+	if c.Len() != 1 {
+		t.Errorf("expected one entry, got %d", c.Len())
+	}
+	if value, _, err := c.Get(ctx, "user:1"); err != nil || value != "second" {
+		t.Errorf("expected second, got %v, %v", value, err)
+	}
+	if touched, _ := c.TouchMany(ctx, []string{"uSeR:1"}, time.Hour); touched != 1 {
+		t.Errorf("expected key to be touched, got %d", touched)
+	}
+	keys, _, _ := c.GetAll(ctx)
+	if len(keys) != 1 || keys[0] != "user:1" {
+		t.Errorf("expected lower-case key in GetAll, got %v", keys)
+	}
+	if items, _ := c.Recent(ctx, 10, "USER:"); len(items) != 1 {
+		t.Errorf("expected prefix to be normalized, got %d items", len(items))
+	}
+	if value, err := c.Evict(ctx, "User:1"); err != nil || value != "second" {
+		t.Errorf("expected evict by mixed-case key, got %v, %v", value, err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected empty cache, got %d entries", c.Len())
+	}
+}
+
+func TestLRUCache_Pop(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute)
+	_ = c.Put(context.Background(), "token", "secret", 0)
+
+	// Из одновременных вызовов значение получает только один
+	var wg sync.WaitGroup
+	var winners atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, _, err := c.Pop(context.Background(), "token"); err == nil {
+				if value != "secret" {
+					t.Errorf("expected secret, got %v", value)
+				}
+				winners.Add(1)
+			} else if !errors.Is(err, errKeyNotFound) {
+				t.Errorf("expected key not found, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if winners.Load() != 1 {
+		t.Errorf("expected exactly one pop to succeed, got %d", winners.Load())
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected key to be removed, got %d entries", c.Len())
+	}
+
+	// Истёкший ключ не возвращается
+	_ = c.Put(context.Background(), "expired", "value", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := c.Pop(context.Background(), "expired"); !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected expired key error, got %v", err)
+	}
+}
+
+func TestLRUCache_ReplaceAll(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(3, time.Minute, WithClock(clock.Now))
+	_ = c.Put(context.Background(), "old", "v", 0)
+
+	err := c.ReplaceAll(context.Background(), []Item{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2", ExpiresAt: clock.Now().Add(time.Hour)},
+		{Key: "gone", Value: "3", ExpiresAt: clock.Now().Add(-time.Second)},
+		{Key: "c", Value: "4"},
+		{Key: "d", Value: "5"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Истёкший элемент пропущен, лишний по ёмкости вытеснен с конца, порядок сохранён
+	items, _ := c.Items(context.Background())
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	if strings.Join(keys, ",") != "a,b,c" {
+		t.Errorf("expected keys a,b,c, got %v", keys)
+	}
+	if !items[0].ExpiresAt.Equal(clock.Now().Add(time.Minute)) || !items[1].ExpiresAt.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("expected default and explicit expiry, got %v and %v", items[0].ExpiresAt, items[1].ExpiresAt)
+	}
+
+	// Некорректный элемент не меняет содержимое кеша
+	for _, entries := range [][]Item{
+		{{Key: "x", Value: "1"}, {Key: "", Value: "2"}},
+		{{Key: "x", Value: "1"}, {Key: "y", Value: nil}},
+		{{Key: "x", Value: "1"}, {Key: "x", Value: "2"}},
+	} {
+		if err := c.ReplaceAll(context.Background(), entries); err == nil {
+			t.Errorf("expected error for %+v", entries)
+		}
+	}
+	if c.Len() != 3 {
+		t.Errorf("expected contents to stay unchanged, got %d entries", c.Len())
+	}
+
+	// Пустой набор очищает кеш
+	if err := c.ReplaceAll(context.Background(), nil); err != nil || c.Len() != 0 || c.UsedCost() != 0 {
+		t.Errorf("expected empty cache, got %d entries, err %v", c.Len(), err)
+	}
+}
+
+func TestLRUCache_ReplaceAllConcurrent(t *testing.T) {
+	const size = 100
+	set := func(name string) []Item {
+		entries := make([]Item, size)
+		for i := range entries {
+			entries[i] = Item{Key: "k" + strconv.Itoa(i), Value: name}
+		}
+		return entries
+	}
+	c := NewLRUCache(size, time.Minute)
+	_ = c.ReplaceAll(context.Background(), set("old"))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			name := "old"
+			if i%2 == 0 {
+				name = "new"
+			}
+			_ = c.ReplaceAll(context.Background(), set(name))
+		}
+		close(done)
+	}()
+
+	// Читатель видит только полный прежний или полный новый набор
+	for {
+		select {
+		case <-done:
+			wg.Wait()
+			return
+		default:
+		}
+		items, err := c.Items(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(items) != size {
+			t.Fatalf("expected %d items, got %d", size, len(items))
+		}
+		for _, item := range items {
+			if item.Value != items[0].Value {
+				t.Fatalf("expected a single set, got mixed values %v and %v", items[0].Value, item.Value)
+			}
+		}
+	}
+}
+
+func TestLRUCache_HitRatio(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Hour, WithClock(clock.Now))
+	if stats := c.HitRatio(); stats.Ratio != 0 || stats.Hits+stats.Misses != 0 {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+
+	// Старые чтения: только промахи
+	for i := 0; i < 50; i++ {
+		_, _, _ = c.Get(context.Background(), "missing")
+	}
+	clock.Advance(2 * HitRatioWindow)
+
+	// Текущая нагрузка: 3 попадания на 1 промах
+	_ = c.Put(context.Background(), "key", "value", 0)
+	for i := 0; i < 400; i++ {
+		key := "key"
+		if i%4 == 3 {
+			key = "missing"
+		}
+		_, _, _ = c.Get(context.Background(), key)
+		clock.Advance(100 * time.Millisecond)
+	}
+	stats := c.HitRatio()
+	if stats.Ratio < 0.74 || stats.Ratio > 0.76 {
+		t.Errorf("expected hit ratio about 0.75, got %+v", stats)
+	}
+	if stats.Hits+stats.Misses > 400 {
+		t.Errorf("expected lookups outside the window to be dropped, got %+v", stats)
+	}
+
+	// Окно сдвигается: после периода без чтений статистика пуста
+	clock.Advance(HitRatioWindow + hitRatioBucketWidth)
+	if stats := c.HitRatio(); stats.Hits+stats.Misses != 0 {
+		t.Errorf("expected empty window, got %+v", stats)
+	}
+}
+
+func TestLRUCache_HitRatioGetOrCompute(t *testing.T) {
+	c := NewLRUCache(10, time.Hour)
+	loader := func(ctx context.Context) (interface{}, error) { return "value", nil }
+
+	// Промах с загрузкой и последующее попадание учитываются по одному разу
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetOrCompute(context.Background(), "key", 0, loader); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if stats := c.HitRatio(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
+
+func TestLRUCache_EvictionRateLimit(t *testing.T) {
+	const (
+		rate    = 100
+		burst   = 5
+		evicted = 30
+	)
+	var mu sync.Mutex
+	var calls []time.Time
+	c := NewLRUCache(1, time.Minute,
+		WithOnEvict(func(key string, value interface{}) {
+			mu.Lock()
+			calls = append(calls, time.Now())
+			mu.Unlock()
+		}),
+		WithEvictionRateLimit(rate, burst, 4),
+	)
+
+	// Каждая запись вытесняет предыдущий «грязный» элемент
+	start := time.Now()
+	for i := 0; i <= evicted; i++ {
+		_, _, err := c.PutWithOptions(context.Background(), strconv.Itoa(i), i, PutOptions{Dirty: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Очередь мала, поэтому запись замедляется вместе с обработчиком
+	if elapsed := time.Since(start); elapsed < (evicted-burst-4-1)*time.Second/rate/2 {
+		t.Errorf("expected writes to be delayed by backpressure, took %s", elapsed)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != evicted {
+		t.Fatalf("expected %d callbacks, got %d", evicted, len(calls))
+	}
+	// Сверх начального всплеска вызовы идут не чаще rate в секунду
+	// (ожидающие при Close элементы передаются без ограничения)
+	limited := calls[burst : evicted-5]
+	span := limited[len(limited)-1].Sub(limited[0])
+	if minimum := time.Duration(len(limited)-1) * time.Second / rate * 9 / 10; span < minimum {
+		t.Errorf("expected %d callbacks to take at least %s, took %s", len(limited), minimum, span)
+	}
+}
+
+func TestLRUCache_EvictionRateLimitClose(t *testing.T) {
+	var calls atomic.Int64
+	c := NewLRUCache(1, time.Minute,
+		WithOnEvict(func(key string, value interface{}) { calls.Add(1) }),
+		WithEvictionRateLimit(1000, 1, 64),
+	)
+
+	// Записи вытесняют друг друга одновременно с закрытием кеша
+	var puts atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := strconv.Itoa(w*1000 + i)
+				if _, _, err := c.PutWithOptions(context.Background(), key, i, PutOptions{Dirty: true}); err == nil {
+					puts.Add(1)
+				}
+			}
+		}(w)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wg.Wait()
+
+	// Каждый вытесненный элемент передан обработчику, в том числе оставшиеся в очереди
+	if want := puts.Load() - int64(c.Len()); calls.Load() != want {
+		t.Errorf("expected %d callbacks, got %d", want, calls.Load())
+	}
+}
+
+func TestLRUCache_MemoryCeiling(t *testing.T) {
+	var heap atomic.Uint64
+	heap.Store(50)
+	var logs bytes.Buffer
+	c := NewLRUCache(100, time.Minute,
+		WithMemoryCeiling(100, 5*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		// Подменяем чтение runtime.MemStats управляемым значением
+		func(c *LRUCache) { c.heapUsage = heap.Load },
+	)
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		_ = c.Put(context.Background(), strconv.Itoa(i), i, 0)
+	}
+
+	// Ниже предела элементы не вытесняются
+	time.Sleep(30 * time.Millisecond)
+	if c.Len() != 100 {
+		t.Fatalf("expected no eviction below the ceiling, got %d entries", c.Len())
+	}
+
+	// Превышение предела вытесняет давно использованные элементы
+	heap.Store(150)
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Len() > 80 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	heap.Store(50)
+	if c.Len() > 80 {
+		t.Fatalf("expected emergency eviction above the ceiling, got %d entries", c.Len())
+	}
+	if _, _, err := c.Get(context.Background(), "0"); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("expected the least recently used entry to be evicted, got %v", err)
+	}
+	if _, _, err := c.Get(context.Background(), "99"); err != nil {
+		t.Errorf("expected the most recently used entry to survive, got %v", err)
+	}
+	if stats := c.EvictionStats(); stats.MemoryCeilingReached == 0 || stats.Evicted < 20 {
+		t.Errorf("expected memory ceiling stats to be recorded, got %+v", stats)
+	}
+
+	// После возврата ниже предела вытеснение прекращается
+	time.Sleep(20 * time.Millisecond)
+	remaining := c.Len()
+	time.Sleep(30 * time.Millisecond)
+	if c.Len() != remaining {
+		t.Errorf("expected eviction to stop below the ceiling, got %d then %d entries", remaining, c.Len())
+	}
+
+	// Журнал читается после остановки фоновой проверки
+	_ = c.Close()
+	if !strings.Contains(logs.String(), "Memory ceiling exceeded") {
+		t.Errorf("expected memory ceiling warning to be logged, got %q", logs.String())
+	}
+
+	// Если вытеснять нечего, срабатывание не учитывается и не записывается в журнал
+	logs.Reset()
+	empty := NewLRUCache(10, time.Minute,
+		WithMemoryCeiling(100, time.Hour),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		func(c *LRUCache) { c.heapUsage = heap.Load },
+	)
+	defer empty.Close()
+	heap.Store(150)
+	for i := 0; i < 3; i++ {
+		if n := empty.checkMemoryCeiling(); n != 0 {
+			t.Errorf("expected nothing to evict from an empty cache, got %d", n)
+		}
+	}
+	if stats := empty.EvictionStats(); stats.MemoryCeilingReached != 0 {
+		t.Errorf("expected no memory ceiling events without evictions, got %+v", stats)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no warnings without evictions, got %q", logs.String())
+	}
+}
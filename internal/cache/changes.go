@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// tombstoneRetention — время хранения записей об удалённых ключах для Changes.
+const tombstoneRetention = 5 * time.Minute
+
+// tombstoneLimit — максимальное число хранимых записей об удалённых ключах.
+const tombstoneLimit = 10000
+
+// ErrChangesExpired возвращается Changes, если записи об удалениях после запрошенной
+// версии уже не хранятся. Клиенту следует заново получить все элементы.
+var ErrChangesExpired = errors.New("changes since version are no longer available")
+
+// Change описывает изменение ключа для инкрементальной синхронизации.
+type Change struct {
+	Key     string // Ключ элемента
+	Version uint64 // Версия кеша, на которой произошло изменение
+	Deleted bool   // Элемент удалён, истёк или вытеснен
+}
+
+// tombstone — запись об удалённом ключе.
+type tombstone struct {
+	key       string    // Ключ удалённого элемента
+	version   uint64    // Версия кеша, на которой элемент удалён
+	removedAt time.Time // Время удаления
+}
+
+// Changes возвращает изменения ключей после версии since в порядке возрастания версии
+// и текущую версию кеша, которую следует передать в следующий вызов. Каждая запись и
+// каждое удаление (явное, по TTL или вытеснение) увеличивают версию кеша; для ключа
+// возвращается только последнее изменение. Отрицательные записи считаются удалёнными.
+//
+// Записи об удалениях хранятся ограниченное время (tombstoneRetention) и в ограниченном
+// числе (tombstoneLimit). Если часть удалений после since уже забыта, а также после
+// EvictAll возвращается ErrChangesExpired. При since, равном 0, возвращаются все
+// актуальные элементы без удалений — так выполняется начальная синхронизация.
+func (c *LRUCache) Changes(ctx context.Context, since uint64) (changes []Change, version uint64, err error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+	}()
+	c.drainPromotions()
+
+	// Истёкшие элементы удаляются, чтобы их удаление попало в список изменений
+	c.purgeExpired(nil, &evicted)
+	c.pruneTombstones(c.now())
+	if since > 0 && since < c.changesHorizon {
+		return nil, c.version, ErrChangesExpired
+	}
+
+	for node := c.front(); node != nil; node = c.nextNode(node) {
+		if node.version > since && !(since == 0 && node.negative) {
+			changes = append(changes, Change{Key: node.key, Version: node.version, Deleted: node.negative})
+		}
+	}
+	if since > 0 {
+		latest := make(map[string]uint64)
+		for _, t := range c.tombstones {
+			if t.version > since {
+				latest[t.key] = t.version
+			}
+		}
+		for key, v := range latest {
+			// Ключ, записанный заново после удаления, уже учтён как актуальный
+			if _, exists := c.cache[key]; !exists {
+				changes = append(changes, Change{Key: key, Version: v, Deleted: true})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Version < changes[j].Version })
+	return changes, c.version, nil
+}
+
+// addTombstone запоминает удаление ключа для Changes. Вызывается под блокировкой на запись.
+func (c *LRUCache) addTombstone(key string) {
+	c.version++
+	now := c.now()
+	c.tombstones = append(c.tombstones, tombstone{key: key, version: c.version, removedAt: now})
+	c.pruneTombstones(now)
+}
+
+// pruneTombstones забывает устаревшие и лишние записи об удалениях, запоминая
+// наибольшую забытую версию. Вызывается под блокировкой на запись.
+func (c *LRUCache) pruneTombstones(now time.Time) {
+	n := 0
+	for n < len(c.tombstones) && (len(c.tombstones)-n > tombstoneLimit || now.Sub(c.tombstones[n].removedAt) > tombstoneRetention) {
+		c.changesHorizon = c.tombstones[n].version
+		n++
+	}
+	// Срез сдвигается без копирования; забытые записи освобождаются при следующем росте среза
+	c.tombstones = c.tombstones[n:]
+}
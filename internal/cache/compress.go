@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// compressedValue хранит сжатое значение элемента.
+type compressedValue struct {
+	data []byte // Значение, сжатое gzip
+	text bool   // Исходное значение было строкой, а не []byte
+}
+
+// WithCompression включает прозрачное сжатие gzip строковых и двоичных значений,
+// размер которых превышает threshold байт. Значение сжимается при записи и распаковывается
+// при чтении; в бюджете памяти учитывается размер сжатого значения. Если сжатие
+// не уменьшает размер, значение хранится как есть. Значение 0 отключает сжатие.
+func WithCompression(threshold int) Option {
+	return func(c *LRUCache) {
+		c.compressThreshold = threshold
+	}
+}
+
+// compressValue сжимает значение, если сжатие включено и выгодно.
+// Возвращает значение для хранения и признак сжатия.
+func (c *LRUCache) compressValue(value interface{}) (interface{}, bool) {
+	if c.compressThreshold <= 0 {
+		return value, false
+	}
+
+	var raw []byte
+	text := false
+	switch v := value.(type) {
+	case string:
+		raw, text = []byte(v), true
+	case []byte:
+		raw = v
+	default:
+		return value, false
+	}
+	if len(raw) <= c.compressThreshold {
+		return value, false
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return value, false
+	}
+	if err := zw.Close(); err != nil || buf.Len() >= len(raw) {
+		return value, false
+	}
+	return compressedValue{data: buf.Bytes(), text: text}, true
+}
+
+// decompress распаковывает сжатое значение в исходный тип.
+func (v compressedValue) decompress() (interface{}, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(v.data))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if v.text {
+		return string(raw), nil
+	}
+	return raw, nil
+}
+
+// nodeValue возвращает исходное значение узла, распаковывая его при необходимости.
+// Сжатые данные создаются самим кешем, поэтому ошибка распаковки означает их повреждение;
+// в этом случае возвращается nil.
+func nodeValue(node *Node) interface{} {
+	if !node.compressed {
+		return node.value
+	}
+	value, err := node.value.(compressedValue).decompress()
+	if err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRUCache_Compression(t *testing.T) {
+	c := NewLRUCache(10, 1*time.Minute, WithCompression(1024))
+	large := strings.Repeat("compressible ", 1000)
+
+	_ = c.Put(context.Background(), "text", large, 0)
+	_ = c.Put(context.Background(), "binary", []byte(large), 0)
+	_ = c.Put(context.Background(), "small", "short", 0)
+
+	// Сжатое значение занимает меньше места, чем исходное
+	if used := c.UsedBytes(); used >= int64(len(large)) {
+		t.Errorf("expected compressed storage smaller than %d bytes, got %d", len(large), used)
+	}
+	if !c.cache["text"].compressed || !c.cache["binary"].compressed {
+		t.Error("expected large values to be stored compressed")
+	}
+	if c.cache["small"].compressed {
+		t.Error("expected value below threshold to be stored as is")
+	}
+
+	value, _, err := c.Get(context.Background(), "text")
+	if err != nil || value != large {
+		t.Errorf("expected original string, got error %v", err)
+	}
+	value, _, err = c.Get(context.Background(), "binary")
+	if data, ok := value.([]byte); err != nil || !ok || !bytes.Equal(data, []byte(large)) {
+		t.Errorf("expected original bytes, got %T with error %v", value, err)
+	}
+}
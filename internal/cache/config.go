@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"cache_service/config"
+	"log/slog"
+)
+
+// ConfigOptions собирает опции кэша из конфигурации сервиса. Используется при
+// запуске сервера и в самопроверке, чтобы оба работали с одинаково настроенным кэшем.
+//
+// Параметры:
+// - cfg: конфигурация сервиса.
+// - log: логгер кэша; nil отключает логирование.
+//
+// Возвращает:
+// - Список опций для NewLRUCache.
+// - Ошибку, если правила TTL для шаблонов ключей заданы некорректно.
+func ConfigOptions(cfg *config.Config, log *slog.Logger) ([]Option, error) {
+	ttlOverrides, err := cfg.TTLOverrideRules()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{
+		WithMaxBytes(cfg.CacheMaxBytes),
+		WithCleanupInterval(cfg.CacheCleanupInterval),
+		WithMemoryCeiling(uint64(cfg.MemoryCeiling), cfg.MemoryCheckInterval),
+		WithSnapshotInterval(cfg.SnapshotInterval),
+		WithCompression(cfg.CacheCompressAbove),
+		WithTTLJitter(cfg.CacheTTLJitter),
+		WithMaxTTL(cfg.MaxTTL),
+		WithMinTTL(cfg.MinTTL),
+		WithSoftLimit(cfg.CacheSoftLimit),
+		WithExpiredFirst(cfg.EvictExpiredFirst),
+		WithPrefixQuota(cfg.CachePrefixSeparator, cfg.CachePrefixQuota),
+		WithCaseInsensitiveKeys(cfg.CaseInsensitiveKeys),
+		WithFullnessThresholds(cfg.CacheFullness...),
+		WithLogger(log),
+	}
+	for _, override := range ttlOverrides {
+		opts = append(opts, WithTTLOverride(override.Pattern, override.TTL))
+	}
+	return opts, nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"time"
+)
+
+// evictedEntry — вытесненный элемент, ожидающий вызова обработчика вытеснения.
+type evictedEntry struct {
+	key   string      // Ключ элемента
+	value interface{} // Значение элемента
+}
+
+// WithEvictionRateLimit ограничивает частоту вызовов обработчика WithOnEvict, чтобы
+// массовое вытеснение (например, одновременное истечение TTL множества элементов)
+// не перегрузило медленное хранилище, в которое обработчик сохраняет элементы.
+//
+// Обработчик вызывается асинхронно одной фоновой горутиной в порядке вытеснения,
+// не чаще perSecond раз в секунду с допустимым всплеском burst вызовов (token bucket).
+// Ожидающие элементы хранятся в очереди на queueSize элементов. Если обработчик не
+// успевает и очередь заполнена, операция, вызвавшая вытеснение, блокируется до
+// освобождения места, замедляя запись и тем самым дальнейшие вытеснения.
+//
+// Компромисс: вытесненные элементы уже недоступны в кеше, но до вызова обработчика
+// занимают память в очереди, поэтому общий объём хранимых данных может временно
+// превышать ёмкость кеша на размер очереди. При Close оставшиеся в очереди элементы
+// передаются обработчику без ограничения частоты. Значение perSecond <= 0 отключает
+// ограничение; burst и queueSize меньше 1 заменяются на 1.
+func WithEvictionRateLimit(perSecond float64, burst, queueSize int) Option {
+	return func(c *LRUCache) {
+		c.evictRate = perSecond
+		c.evictBurst = max(burst, 1)
+		c.evictQueueSize = max(queueSize, 1)
+	}
+}
+
+// enqueueEvicted передаёт элемент фоновой горутине runEvictions, ожидая места в очереди.
+// После закрытия кеша обработчик вызывается синхронно. Постановка в очередь выполняется
+// под evictMutex на чтение, поэтому Close, захватив его на запись, знает, что после
+// этого в очередь ничего не попадёт, и передаёт обработчику её остаток.
+func (c *LRUCache) enqueueEvicted(entry evictedEntry) {
+	c.evictMutex.RLock()
+	defer c.evictMutex.RUnlock()
+	if !c.closed.Load() {
+		select {
+		case c.evictQueue <- entry:
+			return
+		case <-c.done:
+		}
+	}
+	c.onEvict(entry.key, entry.value)
+}
+
+// runEvictions вызывает обработчик вытеснения для элементов очереди с ограничением
+// частоты до закрытия кеша, после чего передаёт обработчику остаток очереди.
+func (c *LRUCache) runEvictions() {
+	defer c.background.Done()
+
+	bucket := newTokenBucket(c.evictRate, c.evictBurst)
+	for {
+		select {
+		case entry := <-c.evictQueue:
+			if !bucket.wait(c.done) {
+				c.onEvict(entry.key, entry.value)
+				c.flushEvicted()
+				return
+			}
+			c.onEvict(entry.key, entry.value)
+		case <-c.done:
+			c.flushEvicted()
+			return
+		}
+	}
+}
+
+// flushEvicted передаёт обработчику вытеснения все элементы, оставшиеся в очереди.
+func (c *LRUCache) flushEvicted() {
+	for {
+		select {
+		case entry := <-c.evictQueue:
+			c.onEvict(entry.key, entry.value)
+		default:
+			return
+		}
+	}
+}
+
+// tokenBucket ограничивает частоту событий: маркеры накапливаются со скоростью rate
+// в секунду до burst, и каждое событие расходует один маркер.
+type tokenBucket struct {
+	rate   float64   // Скорость пополнения в маркерах в секунду
+	burst  float64   // Максимальное число накопленных маркеров
+	tokens float64   // Текущее число маркеров
+	last   time.Time // Время последнего пополнения
+}
+
+// newTokenBucket создаёт заполненное хранилище маркеров.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait ожидает появления маркера и расходует его. Возвращает false, если ожидание
+// прервано закрытием done.
+func (b *tokenBucket) wait(done <-chan struct{}) bool {
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-done:
+			return false
+		}
+		b.tokens, b.last = 1, b.last.Add(delay)
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Скользящее окно статистики попаданий делится на hitRatioBuckets интервалов по
+// hitRatioBucketWidth; устаревшие интервалы обнуляются при повторном использовании.
+const (
+	hitRatioBuckets     = 12
+	hitRatioBucketWidth = 5 * time.Second
+)
+
+// HitRatioWindow — длительность скользящего окна, за которое вычисляется HitRatio.
+const HitRatioWindow = hitRatioBuckets * hitRatioBucketWidth
+
+// HitStats содержит статистику чтений по ключу за скользящее окно HitRatioWindow.
+type HitStats struct {
+	Hits   uint64  // Число чтений, нашедших элемент (включая отрицательные записи)
+	Misses uint64  // Число чтений отсутствующих или истёкших элементов
+	Ratio  float64 // Доля попаданий hits / (hits + misses); 0, если чтений не было
+}
+
+// lookupBucket — счётчики чтений за один интервал окна.
+type lookupBucket struct {
+	slot   atomic.Int64  // Номер интервала, к которому относятся счётчики
+	hits   atomic.Uint64 // Число попаданий за интервал
+	misses atomic.Uint64 // Число промахов за интервал
+}
+
+// lookupWindow — кольцевой буфер счётчиков чтений. Счётчики обновляются без блокировок,
+// поэтому чтение, совпавшее с обнулением интервала, может быть не учтено; для оценки
+// доли попаданий такая погрешность несущественна.
+type lookupWindow struct {
+	buckets [hitRatioBuckets]lookupBucket // Счётчики интервалов окна
+}
+
+// record учитывает чтение в интервале, содержащем момент now.
+func (w *lookupWindow) record(now time.Time, hit bool) {
+	slot := now.UnixNano() / int64(hitRatioBucketWidth)
+	bucket := &w.buckets[slot%hitRatioBuckets]
+	if old := bucket.slot.Load(); old != slot && bucket.slot.CompareAndSwap(old, slot) {
+		bucket.hits.Store(0)
+		bucket.misses.Store(0)
+	}
+	if hit {
+		bucket.hits.Add(1)
+	} else {
+		bucket.misses.Add(1)
+	}
+}
+
+// stats суммирует счётчики интервалов, попадающих в окно, заканчивающееся в now.
+func (w *lookupWindow) stats(now time.Time) HitStats {
+	current := now.UnixNano() / int64(hitRatioBucketWidth)
+	var stats HitStats
+	for i := range w.buckets {
+		bucket := &w.buckets[i]
+		if age := current - bucket.slot.Load(); age < 0 || age >= hitRatioBuckets {
+			continue
+		}
+		stats.Hits += bucket.hits.Load()
+		stats.Misses += bucket.misses.Load()
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.Ratio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// recordLookup учитывает результат чтения GetItem в статистике попаданий. Ошибки, не
+// связанные с наличием ключа (пустой ключ, отмена контекста, закрытый кеш), не учитываются.
+func (c *LRUCache) recordLookup(err error) {
+	switch {
+	case err == nil, errors.Is(err, ErrNegativeCached):
+		c.lookups.record(c.now(), true)
+	case errors.Is(err, errKeyNotFound), errors.Is(err, errExpiredKey):
+		c.lookups.record(c.now(), false)
+	}
+}
+
+// HitRatio возвращает число попаданий и промахов чтения по ключу (Get, GetItem, GetMany
+// и основанные на них вызовы) за последние HitRatioWindow и долю попаданий. В отличие
+// от счётчиков за всё время работы, значение отражает текущую нагрузку.
+func (c *LRUCache) HitRatio() HitStats {
+	return c.lookups.stats(c.now())
+}
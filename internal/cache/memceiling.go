@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"runtime"
+	"time"
+)
+
+// memoryCeilingFraction — доля элементов, вытесняемых за одну проверку при превышении
+// предела памяти. Вытеснение частями позволяет сборщику мусора освободить память
+// до следующей проверки, не очищая кеш целиком из-за одного всплеска.
+const memoryCeilingFraction = 10
+
+// WithMemoryCeiling задаёт жёсткий предел объёма кучи процесса в байтах. Раз в interval
+// фоновая горутина читает runtime.MemStats и, если HeapAlloc превышает limit, экстренно
+// вытесняет давно использованные элементы (десятую часть, но не меньше одного) с причиной
+// EvictReasonCapacity и запускает сборку мусора. Каждое срабатывание, вытеснившее хотя бы
+// один элемент, записывается в журнал и учитывается в EvictionStats.MemoryCeilingReached.
+//
+// В отличие от WithMaxBytes, учитывающего оценочный размер элементов, предел относится
+// ко всей памяти процесса и защищает от завершения по OOM. Чтение MemStats ненадолго
+// останавливает программу, поэтому interval не стоит делать меньше секунды.
+// Значение limit или interval, равное 0 (по умолчанию), отключает проверку.
+func WithMemoryCeiling(limit uint64, interval time.Duration) Option {
+	return func(c *LRUCache) {
+		c.memoryCeiling = limit
+		c.memoryInterval = interval
+	}
+}
+
+// readHeapAlloc возвращает объём памяти, занятой объектами в куче.
+func readHeapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// runMemoryCeiling проверяет объём кучи раз в interval до закрытия кеша.
+func (c *LRUCache) runMemoryCeiling(interval time.Duration) {
+	defer c.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.checkMemoryCeiling()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// checkMemoryCeiling вытесняет часть давно использованных элементов, если объём кучи
+// превышает предел, и возвращает число вытесненных элементов.
+func (c *LRUCache) checkMemoryCeiling() int {
+	heap := c.heapUsage()
+	if heap <= c.memoryCeiling {
+		return 0
+	}
+
+	var evicted []*Node
+	c.mutex.Lock()
+	c.drainPromotions()
+	target := max(len(c.cache)/memoryCeilingFraction, 1)
+	count := 0
+	for count < target {
+		node := c.victim(nil)
+		if node == nil {
+			break
+		}
+		c.removeElement(node, EvictReasonCapacity, &evicted)
+		count++
+	}
+	if count == 0 {
+		// Кеш пуст или все элементы закреплены: память занята не кешем, и
+		// предупреждение на каждой проверке только засоряло бы журнал
+		c.mutex.Unlock()
+		return 0
+	}
+	c.evictionStats.MemoryCeilingReached++
+	c.evictionStats.Passes++
+	c.evictionStats.Evicted += uint64(count)
+	c.evictionStats.LastPassEvicted = count
+	remaining := len(c.cache)
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
+
+	if c.log != nil {
+		c.log.Warn("Memory ceiling exceeded, evicting entries",
+			"heap_bytes", heap,
+			"ceiling_bytes", c.memoryCeiling,
+			"evicted", count,
+			"remaining", remaining,
+		)
+	}
+	runtime.GC()
+	return count
+}
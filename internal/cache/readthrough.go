@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// refreshTimeout ограничивает фоновое обновление устаревшего значения из источника.
+const refreshTimeout = 30 * time.Second
+
+// WithStaleWindow задаёт окно stale-while-revalidate: истёкший элемент хранится в кеше
+// ещё window после истечения TTL и доступен через GetStale. ReadThrough в этом окне
+// возвращает устаревшее значение сразу и обновляет его из источника в фоне.
+// Для остальных операций элемент считается истёкшим. 0 отключает окно.
+func WithStaleWindow(window time.Duration) Option {
+	return func(c *LRUCache) {
+		c.staleWindow = window
+	}
+}
+
+// GetStale возвращает элемент по ключу так же, как GetItem, а если его TTL истёк, но
+// не более чем на окно WithStaleWindow назад — устаревшее значение с флагом stale.
+// Чтение устаревшего значения не перемещает элемент в списке.
+func (c *LRUCache) GetStale(ctx context.Context, key string) (item Item, stale bool, err error) {
+	item, err = c.GetItem(ctx, key)
+	if !errors.Is(err, errExpiredKey) || c.staleWindow <= 0 {
+		return item, false, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	node, exists := c.cache[c.normalizeKey(key)]
+	if !exists || node == nil || c.removable(node, c.now()) {
+		return Item{}, false, errExpiredKey
+	}
+	if !node.expired(c.now()) {
+		// Элемент успели перезаписать между вызовами
+		item, err = nodeResult(node)
+		return item, false, err
+	}
+	if node.negative {
+		return Item{}, false, errExpiredKey
+	}
+	return nodeItem(node), true, nil
+}
+
+// ErrSourceNotFound возвращается источником данных, если элемент по ключу отсутствует.
+var ErrSourceNotFound = errors.New("key not found in source")
+
+// Source описывает источник данных для кеша со сквозным чтением.
+type Source interface {
+	// Load загружает значение по ключу и возвращает его вместе с TTL.
+	// Если элемент отсутствует, возвращает ErrSourceNotFound.
+	Load(ctx context.Context, key string) (interface{}, time.Duration, error)
+}
+
+// ReadThrough представляет собой кеш со сквозным чтением: при промахе значение
+// загружается из источника, сохраняется в LRU-кеш и возвращается вызывающему.
+type ReadThrough struct {
+	cache       *LRUCache     // Кеш для хранения загруженных значений
+	source      Source        // Источник данных
+	negativeTTL time.Duration // Время жизни отрицательного результата (0 — не кешировать)
+	refreshing  sync.Map      // Нормализованные ключи, для которых выполняется фоновое обновление устаревшего значения
+}
+
+// NewReadThrough создаёт кеш со сквозным чтением поверх LRU-кеша.
+//
+// Параметры:
+// - c: LRU-кеш для хранения значений.
+// - source: источник данных.
+// - negativeTTL: время жизни записи об отсутствии элемента в источнике; 0 отключает кеширование отрицательных результатов.
+func NewReadThrough(c *LRUCache, source Source, negativeTTL time.Duration) *ReadThrough {
+	return &ReadThrough{
+		cache:       c,
+		source:      source,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// Get возвращает значение по ключу. При промахе значение загружается из источника;
+// одновременные промахи по одному ключу приводят к единственной загрузке.
+// Если элемент отсутствует в источнике, возвращается ErrSourceNotFound.
+func (rt *ReadThrough) Get(ctx context.Context, key string) (interface{}, error) {
+	value, _, err := rt.GetWithStale(ctx, key)
+	return value, err
+}
+
+// GetWithStale работает как Get, но в окне WithStaleWindow кеша после истечения TTL
+// возвращает устаревшее значение с флагом stale, не дожидаясь источника. Обновление
+// значения из источника запускается в фоне, не более одного одновременно для ключа;
+// при ошибке источника устаревшее значение остаётся в кеше до конца окна.
+func (rt *ReadThrough) GetWithStale(ctx context.Context, key string) (value interface{}, stale bool, err error) {
+	if rt.cache.staleWindow > 0 {
+		item, stale, err := rt.cache.GetStale(ctx, key)
+		if stale {
+			rt.refresh(key)
+			return item.Value, true, nil
+		}
+		if err == nil {
+			return item.Value, false, nil
+		}
+	}
+
+	value, err = rt.load(ctx, key, 0)
+	return value, false, err
+}
+
+// load возвращает значение из кеша, а при промахе загружает его из источника.
+// Если timeout больше 0, загрузка из источника ограничена этим временем.
+func (rt *ReadThrough) load(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	value, err := rt.cache.getOrLoad(ctx, key, func(ctx context.Context) (interface{}, time.Duration, error) {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		value, ttl, err := rt.source.Load(ctx, key)
+		if errors.Is(err, ErrSourceNotFound) && rt.negativeTTL > 0 {
+			return nil, rt.negativeTTL, ErrNegativeCached
+		}
+		return value, ttl, err
+	})
+	if errors.Is(err, ErrNegativeCached) {
+		return nil, ErrSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// refresh запускает фоновую загрузку значения key из источника, если она ещё не выполняется.
+// Загрузка не зависит от контекста запроса, вернувшего устаревшее значение, ограничена
+// refreshTimeout и объединяется с одновременными промахами по тому же ключу. Паника
+// источника не завершает процесс: она записывается в журнал, а устаревшее значение
+// остаётся в кеше до конца окна.
+func (rt *ReadThrough) refresh(key string) {
+	key = rt.cache.normalizeKey(key)
+	if _, running := rt.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer rt.refreshing.Delete(key)
+		defer func() {
+			if rec := recover(); rec != nil && rt.cache.log != nil {
+				rt.cache.log.Error("Stale entry refresh panicked",
+					"key", key, "panic", rec, "stack", string(debug.Stack()))
+			}
+		}()
+		ctx := context.Background()
+		_, err := rt.load(ctx, key, refreshTimeout)
+		if errors.Is(err, ErrSourceNotFound) && rt.negativeTTL <= 0 {
+			_, _ = rt.cache.Evict(ctx, key)
+		}
+	}()
+}
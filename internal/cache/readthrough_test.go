@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSource — источник данных для тестов, подсчитывающий число загрузок.
+type fakeSource struct {
+	data  map[string]interface{}
+	loads atomic.Int32
+}
+
+func (s *fakeSource) Load(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	s.loads.Add(1)
+	value, ok := s.data[key]
+	if !ok {
+		return nil, 0, ErrSourceNotFound
+	}
+	return value, time.Minute, nil
+}
+
+func TestReadThrough_Get(t *testing.T) {
+	source := &fakeSource{data: map[string]interface{}{"key1": "value1"}}
+	rt := NewReadThrough(NewLRUCache(10, time.Minute), source, 0)
+
+	for i := 0; i < 2; i++ {
+		val, err := rt.Get(context.Background(), "key1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if val != "value1" {
+			t.Errorf("expected value1, got %v", val)
+		}
+	}
+
+	// Второй Get обслуживается из кеша
+	if n := source.loads.Load(); n != 1 {
+		t.Errorf("expected 1 load, got %d", n)
+	}
+}
+
+func TestReadThrough_NegativeCaching(t *testing.T) {
+	source := &fakeSource{data: map[string]interface{}{}}
+	rt := NewReadThrough(NewLRUCache(10, time.Minute), source, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := rt.Get(context.Background(), "missing"); !errors.Is(err, ErrSourceNotFound) {
+			t.Errorf("expected ErrSourceNotFound, got %v", err)
+		}
+	}
+	if n := source.loads.Load(); n != 1 {
+		t.Errorf("expected 1 load with negative caching, got %d", n)
+	}
+
+	// Без кеширования отрицательных результатов каждый промах обращается к источнику
+	source = &fakeSource{data: map[string]interface{}{}}
+	rt = NewReadThrough(NewLRUCache(10, time.Minute), source, 0)
+	_, _ = rt.Get(context.Background(), "missing")
+	_, _ = rt.Get(context.Background(), "missing")
+	if n := source.loads.Load(); n != 2 {
+		t.Errorf("expected 2 loads without negative caching, got %d", n)
+	}
+}
+
+// blockingSource — источник данных, загрузка которого ждёт сигнала release.
+type blockingSource struct {
+	value   atomic.Value
+	loads   atomic.Int32
+	release chan struct{}
+	loaded  chan struct{}
+}
+
+func (s *blockingSource) Load(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	s.loads.Add(1)
+	<-s.release
+	defer func() { s.loaded <- struct{}{} }()
+	return s.value.Load(), time.Minute, nil
+}
+
+func TestReadThrough_StaleWhileRevalidate(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now), WithStaleWindow(30*time.Second))
+	source := &blockingSource{release: make(chan struct{}), loaded: make(chan struct{}, 1)}
+	rt := NewReadThrough(c, source, 0)
+	source.value.Store("old")
+	_ = c.Put(context.Background(), "key1", "old", time.Minute)
+	source.value.Store("new")
+
+	// В окне после истечения TTL возвращается устаревшее значение без ожидания источника
+	clock.Advance(time.Minute + 10*time.Second)
+	for i := 0; i < 5; i++ {
+		value, stale, err := rt.GetWithStale(context.Background(), "key1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value != "old" || !stale {
+			t.Errorf("expected stale old value, got %v (stale=%v)", value, stale)
+		}
+	}
+	if _, _, err := c.Get(context.Background(), "key1"); !errors.Is(err, errExpiredKey) {
+		t.Errorf("expected plain Get to treat the stale entry as expired, got %v", err)
+	}
+
+	// Повторные чтения не запускают новых обновлений, пока первое не завершилось
+	close(source.release)
+	<-source.loaded
+	deadline := time.Now().Add(time.Second)
+	for {
+		value, stale, err := rt.GetWithStale(context.Background(), "key1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !stale {
+			if value != "new" {
+				t.Errorf("expected refreshed value, got %v", value)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := source.loads.Load(); n != 1 {
+		t.Errorf("expected exactly 1 refresh, got %d", n)
+	}
+
+	// За пределами окна элемент удаляется, и значение загружается синхронно
+	clock.Advance(2 * time.Minute)
+	if _, stale, err := c.GetStale(context.Background(), "key1"); !errors.Is(err, errExpiredKey) || stale {
+		t.Errorf("expected expired entry outside the window, got stale=%v err=%v", stale, err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected entry outside the window to be removed, got %d entries", c.Len())
+	}
+}
+
+// panicSource — источник данных, загрузка из которого паникует.
+type panicSource struct {
+	loads atomic.Int32
+}
+
+func (s *panicSource) Load(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	s.loads.Add(1)
+	panic("source failure")
+}
+
+func TestReadThrough_StaleRefreshCaseInsensitive(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now), WithStaleWindow(30*time.Second), WithCaseInsensitiveKeys(true))
+	source := &blockingSource{release: make(chan struct{}), loaded: make(chan struct{}, 1)}
+	source.value.Store("new")
+	rt := NewReadThrough(c, source, 0)
+	_ = c.Put(context.Background(), "key1", "old", time.Minute)
+	clock.Advance(time.Minute + 10*time.Second)
+
+	// Варианты ключа в разном регистре запускают одно обновление
+	for _, key := range []string{"key1", "KEY1", "Key1"} {
+		if _, stale, err := rt.GetWithStale(context.Background(), key); err != nil || !stale {
+			t.Fatalf("expected stale value for %s, got stale=%v err=%v", key, stale, err)
+		}
+	}
+	close(source.release)
+	<-source.loaded
+	waitRefreshed(t, rt)
+	if n := source.loads.Load(); n != 1 {
+		t.Errorf("expected exactly 1 refresh, got %d", n)
+	}
+}
+
+func TestReadThrough_StaleRefreshPanic(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLRUCache(10, time.Minute, WithClock(clock.Now), WithStaleWindow(30*time.Second))
+	source := &panicSource{}
+	rt := NewReadThrough(c, source, 0)
+	_ = c.Put(context.Background(), "key1", "old", time.Minute)
+	clock.Advance(time.Minute + 10*time.Second)
+
+	// Паника источника при фоновом обновлении не завершает процесс
+	if _, stale, err := rt.GetWithStale(context.Background(), "key1"); err != nil || !stale {
+		t.Fatalf("expected stale value, got stale=%v err=%v", stale, err)
+	}
+	waitRefreshed(t, rt)
+
+	// Устаревшее значение остаётся доступным, а следующее чтение снова запускает обновление
+	value, stale, err := rt.GetWithStale(context.Background(), "key1")
+	if err != nil || !stale || value != "old" {
+		t.Fatalf("expected stale old value, got %v stale=%v err=%v", value, stale, err)
+	}
+	waitRefreshed(t, rt)
+	if n := source.loads.Load(); n != 2 {
+		t.Errorf("expected 2 refresh attempts, got %d", n)
+	}
+}
+
+// waitRefreshed ожидает завершения фоновых обновлений rt.
+func waitRefreshed(t *testing.T, rt *ReadThrough) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		running := false
+		rt.refreshing.Range(func(key, value interface{}) bool {
+			running = true
+			return false
+		})
+		if !running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
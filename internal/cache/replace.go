@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errDuplicateKey возвращается ReplaceAll, если набор содержит один ключ дважды.
+var errDuplicateKey = errors.New("duplicate key")
+
+// ReplaceAll атомарно заменяет всё содержимое кеша набором entries под одной блокировкой
+// на запись: читатели видят либо прежнее, либо новое содержимое целиком, без промежуточного
+// пустого или частично загруженного состояния. Порядок entries задаёт порядок списка:
+// первый элемент становится недавно использованным, как в Items.
+//
+// Для каждого элемента используются Key, Value, ExpiresAt и Tags. Нулевое ExpiresAt означает
+// TTL по умолчанию для ключа, а уже истёкшие элементы пропускаются. Если набор не
+// умещается в ёмкость или квоту префикса, давно использованные элементы вытесняются как обычно.
+// При некорректном элементе (пустой ключ, nil, повтор ключа, размер больше бюджета памяти)
+// содержимое кеша не изменяется. Прежние элементы удаляются без вызова обработчика
+// вытеснения; клиентам Changes, как после EvictAll, требуется полная синхронизация.
+func (c *LRUCache) ReplaceAll(ctx context.Context, entries []Item) error {
+	if err := c.checkOpen(ctx); err != nil {
+		return err
+	}
+
+	// Узлы готовятся до захвата блокировки, чтобы сжатие значений не задерживало читателей
+	now := c.now()
+	nodes := make([]*Node, 0, len(entries))
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		key := strings.Clone(c.normalizeKey(entry.Key))
+		if key == "" {
+			return errEmptyKey
+		}
+		if entry.Value == nil {
+			return fmt.Errorf("key %q: %w", entry.Key, ErrNilValue)
+		}
+		if _, dup := seen[key]; dup {
+			return fmt.Errorf("key %q: %w", entry.Key, errDuplicateKey)
+		}
+		seen[key] = struct{}{}
+
+		var ttl time.Duration
+		if !entry.ExpiresAt.IsZero() {
+			if ttl = entry.ExpiresAt.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		stored, compressed := c.compressValue(entry.Value)
+		tags := cloneTags(entry.Tags)
+		size := sizeOf(key, stored) + tags.size()
+		if c.maxBytes > 0 && size > c.maxBytes {
+			return fmt.Errorf("key %q: %w", entry.Key, errTooLarge)
+		}
+		lifetime, _ := c.getTTL(key, ttl, false)
+		var expiresAt time.Time
+		if lifetime > 0 {
+			expiresAt = now.Add(lifetime)
+		}
+		node := &Node{
+			key:        key,
+			value:      stored,
+			compressed: compressed,
+			TTL:        expiresAt,
+			lifetime:   lifetime,
+			tags:       tags,
+			modifiedAt: now,
+			promotedAt: now,
+			size:       size,
+			cost:       1,
+		}
+		node.touch(now)
+		nodes = append(nodes, node)
+	}
+
+	var evicted []*Node
+	c.mutex.Lock()
+	defer func() {
+		c.mutex.Unlock()
+		c.notifyEvicted(evicted)
+		for _, node := range nodes {
+			c.notifyWaiters(node.key)
+		}
+	}()
+	c.drainPromotions()
+
+	// Прежние элементы удаляются без removeElement, поэтому в журнал пишется одна сводная запись
+	if c.log != nil && len(c.cache) > 0 {
+		c.log.Debug("All entries removed from cache", "count", len(c.cache), "reason", EvictReasonDeleted)
+	}
+	c.cache = make(map[string]*Node, len(nodes))
+	if c.prefixCounts != nil {
+		c.prefixCounts = make(map[string]int)
+	}
+	c.head, c.tail = nil, nil
+	c.protectedHead, c.protectedTail, c.protectedLen = nil, nil, 0
+	c.usedBytes, c.usedCost = 0, 0
+	c.version++
+	c.changesHorizon = c.version
+	c.tombstones = nil
+
+	// Элементы добавляются в начало списка, поэтому обходятся с конца
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		c.version++
+		node.version = c.version
+		if prefix, ok := c.keyPrefix(node.key); ok {
+			if c.prefixCounts[prefix] >= c.prefixQuota {
+				c.evictPrefix(prefix, &evicted)
+			}
+			c.prefixCounts[prefix]++
+		}
+		c.cache[node.key] = node
+		c.addNode(node)
+		c.usedBytes += node.size
+		c.usedCost += node.cost
+	}
+	c.evictOverflow(nil, &evicted)
+	c.checkFullness()
+	return nil
+}
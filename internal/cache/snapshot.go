@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Snapshot — неизменяемый снимок актуальных элементов кеша для чтения без блокировок
+// (см. WithSnapshotInterval). Снимок нельзя изменять: он разделяется всеми читателями.
+type Snapshot struct {
+	Items   []Item    // Элементы в порядке от недавно использованных к давно использованным
+	TakenAt time.Time // Время снятия снимка
+}
+
+// WithSnapshotInterval включает режим чтения из снимка: раз в interval кеш снимает
+// неизменяемую копию актуальных элементов, и массовые чтения (полный список, экспорт)
+// обслуживаются из неё, вовсе не обращаясь к блокировке кеша и не задерживая запись.
+// Данные снимка отстают от кеша не более чем на interval плюс время его снятия;
+// записи и удаления после снятия в нём не видны до следующего обновления.
+// Снимок можно обновить досрочно методом RefreshSnapshot. Горутина обновления
+// останавливается методом Close. Значение 0 (по умолчанию) отключает режим.
+func WithSnapshotInterval(interval time.Duration) Option {
+	return func(c *LRUCache) {
+		c.snapshotInterval = interval
+	}
+}
+
+// Snapshot возвращает последний снятый снимок или nil, если режим чтения из снимка
+// отключён. Вызов не захватывает блокировку кеша.
+func (c *LRUCache) Snapshot() *Snapshot {
+	return c.snapshot.Load()
+}
+
+// RefreshSnapshot снимает новый снимок актуальных элементов и делает его текущим.
+// Снимок строится под блокировкой на чтение, как Items. Может вызываться и при
+// отключённом режиме, после чего Snapshot возвращает снятый снимок.
+func (c *LRUCache) RefreshSnapshot(ctx context.Context) (*Snapshot, error) {
+	takenAt := c.now()
+	items, err := c.Items(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{Items: items, TakenAt: takenAt}
+	c.snapshot.Store(snapshot)
+	return snapshot, nil
+}
+
+// runSnapshots периодически обновляет снимок до закрытия кеша.
+func (c *LRUCache) runSnapshots(interval time.Duration) {
+	defer c.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = c.RefreshSnapshot(context.Background())
+		case <-c.done:
+			return
+		}
+	}
+}
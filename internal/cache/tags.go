@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+)
+
+// cloneTags возвращает копию тегов, чтобы хранимый элемент не разделял карту ни с
+// переданными при записи тегами, ни с возвращаемыми вызывающему. Пустые теги заменяются на nil.
+func cloneTags(tags Tags) Tags {
+	if len(tags) == 0 {
+		return nil
+	}
+	clone := make(Tags, len(tags))
+	for name, value := range tags {
+		clone[name] = value
+	}
+	return clone
+}
+
+// size возвращает оценку размера тегов в байтах — сумму длин имён и значений.
+func (t Tags) size() int64 {
+	var size int64
+	for name, value := range t {
+		size += int64(len(name) + len(value))
+	}
+	return size
+}
+
+// GetByTag возвращает актуальные элементы, у которых тег name имеет значение value,
+// в порядке от недавно использованных к давно использованным. Как и Items, метод
+// просматривает весь кеш под блокировкой на чтение и не меняет порядок элементов.
+// Истёкшие и отрицательные записи пропускаются. При отмене ctx поиск прерывается
+// и возвращается ошибка контекста.
+func (c *LRUCache) GetByTag(ctx context.Context, name, value string) ([]Item, error) {
+	if err := c.checkOpen(ctx); err != nil {
+		return nil, err
+	}
+
+	c.flushPromotions()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var items []Item
+	now := c.now()
+	for node := c.front(); node != nil; node = c.nextNode(node) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if node.expired(now) || node.negative {
+			continue
+		}
+		if tag, ok := node.tags[name]; ok && tag == value {
+			items = append(items, nodeItem(node))
+		}
+	}
+	return items, nil
+}
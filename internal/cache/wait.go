@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WaitGet возвращает значение по ключу, дожидаясь его записи, если ключ отсутствует.
+// Ожидание завершается при записи ключа через Put, отмене контекста или закрытии кеша;
+// в последних двух случаях возвращается ошибка контекста или ErrClosed.
+// Истёкшие и отрицательные записи считаются отсутствующими. Позволяет организовать
+// простую передачу значения от производителя к потребителю через кеш.
+func (c *LRUCache) WaitGet(ctx context.Context, key string) (interface{}, time.Time, error) {
+	key = c.normalizeKey(key)
+	for {
+		// Регистрируемся до чтения, чтобы не пропустить запись между Get и ожиданием
+		ready := c.addWaiter(key)
+		value, expiresAt, err := c.Get(ctx, key)
+		if !errors.Is(err, errKeyNotFound) && !errors.Is(err, errExpiredKey) && !errors.Is(err, ErrNegativeCached) {
+			c.removeWaiter(key, ready)
+			return value, expiresAt, err
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			c.removeWaiter(key, ready)
+			return nil, time.Time{}, ctx.Err()
+		case <-c.done:
+			c.removeWaiter(key, ready)
+			return nil, time.Time{}, ErrClosed
+		}
+	}
+}
+
+// addWaiter регистрирует ожидание ключа и возвращает канал, закрываемый при его записи.
+func (c *LRUCache) addWaiter(key string) chan struct{} {
+	ready := make(chan struct{})
+	c.waitersMutex.Lock()
+	c.waiters[key] = append(c.waiters[key], ready)
+	c.waitersMutex.Unlock()
+	return ready
+}
+
+// removeWaiter отменяет ожидание ключа, если оно ещё не было завершено записью.
+func (c *LRUCache) removeWaiter(key string, ready chan struct{}) {
+	c.waitersMutex.Lock()
+	defer c.waitersMutex.Unlock()
+
+	waiters := c.waiters[key]
+	for i, w := range waiters {
+		if w == ready {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(c.waiters, key)
+		return
+	}
+	c.waiters[key] = waiters
+}
+
+// notifyWaiters пробуждает все вызовы WaitGet, ожидающие записи ключа.
+func (c *LRUCache) notifyWaiters(key string) {
+	c.waitersMutex.Lock()
+	waiters := c.waiters[key]
+	delete(c.waiters, key)
+	c.waitersMutex.Unlock()
+
+	for _, ready := range waiters {
+		close(ready)
+	}
+}
@@ -0,0 +1,10 @@
+// Package preload реализует предварительную загрузку кэша при запуске сервиса.
+//
+// Основной функционал:
+// - Ожидание готовности источника данных с экспоненциальной задержкой между попытками.
+// - Ограничение ожидания таймаутом с ошибкой, если источник так и не стал доступен.
+// - Атомарная замена содержимого кэша загруженными элементами.
+// - Источник на основе экспорта другого экземпляра сервиса (GET /api/lru/export).
+//
+// Загрузка выполняется до открытия порта, чтобы сервис не отвечал из пустого кэша.
+package preload
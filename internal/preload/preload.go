@@ -0,0 +1,66 @@
+package preload
+
+import (
+	"cache_service/internal/cache"
+	"cache_service/pkg/client"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Задержки между попытками загрузки: начальная удваивается после каждой неудачи до максимальной.
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// Source загружает элементы для предварительного заполнения кэша в порядке от недавно
+// использованных к давно использованным. Ошибка означает, что источник ещё не готов.
+type Source func(ctx context.Context) ([]cache.Item, error)
+
+// PeerSource возвращает источник, загружающий элементы из экспорта другого экземпляра
+// сервиса, доступного по адресу baseURL (например, http://cache-0:8080).
+func PeerSource(baseURL string) Source {
+	peer := client.New(baseURL)
+	return func(ctx context.Context) ([]cache.Item, error) {
+		entries, err := peer.Export(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]cache.Item, len(entries))
+		for i, entry := range entries {
+			items[i] = cache.Item{Key: entry.Key, Value: entry.Value, ExpiresAt: entry.ExpiresAt}
+		}
+		return items, nil
+	}
+}
+
+// Run загружает элементы из source и атомарно заменяет ими содержимое кэша c.
+// Пока источник возвращает ошибку, попытки повторяются с экспоненциальной задержкой
+// от initialBackoff до maxBackoff. Если источник не стал доступен за timeout или
+// контекст отменён, возвращается ошибка с причиной последней неудачной попытки.
+func Run(ctx context.Context, c *cache.LRUCache, source Source, timeout time.Duration, log *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		items, err := source(ctx)
+		if err == nil {
+			if err := c.ReplaceAll(ctx, items); err != nil {
+				return fmt.Errorf("preload: %w", err)
+			}
+			log.Info("Cache preloaded", "count", len(items), "attempts", attempt)
+			return nil
+		}
+
+		log.Warn("Preload source not ready", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("preload source not ready after %d attempts: %w", attempt, err)
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
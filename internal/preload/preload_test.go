@@ -0,0 +1,80 @@
+package preload
+
+import (
+	"cache_service/internal/cache"
+	"cache_service/internal/logger"
+	"cache_service/internal/server"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRun_SourceBecomesReady(t *testing.T) {
+	c := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+
+	// Источник становится доступен после двух неудачных попыток
+	readyAt := time.Now().Add(250 * time.Millisecond)
+	attempts := 0
+	source := func(ctx context.Context) ([]cache.Item, error) {
+		attempts++
+		if time.Now().Before(readyAt) {
+			return nil, errors.New("connection refused")
+		}
+		return []cache.Item{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}, nil
+	}
+
+	if err := Run(context.Background(), c, source, 5*time.Second, log); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts < 3 {
+		t.Errorf("expected retries before the source became ready, got %d attempts", attempts)
+	}
+	keys, _, _ := c.GetAll(context.Background())
+	if len(keys) != 2 || keys[0] != "a" {
+		t.Errorf("expected preloaded keys [a b], got %v", keys)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	c := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	notReady := errors.New("connection refused")
+	source := func(ctx context.Context) ([]cache.Item, error) {
+		return nil, notReady
+	}
+
+	start := time.Now()
+	err := Run(context.Background(), c, source, 200*time.Millisecond, log)
+	if !errors.Is(err, notReady) {
+		t.Fatalf("expected error wrapping the last failure, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected to give up after the timeout, took %s", elapsed)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected empty cache, got %d entries", c.Len())
+	}
+}
+
+func TestPeerSource(t *testing.T) {
+	peerCache := cache.NewLRUCache(10, time.Minute)
+	_ = peerCache.Put(context.Background(), "a", "1", time.Hour)
+	_ = peerCache.Put(context.Background(), "b", []byte{1, 2}, 0)
+	peer := httptest.NewServer(server.NewServer(peerCache, logger.NewLogger("ERROR")))
+	defer peer.Close()
+
+	c := cache.NewLRUCache(10, time.Minute)
+	if err := Run(context.Background(), c, PeerSource(peer.URL), time.Second, logger.NewLogger("ERROR")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, _ := c.Items(context.Background())
+	if len(items) != 2 || items[0].Key != "b" || items[1].Key != "a" {
+		t.Fatalf("expected peer entries in LRU order, got %+v", items)
+	}
+	if remaining := time.Until(items[1].ExpiresAt); remaining < 59*time.Minute {
+		t.Errorf("expected peer TTL to be preserved, got %s", remaining)
+	}
+}
@@ -0,0 +1,9 @@
+// Package selfcheck реализует режим самопроверки сервиса.
+//
+// Основной функционал:
+// - Проверка корректности конфигурации.
+// - Создание кэша и выполнение операций добавления, получения и удаления элемента.
+//
+// Режим используется в CI и проверках готовности контейнера перед выкладкой
+// и не затрагивает обработку HTTP-запросов.
+package selfcheck
@@ -0,0 +1,60 @@
+package selfcheck
+
+import (
+	"cache_service/config"
+	"cache_service/internal/cache"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ключ и значение, используемые при проверке операций кэша
+const (
+	checkKey   = "__selfcheck__"
+	checkValue = "ok"
+)
+
+// Run выполняет самопроверку сервиса с заданной конфигурацией.
+//
+// Параметры:
+// - ctx: контекст выполнения проверки.
+// - cfg: проверяемая конфигурация.
+//
+// Возвращает:
+// - nil при успешной проверке.
+// - Ошибку с описанием шага, на котором проверка завершилась неудачно.
+func Run(ctx context.Context, cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	// Проверяем кэш с теми же опциями, с которыми он будет обслуживать запросы
+	opts, err := cache.ConfigOptions(cfg, nil)
+	if err != nil {
+		return fmt.Errorf("cache options: %w", err)
+	}
+	c := cache.NewLRUCache(cfg.CacheSize, cfg.DefaultCacheTTL, opts...)
+	defer c.Close()
+
+	if err := c.Put(ctx, checkKey, checkValue, time.Minute); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	value, _, err := c.Get(ctx, checkKey)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if value != checkValue {
+		return fmt.Errorf("get: expected %q, got %v", checkValue, value)
+	}
+
+	if _, err := c.Evict(ctx, checkKey); err != nil {
+		return fmt.Errorf("evict: %w", err)
+	}
+	if _, _, err := c.Get(ctx, checkKey); err == nil {
+		return errors.New("evict: key is still present after eviction")
+	}
+
+	return nil
+}
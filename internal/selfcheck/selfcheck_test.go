@@ -0,0 +1,33 @@
+package selfcheck
+
+import (
+	"cache_service/config"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	cfg := &config.Config{
+		ServerHostPort:  "localhost:8080",
+		CacheSize:       10,
+		DefaultCacheTTL: time.Minute,
+		LogLevel:        "WARN",
+	}
+
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Errorf("expected self-check to pass, got %v", err)
+	}
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	cfg := &config.Config{
+		ServerHostPort: "localhost:8080",
+		CacheSize:      -1,
+		LogLevel:       "WARN",
+	}
+
+	if err := Run(context.Background(), cfg); err == nil {
+		t.Error("expected self-check to fail for invalid config")
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// combinedTimeFormat — формат времени запроса в Combined Log Format.
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogger пишет журнал доступа в формате Apache Combined Log Format.
+type accessLogger struct {
+	mu sync.Mutex // Упорядочивает запись строк из параллельных запросов
+	w  io.Writer  // Получатель строк журнала
+}
+
+// WithCombinedAccessLog включает журнал доступа в формате Apache Combined Log Format
+// для совместимости с существующими средствами сбора логов. Строки пишутся в w вместо
+// структурированной записи о завершении запроса; предупреждения о медленных запросах
+// по-прежнему пишутся структурированным логгером. nil отключает журнал.
+func WithCombinedAccessLog(w io.Writer) Option {
+	return func(s *Server) {
+		if w == nil {
+			s.accessLog = nil
+			return
+		}
+		s.accessLog = &accessLogger{w: w}
+	}
+}
+
+// log записывает строку журнала для обработанного запроса:
+// IP-адрес клиента, время, строка запроса, код ответа, размер тела, Referer и User-Agent.
+func (l *accessLogger) log(r *http.Request, ww middleware.WrapResponseWriter, start time.Time) {
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	size := "-"
+	if n := ww.BytesWritten(); n > 0 {
+		size = strconv.Itoa(n)
+	}
+	line := fmt.Sprintf("%s - - [%s] %s %d %s %s %s\n",
+		clientIP(r.Context()),
+		start.Format(combinedTimeFormat),
+		strconv.Quote(r.Method+" "+r.RequestURI+" "+r.Proto),
+		status,
+		size,
+		strconv.Quote(headerOrDash(r.Referer())),
+		strconv.Quote(headerOrDash(r.UserAgent())),
+	)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, line)
+}
+
+// headerOrDash возвращает значение заголовка или «-», если он не задан.
+func headerOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
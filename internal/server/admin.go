@@ -0,0 +1,49 @@
+package server
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+)
+
+// WithAdminRouter выносит служебные маршруты (/metrics, /healthz, /debug/pprof и /debug/lru/find)
+// на отдельный маршрутизатор admin, который обслуживается на отдельном порту.
+// Основной маршрутизатор в этом случае служебных маршрутов не содержит, и метрики
+// с профилированием недоступны через публичный API. Без этого параметра /metrics
+// и /healthz обслуживаются основным маршрутизатором, а маршруты /debug не подключаются.
+func WithAdminRouter(admin chi.Router) Option {
+	return func(s *Server) {
+		s.admin = admin
+	}
+}
+
+// mountAdmin регистрирует служебные маршруты в r. Профилирование и отладочный поиск
+// по значению подключаются, только если задан profiler, то есть маршруты обслуживаются
+// на отдельном порту.
+func (s *Server) mountAdmin(r chi.Router, profiler bool) {
+	r.Get("/healthz", s.HealthHandler)
+	r.Method(http.MethodGet, "/metrics", s.metrics.handler())
+	if profiler {
+		r.Mount("/debug", middleware.Profiler())
+		r.Get("/debug/lru/find", s.FindByValueHandler)
+	}
+}
+
+// HealthHandler обрабатывает GET-запрос на проверку работоспособности сервиса.
+//
+// Метод:
+// - GET /healthz
+//
+// Ответы:
+// - 200 OK: Сервис принимает запросы.
+// - 503 Service Unavailable: Сервис останавливается (см. WithShutdownSignal).
+func (s *Server) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if s.draining() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("shutting down\n"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
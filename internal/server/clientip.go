@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPContextKey — ключ контекста запроса для IP-адреса клиента.
+type clientIPContextKey struct{}
+
+// WithTrustedProxies задаёт адреса и подсети доверенных прокси. Если запрос пришёл
+// от доверенного прокси, адрес клиента определяется по заголовкам X-Forwarded-For
+// и X-Real-IP; иначе используется адрес соединения. Без доверенных прокси заголовки
+// игнорируются, так как клиент может подделать их.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = prefixes
+	}
+}
+
+// clientIPMiddleware определяет IP-адрес клиента и сохраняет его в контексте запроса.
+func (s *Server) clientIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPContextKey{}, s.resolveClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIP возвращает IP-адрес клиента, определённый clientIPMiddleware.
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPContextKey{}).(string)
+	return ip
+}
+
+// resolveClientIP определяет IP-адрес клиента с учётом доверенных прокси.
+//
+// X-Forwarded-For просматривается справа налево: каждый доверенный прокси добавляет
+// адрес своего клиента в конец списка, поэтому первый недоверенный адрес — адрес клиента.
+func (s *Server) resolveClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !s.trustedProxy(remote) {
+		return remote
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			if !s.trustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+	return remote
+}
+
+// trustedProxy сообщает, принадлежит ли адрес доверенному прокси.
+func (s *Server) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Встроенные предикаты поиска элементов по значению (см. FindByValueHandler).
+const (
+	predicateEquals   = "equals"   // Значение совпадает с заданным JSON
+	predicateContains = "contains" // Строковое значение содержит заданную подстроку
+	predicateType     = "type"     // Значение имеет заданный тип JSON
+)
+
+// valueTypes — допустимые типы значения для предиката type.
+var valueTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+	"binary":  true,
+}
+
+// FindByValueHandler обрабатывает GET-запрос на поиск ключей элементов, значения которых
+// удовлетворяют встроенному предикату. Маршрут предназначен для отладки и обслуживается
+// только служебным маршрутизатором (см. WithAdminRouter), так как просматривает весь кэш
+// и раскрывает ключи всех арендаторов.
+//
+// Метод:
+// - GET /debug/lru/find
+//
+// Параметры запроса:
+// - predicate (string): Предикат: equals, contains или type.
+// - value (string): Аргумент предиката: JSON-значение для equals (например, "abc" в
+// кавычках или 42), подстрока для contains, тип (string, number, boolean, object,
+// array, binary) для type. Двоичные значения сравниваются в виде строки base64.
+//
+// Тело ответа (JSON):
+// - keys (array): Ключи найденных элементов в порядке от недавно использованных.
+// - count (int): Число найденных элементов.
+//
+// Ответы:
+// - 200 OK: Успешный ответ со списком ключей.
+// - 400 Bad Request: Неизвестный предикат или некорректный аргумент.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+func (s *Server) FindByValueHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	var errs validationErrors
+	match := valuePredicate(r.URL.Query().Get("predicate"), r.URL.Query().Get("value"), &errs)
+	if len(errs) > 0 {
+		s.writeValidationErrors(w, r, errs)
+		return
+	}
+
+	keys, err := s.cache.FindByValue(ctx, match)
+	if err != nil && s.requestCancelled(w, r) {
+		return
+	}
+	if err != nil {
+		s.requestLog(r).Error("Failed to find entries by value", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	response := struct {
+		Keys  []string `json:"keys"`
+		Count int      `json:"count"`
+	}{
+		Keys:  append([]string{}, keys...),
+		Count: len(keys),
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// valuePredicate возвращает функцию проверки значения для встроенного предиката name
+// с аргументом arg. Ошибки в имени предиката или аргументе добавляются в errs.
+func valuePredicate(name, arg string, errs *validationErrors) func(interface{}) bool {
+	switch name {
+	case predicateEquals:
+		want, err := canonicalJSON(json.RawMessage(arg))
+		if err != nil {
+			errs.add("value", fmt.Sprintf("value must be valid JSON for %s", name))
+			return nil
+		}
+		return func(v interface{}) bool {
+			got, err := canonicalJSON(v)
+			return err == nil && bytes.Equal(got, want)
+		}
+	case predicateContains:
+		return func(v interface{}) bool {
+			text, ok := valueText(v)
+			return ok && strings.Contains(text, arg)
+		}
+	case predicateType:
+		if !valueTypes[arg] {
+			errs.add("value", fmt.Sprintf("unknown value type %q", arg))
+			return nil
+		}
+		return func(v interface{}) bool {
+			return valueType(v) == arg
+		}
+	case "":
+		errs.add("predicate", "predicate is required")
+	default:
+		errs.add("predicate", fmt.Sprintf("unknown predicate %q", name))
+	}
+	return nil
+}
+
+// canonicalJSON кодирует v в JSON без пробелов и с упорядоченными ключами объектов,
+// чтобы равные значения давали одинаковые байты независимо от способа хранения.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+// valueText возвращает текст строкового значения элемента, в том числе хранимого
+// в виде исходного JSON.
+func valueText(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case json.RawMessage:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return "", false
+		}
+		return text, true
+	default:
+		return "", false
+	}
+}
+
+// valueType возвращает тип значения элемента в терминах JSON или binary для
+// двоичных значений. Значение null в кэше не хранится (см. cache.ErrNilValue).
+func valueType(v interface{}) string {
+	if _, ok := v.([]byte); ok {
+		return "binary"
+	}
+	data, err := json.Marshal(v)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	switch data[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// drainRetryAfter — значение заголовка Retry-After (в секундах) в ответах во время остановки.
+const drainRetryAfter = 5
+
+// WithShutdownSignal задаёт канал, закрытие которого означает начало остановки сервиса.
+// После закрытия канала новые запросы к /api/lru отклоняются с кодом 503 и заголовком
+// Retry-After, чтобы балансировщик перенаправил их на другие экземпляры, пока активные
+// запросы завершаются. Служебные маршруты (/metrics, /openapi.json) остаются доступны,
+// а /healthz отвечает кодом 503.
+func WithShutdownSignal(done <-chan struct{}) Option {
+	return func(s *Server) {
+		s.shutdown = done
+	}
+}
+
+// draining сообщает, находится ли сервер в состоянии остановки: получен сигнал
+// остановки или кэш уже закрыт.
+func (s *Server) draining() bool {
+	select {
+	case <-s.shutdown:
+		return true
+	default:
+		return s.cache.Closed()
+	}
+}
+
+// drainMiddleware отклоняет новые запросы с кодом 503, пока сервер останавливается.
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining() {
+			s.requestLog(r).Warn("Rejecting request during shutdown")
+			w.Header().Set("Retry-After", strconv.Itoa(drainRetryAfter))
+			s.writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "service is shutting down")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
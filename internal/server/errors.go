@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Коды ошибок в JSON-ответах, позволяющие клиенту различать источники ошибки
+// с одинаковым кодом ответа.
+const (
+	errorCodeKeyNotFound          = "key_not_found"          // Элемент не найден или истёк
+	errorCodeRouteNotFound        = "route_not_found"        // Маршрут не существует
+	errorCodeInvalidRequest       = "invalid_request"        // Некорректный запрос
+	errorCodeDecode               = "client_decode_error"    // Тело запроса не является корректным JSON ожидаемой структуры
+	errorCodeRejected             = "cache_rejected"         // Кэш отклонил запись (размер, стоимость и т. п.)
+	errorCodePreconditionFailed   = "precondition_failed"    // Условие запроса не выполнено
+	errorCodeKeyExists            = "key_exists"             // Ключ уже существует при записи только отсутствующего ключа
+	errorCodeUnsupportedMediaType = "unsupported_media_type" // Неподдерживаемый Content-Type запроса
+	errorCodeChangesExpired       = "changes_expired"        // Изменения после запрошенной версии больше недоступны
+	errorCodeUnavailable          = "service_unavailable"    // Сервис перегружен или останавливается
+	errorCodeRequestCancelled     = "request_cancelled"      // Запрос отменён клиентом
+	errorCodeInternal             = "internal_error"         // Внутренняя ошибка сервера
+)
+
+// contentTypeJSON — значение заголовка Content-Type JSON-ответов.
+const contentTypeJSON = "application/json; charset=utf-8"
+
+// writeJSON отвечает на запрос r кодом status и телом v в формате JSON.
+// Все JSON-ответы сервера, включая ошибки, формируются через эту функцию.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.requestLog(r).Error("Failed to encode response", "error", err)
+	}
+}
+
+// writeError отвечает кодом status и телом JSON с кодом и описанием ошибки.
+//
+// Тело ответа (JSON):
+// - code (string): Машиночитаемый код ошибки.
+// - error (string): Описание ошибки.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	response := struct {
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}{
+		Code:  code,
+		Error: message,
+	}
+	s.writeJSON(w, r, status, response)
+}
+
+// Значения метки kind метрики request_errors_total.
+const (
+	requestErrorDecode   = "client_decode"  // Некорректное тело запроса
+	requestErrorRejected = "cache_rejected" // Запись отклонена кэшем
+)
+
+// writeDecodeError отвечает кодом 400 с кодом client_decode_error на тело запроса,
+// которое не удалось разобрать, и учитывает ошибку в метрике request_errors_total,
+// чтобы некорректные запросы клиентов можно было отличить от отказов кэша.
+func (s *Server) writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	s.requestLog(r).Error("Invalid request body", "error", err)
+	s.metrics.requestErrors.WithLabelValues(requestErrorDecode).Inc()
+	s.writeError(w, r, http.StatusBadRequest, errorCodeDecode, "invalid request body")
+}
+
+// writeRejected отвечает кодом 422 с кодом cache_rejected и причиной отказа кэша
+// в записи корректного запроса и учитывает отказ в метрике request_errors_total.
+func (s *Server) writeRejected(w http.ResponseWriter, r *http.Request, err error) {
+	s.requestLog(r).Warn("Cache rejected write", "error", err)
+	s.metrics.requestErrors.WithLabelValues(requestErrorRejected).Inc()
+	s.writeError(w, r, http.StatusUnprocessableEntity, errorCodeRejected, err.Error())
+}
+
+// NotFoundHandler отвечает на запросы к несуществующим маршрутам кодом 404
+// с JSON-телом, отличающимся от ответа об отсутствии ключа кодом route_not_found.
+func (s *Server) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	s.requestLog(r).Warn("Route not found")
+	s.writeError(w, r, http.StatusNotFound, errorCodeRouteNotFound, "route not found")
+}
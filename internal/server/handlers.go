@@ -1,56 +1,280 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"cache_service/internal/cache"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"hash/fnv"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// CreateLRUHandler обрабатывает POST-запрос на добавление элемента в кэш.
+// CreateLRUHandler обрабатывает POST-запрос на добавление или обновление элемента в кэше.
 //
 // Метод:
 // - POST /api/lru
 //
 // Тело запроса (JSON):
-// - key (string): Ключ элемента.
-// - value (interface{}): Значение элемента.
-// - ttl_seconds (int, optional): Время жизни элемента в секундах.
+// - key (string): Ключ элемента. Имена служебных маршрутов /api/lru (stats, export и
+// другие, см. reservedKeys) зарезервированы и отклоняются с кодом 400.
+// - key_template (string, optional): Шаблон ключа вместо key, например "{tenant}:{id}".
+// Заполнители {field} заменяются полями объекта value (строки, числа, логические значения);
+// запрос без одного из полей отклоняется. См. expandKeyTemplate.
+// - value (interface{}): Значение элемента. Значение null не допускается. С WithRawJSONValues
+// значение хранится и возвращается в исходной записи JSON.
+// - value_b64 (string, optional): Двоичное значение в кодировке base64 вместо value.
+// Хранится как последовательность байт и возвращается в том же виде.
+// - ttl_seconds (int, optional): Время жизни элемента в секундах, не более maxTTLSeconds.
+// - sliding (bool, optional): Скользящий TTL — каждое успешное чтение продлевает жизнь
+// элемента на его исходный TTL, и элемент истекает только после периода бездействия.
+// - cost (int, optional): Стоимость элемента в ёмкости кэша, не меньше 1 (по умолчанию 1).
+// Ёмкость ограничивает суммарную стоимость элементов, и дорогой элемент вытесняет
+// несколько давно использованных. Элемент дороже ёмкости кэша не записывается.
+// - tags (object, optional): Метаданные элемента — строковые значения по именам тегов
+// (например, подсказки Cache-Control или источник). Не влияют на значение, возвращаются
+// при чтении и заменяются при каждой записи. Имя тега не может быть пустым или содержать «:».
+//
+// Приоритет TTL: ttl_seconds из тела, затем заголовок X-Cache-TTL, затем TTL по умолчанию.
+// Явно заданный нулевой TTL означает немедленное истечение элемента.
+//
+// Заголовки запроса:
+// - X-Cache-TTL (optional): Время жизни элемента в формате длительности (например, "30s"),
+// используется, если в теле не указан ttl_seconds.
+// - If-Match (optional): ETag текущей версии элемента. Элемент обновляется, только если
+// он существует и его ETag совпадает.
+//
+// Параметры запроса:
+// - echo (bool, optional): При значении true ответ содержит JSON с вычисленным сроком
+// жизни элемента, избавляя клиента от отдельного GET: key, expires_at,
+// expires_at_rfc3339 и remaining_seconds в формате GetLRUHandler, а также ttl_clamped,
+// если TTL был уменьшен до максимального.
+// - if_absent (bool, optional): Записать элемент, только если ключ отсутствует (как SET NX GET
+// в Redis). Если ключ существует, элемент не изменяется, а ответ 409 содержит его текущее
+// значение, избавляя клиента от отдельного GET.
+//
+// Заголовки ответа:
+// - ETag: Версия записанного элемента.
+// - X-Cache-TTL-Clamped (optional): Значение true, если запрошенный TTL или TTL по умолчанию
+// превышал максимальный (cache.WithMaxTTL) и был уменьшен до него.
 //
 // Ответы:
-// - 201 Created: Элемент успешно добавлен.
-// - 400 Bad Request: Некорректный запрос.
+// - 200 OK: Существующий элемент успешно обновлён.
+// - 201 Created: Элемент успешно добавлен. Заголовок Location содержит адрес элемента.
+// - 400 Bad Request: Некорректный запрос. Если тело разобрано, ответ содержит список
+// всех ошибок проверки полей: {"errors": [{"field": ..., "message": ...}]}, иначе — код client_decode_error.
+// - 412 Precondition Failed: ETag из If-Match не совпадает или элемент отсутствует.
+// - 409 Conflict: Ключ существует при if_absent=true. Тело JSON с кодом key_exists и полем
+// current — текущим элементом в формате ExportLRUHandler; заголовок ETag содержит его версию.
+// - 415 Unsupported Media Type: Content-Type запроса отсутствует или не является application/json.
+// - 422 Unprocessable Entity: Кэш отклонил запись (например, значение больше бюджета памяти);
+// код cache_rejected с причиной отказа.
+// - 499 Client Closed Request: Запрос отменён клиентом.
 // - 500 Internal Server Error: Ошибка сервера.
 func (s *Server) CreateLRUHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	s.log.Info("Processing request", "method", r.Method, "path", r.URL.Path)
-	select {
-	case <-ctx.Done():
-		s.log.Warn("Request cancelled", "method", r.Method, "path", r.URL.Path)
-		http.Error(w, "request cancelled", http.StatusInternalServerError)
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) || !s.requireJSON(w, r) {
 		return
-	default:
 	}
 
 	var createRequest struct {
-		Key        string      `json:"key"`
-		Value      interface{} `json:"value"`
-		TTLSeconds int64       `json:"ttl_seconds,omitempty"`
+		Key         string          `json:"key"`
+		KeyTemplate *string         `json:"key_template,omitempty"`
+		Value       json.RawMessage `json:"value"`
+		ValueB64    *string         `json:"value_b64,omitempty"`
+		TTLSeconds  *int64          `json:"ttl_seconds,omitempty"`
+		Sliding     bool            `json:"sliding,omitempty"`
+		Cost        *int64          `json:"cost,omitempty"`
+		Tags        cache.Tags      `json:"tags,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&createRequest); err != nil {
-		s.log.Error("Invalid request body", "error", err)
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		s.writeDecodeError(w, r, err)
+		return
+	}
+
+	// Проверяем все поля, чтобы сообщить клиенту обо всех ошибках разом
+	var errs validationErrors
+	switch {
+	case createRequest.KeyTemplate != nil && createRequest.Key != "":
+		errs.add("key_template", "key and key_template are mutually exclusive")
+	case createRequest.KeyTemplate != nil:
+		var fields map[string]interface{}
+		if err := s.decodeJSON(createRequest.Value, &fields); err != nil || fields == nil {
+			errs.add("key_template", "key_template requires value to be a JSON object")
+		} else if key, err := expandKeyTemplate(*createRequest.KeyTemplate, fields); err != nil {
+			errs.add("key_template", err.Error())
+		} else if key == "" {
+			errs.add("key_template", "key_template produced an empty key")
+		} else if err := validateKey(key); err != nil {
+			errs.add("key_template", err.Error())
+		} else {
+			createRequest.Key = key
+		}
+	case createRequest.Key == "":
+		errs.add("key", "key is required")
+	default:
+		if err := validateKey(createRequest.Key); err != nil {
+			errs.add("key", err.Error())
+		}
+	}
+
+	var decoded interface{}
+	if len(createRequest.Value) > 0 {
+		// Тело уже разобрано декодером, поэтому значение является корректным JSON
+		_ = s.decodeJSON(createRequest.Value, &decoded)
+		if s.rawJSON && decoded != nil {
+			decoded = compactJSON(createRequest.Value)
+		}
+	} else if createRequest.ValueB64 == nil {
+		errs.add("value", "value or value_b64 is required")
+	}
+	value, err := requestValue(decoded, createRequest.ValueB64)
+	if err != nil {
+		errs.add("value_b64", err.Error())
+	}
+
+	ttl, explicitTTL, err := requestTTL(createRequest.TTLSeconds, r.Header.Get("X-Cache-TTL"))
+	if err != nil {
+		field := "X-Cache-TTL"
+		if createRequest.TTLSeconds != nil {
+			field = "ttl_seconds"
+		}
+		errs.add(field, err.Error())
+	}
+
+	var cost int64
+	if createRequest.Cost != nil {
+		cost = *createRequest.Cost
+		if cost < 1 {
+			errs.add("cost", "cost must be a positive integer")
+		} else if capacity := s.cache.Capacity(); capacity > 0 && cost > int64(capacity) {
+			errs.add("cost", fmt.Sprintf("cost cannot exceed cache capacity %d", capacity))
+		}
+	}
+
+	if err := validateTags(createRequest.Tags); err != nil {
+		errs.add("tags", err.Error())
+	}
+
+	var ifAbsent bool
+	if param := r.URL.Query().Get("if_absent"); param != "" {
+		if ifAbsent, err = strconv.ParseBool(param); err != nil {
+			errs.add("if_absent", "must be a boolean")
+		}
+	}
+
+	if len(errs) > 0 {
+		s.writeValidationErrors(w, r, errs)
+		return
+	}
+
+	var ifVersion uint64
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		version, ok := parseETag(ifMatch)
+		if !ok {
+			s.requestLog(r).Warn("Invalid If-Match header", "if_match", ifMatch)
+			s.writeError(w, r, http.StatusPreconditionFailed, errorCodePreconditionFailed, cache.ErrPreconditionFailed.Error())
+			return
+		}
+		ifVersion = version
+	}
+
+	spanCtx, span := s.startCacheSpan(ctx, "put", createRequest.Key)
+	item, created, err := s.cache.PutWithOptions(spanCtx, tenantKey(ctx, createRequest.Key), value, cache.PutOptions{
+		TTL:         ttl,
+		ExplicitTTL: explicitTTL,
+		Sliding:     createRequest.Sliding,
+		Cost:        cost,
+		IfVersion:   ifVersion,
+		IfAbsent:    ifAbsent,
+		Tags:        createRequest.Tags,
+	})
+	endCacheSpan(span, err == nil && !created, err, item.ExpiresAt)
+	if errors.Is(err, cache.ErrKeyExists) {
+		s.requestLog(r).Info("Key already exists", "key", createRequest.Key)
+		current := itemEntry(item)
+		current.Key = createRequest.Key
+		w.Header().Set("ETag", formatETag(item.Version))
+		s.writeJSON(w, r, http.StatusConflict, struct {
+			Code    string      `json:"code"`
+			Error   string      `json:"error"`
+			Current exportEntry `json:"current"`
+		}{
+			Code:    errorCodeKeyExists,
+			Error:   err.Error(),
+			Current: current,
+		})
+		return
+	}
+	if errors.Is(err, cache.ErrNilValue) {
+		s.writeValidationErrors(w, r, validationErrors{{Field: "value", Message: err.Error()}})
+		return
+	}
+	if errors.Is(err, cache.ErrPreconditionFailed) {
+		s.requestLog(r).Warn("Precondition failed for key", "key", createRequest.Key, "if_match", r.Header.Get("If-Match"))
+		s.writeError(w, r, http.StatusPreconditionFailed, errorCodePreconditionFailed, err.Error())
+		return
+	}
+	if err != nil && s.requestCancelled(w, r) {
+		return
+	}
+	if errors.Is(err, cache.ErrClosed) {
+		s.requestLog(r).Error("Failed to put key in cache", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+	if err != nil {
+		s.writeRejected(w, r, err)
 		return
 	}
 
-	if err := s.cache.Put(ctx, createRequest.Key, createRequest.Value, time.Duration(createRequest.TTLSeconds)*time.Second); err != nil {
-		s.log.Error("Failed to put key in cache", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	w.Header().Set("ETag", formatETag(item.Version))
+	if item.TTLClamped {
+		s.requestLog(r).Info("TTL clamped to maximum", "key", createRequest.Key)
+		w.Header().Set("X-Cache-TTL-Clamped", "true")
+	}
+	status := http.StatusOK
+	if created {
+		s.requestLog(r).Info("Key added to cache", "key", createRequest.Key)
+		w.Header().Set("Location", "/api/lru/"+url.PathEscape(createRequest.Key))
+		status = http.StatusCreated
+	} else {
+		s.requestLog(r).Info("Key updated in cache", "key", createRequest.Key)
 	}
 
-	s.log.Info("Key added to cache", "key", createRequest.Key)
-	w.WriteHeader(http.StatusCreated)
+	if echo, _ := strconv.ParseBool(r.URL.Query().Get("echo")); !echo {
+		w.WriteHeader(status)
+		return
+	}
+	expiresAtUnix, expiresAtRFC3339 := formatTimestamp(item.ExpiresAt)
+	response := struct {
+		Key              string `json:"key"`
+		ExpiresAt        int64  `json:"expires_at"`
+		ExpiresAtRFC3339 string `json:"expires_at_rfc3339"`
+		RemainingSeconds int64  `json:"remaining_seconds"`
+		TTLClamped       bool   `json:"ttl_clamped,omitempty"`
+	}{
+		Key:              createRequest.Key,
+		ExpiresAt:        expiresAtUnix,
+		ExpiresAtRFC3339: expiresAtRFC3339,
+		RemainingSeconds: remainingSeconds(item.ExpiresAt),
+		TTLClamped:       item.TTLClamped,
+	}
+	s.writeJSON(w, r, status, response)
 }
 
 // GetLRUHandler обрабатывает GET-запрос на получение элемента по ключу.
@@ -59,44 +283,130 @@ func (s *Server) CreateLRUHandler(w http.ResponseWriter, r *http.Request) {
 // - GET /api/lru/{key}
 //
 // Параметры пути:
+// - key (string): Ключ элемента. Может содержать символы «/»; зарезервированные символы
+// должны быть закодированы (percent-encoding). Имена служебных маршрутов (stats, export
+// и другие, см. reservedKeys) ключами быть не могут.
+//
+// Параметры запроса:
+// - wait (duration, optional): Длительное ожидание (long polling). Если ключ отсутствует,
+// ответ задерживается до записи ключа, но не дольше wait (например, "5s") и не дольше
+// максимального времени ожидания сервера (WithMaxWait).
+// - raw (bool, optional): Вернуть значение без обёртки (см. writeRawValue): тело ответа —
+// само значение, а ключ и срок жизни передаются в заголовках X-Cache-*.
+//
+// Тело ответа (JSON):
 // - key (string): Ключ элемента.
+// - value (interface{}): Значение элемента; null для двоичных значений.
+// - value_b64 (string, optional): Двоичное значение в кодировке base64.
+// - expires_at (int): Время истечения срока жизни в формате Unix; 0 для бессрочного элемента.
+// - expires_at_rfc3339 (string): Время истечения срока жизни в формате RFC3339 (UTC).
+// - remaining_seconds (int): Оставшееся время жизни элемента в секундах; -1 для бессрочного элемента.
+// - tags (object, optional): Метаданные элемента, если они были заданы при записи.
+//
+// Заголовки ответа:
+// - ETag: Версия элемента для условного обновления или удаления через If-Match.
+// - Last-Modified: Время последней записи элемента для условного удаления через If-Unmodified-Since.
 //
 // Ответы:
 // - 200 OK: Успешный ответ с данными элемента.
-// - 404 Not Found: Ключ не найден или истёк срок действия.
+// - 400 Bad Request: Некорректно закодированный ключ или параметр wait или raw.
+// - 404 Not Found: Ключ не найден или истёк срок действия (при wait — не записан
+// за время ожидания); тело JSON с кодом key_not_found.
+// - 499 Client Closed Request: Запрос отменён клиентом.
 // - 500 Internal Server Error: Ошибка сервера.
 func (s *Server) GetLRUHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	s.log.Info("Processing request", "method", r.Method, "path", r.URL.Path)
-	select {
-	case <-ctx.Done():
-		s.log.Warn("Request cancelled", "method", r.Method, "path", r.URL.Path)
-		http.Error(w, "request cancelled", http.StatusInternalServerError)
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
 		return
-	default:
 	}
-	key := chi.URLParam(r, "key")
-	value, expiresAt, err := s.cache.Get(ctx, key)
+	key, err := keyParam(r)
+	if err != nil {
+		s.requestLog(r).Error("Invalid key in path", "error", err)
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid key")
+		return
+	}
+	wait, err := s.waitParam(r.URL.Query().Get("wait"))
+	if err != nil {
+		s.writeValidationErrors(w, r, validationErrors{{Field: "wait", Message: err.Error()}})
+		return
+	}
+	raw := false
+	if param := r.URL.Query().Get("raw"); param != "" {
+		if raw, err = strconv.ParseBool(param); err != nil {
+			s.writeValidationErrors(w, r, validationErrors{{Field: "raw", Message: "must be a boolean"}})
+			return
+		}
+	}
+	spanCtx, span := s.startCacheSpan(ctx, "get", key)
+	item, err := s.cache.GetItem(spanCtx, tenantKey(ctx, key))
+	if err != nil && wait > 0 {
+		s.requestLog(r).Debug("Waiting for key", "key", key, "wait", wait)
+		if waited, waitErr := s.waitItem(spanCtx, tenantKey(ctx, key), wait); waitErr == nil {
+			item, err = waited, nil
+		}
+	}
+	endCacheSpan(span, err == nil, err, item.ExpiresAt)
 	if err != nil {
-		s.log.Error("Failed to get key from cache", "error", err)
-		http.Error(w, err.Error(), http.StatusNotFound)
+		s.writeCacheError(w, r, err, "Failed to get key from cache")
+		return
 	}
+	expiresAt := item.ExpiresAt
 
-	s.log.Info("Key retrieved from cache", "key", key, "expires_at", expiresAt)
+	s.requestLog(r).Info("Key retrieved from cache", "key", key, "expires_at", expiresAt)
+	w.Header().Set("ETag", formatETag(item.Version))
+	w.Header().Set("Last-Modified", item.ModifiedAt.UTC().Format(http.TimeFormat))
+	if raw {
+		s.writeRawValue(w, r, key, item)
+		return
+	}
+	expiresAtUnix, expiresAtRFC3339 := formatTimestamp(expiresAt)
+	value, valueB64 := responseValue(item.Value)
 	response := struct {
-		Key       string      `json:"key"`
-		Value     interface{} `json:"value"`
-		ExpiresAt int64       `json:"expires_at"`
+		Key              string      `json:"key"`
+		Value            interface{} `json:"value"`
+		ValueB64         *string     `json:"value_b64,omitempty"`
+		ExpiresAt        int64       `json:"expires_at"`
+		ExpiresAtRFC3339 string      `json:"expires_at_rfc3339"`
+		RemainingSeconds int64       `json:"remaining_seconds"`
+		Tags             cache.Tags  `json:"tags,omitempty"`
 	}{
-		Key:       key,
-		Value:     value,
-		ExpiresAt: expiresAt.Unix(),
+		Key:              key,
+		Value:            value,
+		ValueB64:         valueB64,
+		ExpiresAt:        expiresAtUnix,
+		ExpiresAtRFC3339: expiresAtRFC3339,
+		RemainingSeconds: remainingSeconds(expiresAt),
+		Tags:             item.Tags,
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		s.log.Error("Failed to encode response", "error", err)
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// writeRawValue отвечает значением элемента без обёртки, для клиентов, ожидающих
+// данные в теле ответа как есть. Двоичное значение передаётся байтами
+// (application/octet-stream), строка — текстом (text/plain), остальные значения — в JSON.
+//
+// Заголовки ответа:
+// - X-Cache-Key: Ключ элемента в percent-encoding, как в пути запроса.
+// - X-Cache-Expires-At: Время истечения срока жизни в формате Unix; 0 для бессрочного элемента.
+// - X-Cache-Remaining-Seconds: Оставшееся время жизни в секундах; -1 для бессрочного элемента.
+func (s *Server) writeRawValue(w http.ResponseWriter, r *http.Request, key string, item cache.Item) {
+	expiresAtUnix, _ := formatTimestamp(item.ExpiresAt)
+	w.Header().Set("X-Cache-Key", url.PathEscape(key))
+	w.Header().Set("X-Cache-Expires-At", strconv.FormatInt(expiresAtUnix, 10))
+	w.Header().Set("X-Cache-Remaining-Seconds", strconv.FormatInt(remainingSeconds(item.ExpiresAt), 10))
+
+	switch value := item.Value.(type) {
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(value)
+	case string:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, value)
+	default:
+		s.writeJSON(w, r, http.StatusOK, value)
 	}
 }
 
@@ -104,42 +414,341 @@ func (s *Server) GetLRUHandler(w http.ResponseWriter, r *http.Request) {
 //
 // Метод:
 // - GET /api/lru
+// - GET /api/lru?key=a&key=b: Выборка элементов по набору ключей (см. getManyResponse).
+//
+// Параметры запроса:
+// - order (string, optional): Порядок элементов: recency (по умолчанию) — от недавно
+// использованных к давно использованным, lru — в обратном порядке, key — по ключу
+// в лексикографическом порядке (стабильный порядок для сравнения списков).
+// - tag (string, optional): Фильтр по тегу в виде имя:значение (например, source:db) —
+// возвращаются только элементы, у которых тег имя имеет указанное значение. Фильтр
+// читает кэш напрямую, в том числе в режиме чтения из снимка.
+//
+// При разделении по арендаторам (WithTenantHeader) возвращаются только элементы арендатора.
+// В режиме чтения из снимка (cache.WithSnapshotInterval) список строится по последнему
+// снимку без блокировки кэша и может отставать от него на интервал обновления снимка.
+//
+// Заголовки запроса:
+// - If-None-Match (optional): ETag ранее полученного списка. Если список не изменился,
+// возвращается 304 без тела, что экономит трафик клиентов, периодически опрашивающих кэш.
+//
+// Заголовки ответа:
+// - ETag: Версия списка — хеш тела ответа; меняется при любом изменении ключей или значений.
+// - Age (optional): Давность снимка в секундах; только в режиме чтения из снимка.
+//
+// Тело ответа (JSON):
+// - keys (array): Ключи элементов.
+// - values (array): Значения элементов в том же порядке.
+// - truncated (bool): Ответ ограничен WithMaxResponseEntries и содержит не все элементы;
+// полный список можно получить потоково через GET /api/lru/export.
 //
 // Ответы:
 // - 200 OK: Успешный ответ с данными всех элементов.
 // - 204 No Content: Кэш пуст.
+// - 304 Not Modified: Список не изменился с ответа с ETag из If-None-Match.
+// - 400 Bad Request: Неизвестный порядок order или некорректный фильтр tag.
+// - 499 Client Closed Request: Запрос отменён клиентом.
 // - 500 Internal Server Error: Ошибка сервера.
 func (s *Server) GetAllLRUHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	s.log.Info("Processing request", "method", r.Method, "path", r.URL.Path)
-	select {
-	case <-ctx.Done():
-		s.log.Warn("Request cancelled", "method", r.Method, "path", r.URL.Path)
-		http.Error(w, "request cancelled", http.StatusInternalServerError)
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+	if keys := r.URL.Query()["key"]; len(keys) > 0 {
+		s.getManyResponse(w, r, keys)
+		return
+	}
+	order := r.URL.Query().Get("order")
+	if order != "" && order != listOrderRecency && order != listOrderLRU && order != listOrderKey {
+		s.requestLog(r).Error("Invalid order parameter", "order", order)
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "order must be one of recency, lru, key")
 		return
-	default:
 	}
 
-	keys, values, err := s.cache.GetAll(ctx)
-	if err != nil {
-		s.log.Error("Failed to get all keys from cache", "error", err)
-		http.Error(w, err.Error(), http.StatusNoContent)
+	var keys []string
+	var values []interface{}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		name, value, ok := strings.Cut(tag, ":")
+		if !ok || name == "" {
+			s.requestLog(r).Error("Invalid tag parameter", "tag", tag)
+			s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "tag must be in the form name:value")
+			return
+		}
+		items, err := s.cache.GetByTag(ctx, name, value)
+		if err != nil && s.requestCancelled(w, r) {
+			return
+		}
+		if err != nil {
+			s.requestLog(r).Error("Failed to get tagged keys from cache", "error", err)
+			s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+			return
+		}
+		keys, values = make([]string, len(items)), make([]interface{}, len(items))
+		for i, item := range items {
+			keys[i], values[i] = item.Key, item.Value
+		}
+	} else {
+		var err error
+		keys, values, err = s.allEntries(ctx, w)
+		if errors.Is(err, cache.ErrEmptyCache) || errors.Is(err, errEmptySnapshot) {
+			s.requestLog(r).Info("No keys in cache")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err != nil && s.requestCancelled(w, r) {
+			return
+		}
+		if err != nil {
+			s.requestLog(r).Error("Failed to get all keys from cache", "error", err)
+			s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+			return
+		}
 	}
+	keys, values = tenantEntries(ctx, keys, values)
+	orderEntries(keys, values, order)
 
-	s.log.Info("All keys retrieved from cache", "count", len(keys))
+	truncated := s.maxListEntries > 0 && len(keys) > s.maxListEntries
+	if truncated {
+		s.requestLog(r).Warn("List response truncated", "count", len(keys), "limit", s.maxListEntries)
+		keys, values = keys[:s.maxListEntries], values[:s.maxListEntries]
+	}
+
+	s.requestLog(r).Info("All keys retrieved from cache", "count", len(keys))
 	response := struct {
-		Keys   []string      `json:"keys"`
-		Values []interface{} `json:"values"`
+		Keys      []string      `json:"keys"`
+		Values    []interface{} `json:"values"`
+		Truncated bool          `json:"truncated"`
 	}{
-		Keys:   keys,
-		Values: values,
+		Keys:      keys,
+		Values:    values,
+		Truncated: truncated,
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		s.requestLog(r).Error("Failed to encode response", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	etag := collectionETag(body)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		s.requestLog(r).Info("List not modified", "etag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentTypeJSON)
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		s.log.Error("Failed to encode response", "error", err)
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// Порядки элементов в ответе GetAllLRUHandler (параметр order).
+const (
+	listOrderRecency = "recency" // От недавно использованных к давно использованным
+	listOrderLRU     = "lru"     // От давно использованных к недавно использованным
+	listOrderKey     = "key"     // По ключу в лексикографическом порядке
+)
+
+// orderEntries переставляет ключи и значения, полученные в порядке списка кэша,
+// в порядок order. Пустой порядок равнозначен listOrderRecency.
+func orderEntries(keys []string, values []interface{}, order string) {
+	switch order {
+	case listOrderLRU:
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+			values[i], values[j] = values[j], values[i]
+		}
+	case listOrderKey:
+		sort.Sort(entriesByKey{keys: keys, values: values})
+	}
+}
+
+// entriesByKey сортирует ключи вместе с соответствующими значениями.
+type entriesByKey struct {
+	keys   []string      // Ключи элементов
+	values []interface{} // Значения элементов в том же порядке
+}
+
+func (e entriesByKey) Len() int           { return len(e.keys) }
+func (e entriesByKey) Less(i, j int) bool { return e.keys[i] < e.keys[j] }
+func (e entriesByKey) Swap(i, j int) {
+	e.keys[i], e.keys[j] = e.keys[j], e.keys[i]
+	e.values[i], e.values[j] = e.values[j], e.values[i]
+}
+
+// errEmptySnapshot возвращается, если в снимке кэша нет актуальных элементов.
+var errEmptySnapshot = errors.New("snapshot is empty")
+
+// snapshotItems возвращает элементы снимка кэша (cache.WithSnapshotInterval), ещё не истёкшие
+// к моменту запроса, и устанавливает заголовок Age с давностью снимка в секундах.
+// Возвращает false, если режим чтения из снимка отключён.
+func (s *Server) snapshotItems(w http.ResponseWriter) ([]cache.Item, bool) {
+	snapshot := s.cache.Snapshot()
+	if snapshot == nil {
+		return nil, false
+	}
+	now := time.Now()
+	w.Header().Set("Age", strconv.FormatInt(int64(now.Sub(snapshot.TakenAt)/time.Second), 10))
+	items := make([]cache.Item, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		if item.ExpiresAt.IsZero() || item.ExpiresAt.After(now) {
+			items = append(items, item)
+		}
+	}
+	return items, true
+}
+
+// allEntries возвращает ключи и значения всех элементов: из снимка, если режим чтения
+// из снимка включён, иначе из кэша.
+func (s *Server) allEntries(ctx context.Context, w http.ResponseWriter) ([]string, []interface{}, error) {
+	items, ok := s.snapshotItems(w)
+	if !ok {
+		return s.cache.GetAll(ctx)
+	}
+	if len(items) == 0 {
+		return nil, nil, errEmptySnapshot
+	}
+	keys := make([]string, len(items))
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		keys[i], values[i] = item.Key, item.Value
+	}
+	return keys, values, nil
+}
+
+// getManyEntry описывает результат выборки одного ключа в getManyResponse.
+type getManyEntry struct {
+	Found            bool        `json:"found"`
+	Value            interface{} `json:"value,omitempty"`
+	ValueB64         *string     `json:"value_b64,omitempty"`
+	ExpiresAt        int64       `json:"expires_at,omitempty"`
+	ExpiresAtRFC3339 string      `json:"expires_at_rfc3339,omitempty"`
+}
+
+// getManyResponse отвечает на выборку элементов по набору ключей из повторяющихся
+// параметров key. Это GET-вариант пакетного чтения, удобный для простых клиентов
+// и кэшируемый прокси.
+//
+// Тело ответа (JSON):
+// - entries (object): Результат для каждого запрошенного ключа:
+//   - found (bool): Найден ли актуальный элемент.
+//   - value, value_b64, expires_at, expires_at_rfc3339: Данные найденного элемента
+//     в формате ExportLRUHandler.
+func (s *Server) getManyResponse(w http.ResponseWriter, r *http.Request, keys []string) {
+	ctx := r.Context()
+	cacheKeys := make([]string, len(keys))
+	for i, key := range keys {
+		cacheKeys[i] = tenantKey(ctx, key)
+	}
+	items, err := s.cache.GetMany(ctx, cacheKeys)
+	if err != nil {
+		s.requestLog(r).Error("Failed to get keys from cache", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	entries := make(map[string]getManyEntry, len(keys))
+	for i, key := range keys {
+		item, ok := items[cacheKeys[i]]
+		if !ok {
+			entries[key] = getManyEntry{}
+			continue
+		}
+		entry := itemEntry(item)
+		entries[key] = getManyEntry{
+			Found:            true,
+			Value:            entry.Value,
+			ValueB64:         entry.ValueB64,
+			ExpiresAt:        entry.ExpiresAt,
+			ExpiresAtRFC3339: entry.ExpiresAtRFC3339,
+		}
+	}
+
+	s.requestLog(r).Info("Keys retrieved from cache", "requested", len(keys), "found", len(items))
+	response := struct {
+		Entries map[string]getManyEntry `json:"entries"`
+	}{
+		Entries: entries,
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// popSuffix завершает путь запроса на атомарное получение и удаление элемента.
+const popSuffix = "/pop"
+
+// PopLRUHandler обрабатывает POST-запрос на атомарное получение и удаление элемента.
+// В отличие от последовательных GET и DELETE, значение получает только один из
+// одновременных запросов, что подходит для одноразовых токенов и очередей задач.
+//
+// Метод:
+// - POST /api/lru/{key}/pop
+//
+// Параметры пути:
+// - key (string): Ключ элемента. Может содержать символы «/»; зарезервированные символы
+// должны быть закодированы (percent-encoding). Имена служебных маршрутов (stats, export
+// и другие, см. reservedKeys) ключами быть не могут.
+//
+// Тело ответа (JSON):
+// - key (string): Ключ элемента.
+// - value (interface{}): Значение элемента.
+// - value_b64 (string, optional): Двоичное значение в кодировке base64.
+// - expires_at (int): Время истечения срока действия в формате Unix.
+// - expires_at_rfc3339 (string): Время истечения срока действия в формате RFC 3339.
+// - remaining_seconds (int): Оставшееся время жизни элемента в секундах.
+//
+// Ответы:
+// - 200 OK: Элемент получен и удалён.
+// - 400 Bad Request: Некорректно закодированный ключ.
+// - 404 Not Found: Ключ не найден или истёк срок действия; тело JSON с кодом key_not_found.
+// Путь без суффикса /pop — тело JSON с кодом route_not_found.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) PopLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+	key, ok := strings.CutSuffix(chi.URLParam(r, "*"), popSuffix)
+	if !ok || key == "" {
+		s.NotFoundHandler(w, r)
+		return
+	}
+	key, err := decodeKey(r, key)
+	if err != nil {
+		s.requestLog(r).Error("Invalid key in path", "error", err)
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid key")
+		return
+	}
+
+	spanCtx, span := s.startCacheSpan(ctx, "pop", key)
+	value, expiresAt, err := s.cache.Pop(spanCtx, tenantKey(ctx, key))
+	endCacheSpan(span, err == nil, err, expiresAt)
+	if err != nil {
+		s.writeCacheError(w, r, err, "Failed to pop key from cache")
+		return
+	}
+
+	s.requestLog(r).Info("Key popped from cache", "key", key, "reason", cache.EvictReasonDeleted)
+	expiresAtUnix, expiresAtRFC3339 := formatTimestamp(expiresAt)
+	value, valueB64 := responseValue(value)
+	response := struct {
+		Key              string      `json:"key"`
+		Value            interface{} `json:"value"`
+		ValueB64         *string     `json:"value_b64,omitempty"`
+		ExpiresAt        int64       `json:"expires_at"`
+		ExpiresAtRFC3339 string      `json:"expires_at_rfc3339"`
+		RemainingSeconds int64       `json:"remaining_seconds"`
+	}{
+		Key:              key,
+		Value:            value,
+		ValueB64:         valueB64,
+		ExpiresAt:        expiresAtUnix,
+		ExpiresAtRFC3339: expiresAtRFC3339,
+		RemainingSeconds: remainingSeconds(expiresAt),
 	}
+	s.writeJSON(w, r, http.StatusOK, response)
 }
 
 // DeleteLRUHandler обрабатывает DELETE-запрос на удаление элемента по ключу.
@@ -148,29 +757,65 @@ func (s *Server) GetAllLRUHandler(w http.ResponseWriter, r *http.Request) {
 // - DELETE /api/lru/{key}
 //
 // Параметры пути:
-// - key (string): Ключ элемента.
+// - key (string): Ключ элемента. Может содержать символы «/»; зарезервированные символы
+// должны быть закодированы (percent-encoding). Имена служебных маршрутов (stats, export
+// и другие, см. reservedKeys) ключами быть не могут.
+//
+// Заголовки запроса:
+// - If-Match (optional): ETag версии элемента. Элемент удаляется, только если
+// он существует и его ETag совпадает.
+// - If-Unmodified-Since (optional): HTTP-дата. Элемент удаляется, только если
+// он существует и не изменялся после этого момента.
 //
 // Ответы:
 // - 204 No Content: Элемент успешно удалён.
-// - 404 Not Found: Ключ не найден.
+// - 400 Bad Request: Некорректно закодированный ключ.
+// - 404 Not Found: Ключ не найден; тело JSON с кодом key_not_found.
+// - 412 Precondition Failed: Условие If-Match или If-Unmodified-Since не выполнено
+// или элемент отсутствует.
+// - 499 Client Closed Request: Запрос отменён клиентом.
 // - 500 Internal Server Error: Ошибка сервера.
 func (s *Server) DeleteLRUHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	s.log.Info("Processing request", "method", r.Method, "path", r.URL.Path)
-	select {
-	case <-ctx.Done():
-		s.log.Warn("Request cancelled", "method", r.Method, "path", r.URL.Path)
-		http.Error(w, "request cancelled", http.StatusInternalServerError)
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+	key, err := keyParam(r)
+	if err != nil {
+		s.requestLog(r).Error("Invalid key in path", "error", err)
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid key")
+		return
+	}
+
+	var opts cache.EvictOptions
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		version, ok := parseETag(ifMatch)
+		if !ok {
+			s.requestLog(r).Warn("Invalid If-Match header", "if_match", ifMatch)
+			s.writeError(w, r, http.StatusPreconditionFailed, errorCodePreconditionFailed, cache.ErrPreconditionFailed.Error())
+			return
+		}
+		opts.IfVersion = version
+	}
+	// Некорректная дата в If-Unmodified-Since игнорируется (RFC 9110, раздел 13.1.4)
+	if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil {
+		// HTTP-даты имеют точность до секунды, а время записи элемента — нет
+		opts.IfUnmodifiedSince = since.Add(time.Second - time.Nanosecond)
+	}
+
+	_, err = s.cache.EvictWithOptions(ctx, tenantKey(ctx, key), opts)
+	if errors.Is(err, cache.ErrPreconditionFailed) {
+		s.requestLog(r).Warn("Precondition failed for key", "key", key,
+			"if_match", r.Header.Get("If-Match"), "if_unmodified_since", r.Header.Get("If-Unmodified-Since"))
+		s.writeError(w, r, http.StatusPreconditionFailed, errorCodePreconditionFailed, err.Error())
 		return
-	default:
 	}
-	key := chi.URLParam(r, "key")
-	_, err := s.cache.Evict(ctx, key)
 	if err != nil {
-		s.log.Error("Failed to delete key from cache", "error", err)
-		http.Error(w, err.Error(), http.StatusNotFound)
+		s.writeCacheError(w, r, err, "Failed to delete key from cache")
+		return
 	}
-	s.log.Info("Key deleted from cache", "key", key)
+	s.requestLog(r).Info("Key deleted from cache", "key", key, "reason", cache.EvictReasonDeleted)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -179,24 +824,1088 @@ func (s *Server) DeleteLRUHandler(w http.ResponseWriter, r *http.Request) {
 // Метод:
 // - DELETE /api/lru
 //
+// При разделении по арендаторам (WithTenantHeader) удаляются только элементы арендатора.
+//
 // Ответы:
-// - 204 No Content: Все элементы успешно удалены.
+// - 204 No Content: Все элементы успешно удалены или кэш уже пуст.
+// - 499 Client Closed Request: Запрос отменён клиентом.
 // - 500 Internal Server Error: Ошибка сервера.
 func (s *Server) DeleteAllLRUHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	s.log.Info("Processing request", "method", r.Method, "path", r.URL.Path)
-	select {
-	case <-ctx.Done():
-		s.log.Warn("Request cancelled", "method", r.Method, "path", r.URL.Path)
-		http.Error(w, "request cancelled", http.StatusInternalServerError)
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	// Очистка пустого кэша считается успешной, как и при разделении по арендаторам
+	if err := s.evictAll(ctx); err != nil && !errors.Is(err, cache.ErrEmptyCache) {
+		s.requestLog(r).Error("Failed to delete all keys from cache", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+	s.requestLog(r).Info("All keys successfully deleted from cache", "reason", cache.EvictReasonDeleted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplaceAllLRUHandler обрабатывает PUT-запрос на атомарную замену всего содержимого кэша.
+//
+// Метод:
+// - PUT /api/lru
+//
+// Тело запроса (JSON):
+// - entries (array): Новое содержимое кэша в формате строк ExportLRUHandler, от недавно
+// использованных к давно использованным. Поле expires_at (int, optional) задаёт время
+// истечения срока жизни в формате Unix; без него используется TTL по умолчанию.
+// Элементы с истёкшим сроком жизни пропускаются. Пустой список очищает кэш.
+//
+// Содержимое заменяется под одной блокировкой (cache.ReplaceAll), поэтому параллельные
+// чтения видят либо прежний, либо новый набор целиком. При некорректном элементе
+// содержимое кэша не изменяется. При разделении по арендаторам (WithTenantHeader)
+// замена не поддерживается, так как затронула бы элементы всех арендаторов.
+//
+// Ответы:
+// - 204 No Content: Содержимое кэша заменено.
+// - 400 Bad Request: Некорректный запрос или элемент; ответ содержит список ошибок проверки полей.
+// - 415 Unsupported Media Type: Content-Type запроса отсутствует или не является application/json.
+// - 422 Unprocessable Entity: Кэш отклонил набор (повтор ключа, элемент больше бюджета памяти);
+// код cache_rejected с причиной отказа.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) ReplaceAllLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) || !s.requireJSON(w, r) {
+		return
+	}
+	if tenantPrefix(ctx) != "" {
+		s.requestLog(r).Warn("Replace all rejected in tenant mode")
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "replacing all entries is not supported with tenant namespacing")
+		return
+	}
+
+	var replaceRequest struct {
+		Entries *[]exportEntry `json:"entries"`
+	}
+	if err := s.newDecoder(r.Body).Decode(&replaceRequest); err != nil {
+		s.writeDecodeError(w, r, err)
+		return
+	}
+	if replaceRequest.Entries == nil {
+		s.writeValidationErrors(w, r, validationErrors{{Field: "entries", Message: "entries are required"}})
+		return
+	}
+
+	var errs validationErrors
+	items := make([]cache.Item, len(*replaceRequest.Entries))
+	for i, entry := range *replaceRequest.Entries {
+		field := fmt.Sprintf("entries[%d]", i)
+		if entry.Key == "" {
+			errs.add(field+".key", "key is required")
+		} else if err := validateKey(entry.Key); err != nil {
+			errs.add(field+".key", err.Error())
+		}
+		value, err := requestValue(entry.Value, entry.ValueB64)
+		if err != nil {
+			errs.add(field+".value_b64", err.Error())
+		} else if value == nil {
+			errs.add(field+".value", "value or value_b64 is required")
+		}
+		if err := validateTags(entry.Tags); err != nil {
+			errs.add(field+".tags", err.Error())
+		}
+		items[i] = cache.Item{Key: entry.Key, Value: value, Tags: entry.Tags}
+		if entry.ExpiresAt != 0 {
+			items[i].ExpiresAt = time.Unix(entry.ExpiresAt, 0)
+		}
+	}
+	if len(errs) > 0 {
+		s.writeValidationErrors(w, r, errs)
 		return
-	default:
 	}
 
-	if err := s.cache.EvictAll(ctx); err != nil {
-		s.log.Error("Failed to delete all keys from cache", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.cache.ReplaceAll(ctx, items); err != nil {
+		if errors.Is(err, cache.ErrClosed) || ctx.Err() != nil {
+			s.requestLog(r).Error("Failed to replace cache contents", "error", err)
+			s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+			return
+		}
+		s.writeRejected(w, r, err)
+		return
 	}
-	s.log.Info("All keys successfully deleted from cache")
+	s.requestLog(r).Info("Cache contents replaced", "count", len(items))
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// exportEntry описывает элемент кэша в строке NDJSON при экспорте и импорте,
+// а также в списках недавно использованных элементов.
+type exportEntry struct {
+	Key              string      `json:"key"`
+	Value            interface{} `json:"value"`
+	ValueB64         *string     `json:"value_b64,omitempty"`
+	ExpiresAt        int64       `json:"expires_at"`
+	ExpiresAtRFC3339 string      `json:"expires_at_rfc3339,omitempty"`
+	Tags             cache.Tags  `json:"tags,omitempty"`
+}
+
+// exportFlushInterval — число строк, после которого ответ экспорта сбрасывается клиенту.
+const exportFlushInterval = 100
+
+// ExportLRUHandler обрабатывает GET-запрос на экспорт содержимого кэша.
+//
+// Метод:
+// - GET /api/lru/export
+//
+// Тело ответа (NDJSON): по одной строке на каждый актуальный элемент:
+// - key (string): Ключ элемента.
+// - value (interface{}): Значение элемента; null для двоичных значений.
+// - value_b64 (string, optional): Двоичное значение в кодировке base64.
+// - expires_at (int): Время истечения срока жизни в формате Unix; 0 для бессрочного элемента.
+// - expires_at_rfc3339 (string): Время истечения срока жизни в формате RFC3339 (UTC).
+// - tags (object, optional): Метаданные элемента.
+//
+// Элементы кодируются и отправляются по одному из снимка кэша, без буферизации всего ответа.
+// В режиме чтения из снимка (cache.WithSnapshotInterval) используется последний
+// периодический снимок, а заголовок Age содержит его давность в секундах.
+//
+// Ответы:
+// - 200 OK: Поток элементов (может быть пустым).
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) ExportLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	items, ok := s.snapshotItems(w)
+	if !ok {
+		var err error
+		items, err = s.cache.Items(ctx)
+		if err != nil {
+			s.requestLog(r).Error("Failed to snapshot cache", "error", err)
+			s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+			return
+		}
+	}
+	items = tenantItems(ctx, items)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for i, item := range items {
+		if ctx.Err() != nil {
+			s.requestLog(r).Warn("Export cancelled", "exported", i)
+			return
+		}
+		if err := encoder.Encode(itemEntry(item)); err != nil {
+			s.requestLog(r).Error("Failed to encode export entry", "key", item.Key, "error", err)
+			return
+		}
+		if flusher != nil && (i+1)%exportFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	s.requestLog(r).Info("Cache exported", "count", len(items))
+}
+
+// ImportLRUHandler обрабатывает POST-запрос на импорт элементов в кэш.
+//
+// Метод:
+// - POST /api/lru/import
+//
+// Тело запроса (NDJSON): строки в формате ExportLRUHandler. Поле expires_at (int, optional)
+// задаёт время истечения срока жизни в формате Unix; без него используется TTL по умолчанию.
+// Тело читается построчно, без буферизации целиком.
+//
+// Тело ответа (JSON):
+// - imported (int): Число добавленных элементов.
+// - skipped (int): Число пропущенных элементов с истёкшим сроком жизни.
+// - failed (int): Число некорректных строк и элементов, которые не удалось добавить.
+//
+// Ответы:
+// - 200 OK: Импорт завершён.
+// - 400 Bad Request: Ошибка чтения тела запроса.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+func (s *Server) ImportLRUHandler(w http.ResponseWriter, r *http.Request) {
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	var summary struct {
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+		Failed   int `json:"failed"`
+	}
+
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			switch s.importLine(r, line) {
+			case importImported:
+				summary.Imported++
+			case importSkipped:
+				summary.Skipped++
+			default:
+				summary.Failed++
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			s.requestLog(r).Error("Failed to read import body", "error", readErr)
+			s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "failed to read request body")
+			return
+		}
+	}
+
+	s.requestLog(r).Info("Cache imported", "imported", summary.Imported, "skipped", summary.Skipped, "failed", summary.Failed)
+	s.writeJSON(w, r, http.StatusOK, summary)
+}
+
+// itemEntry преобразует элемент кэша в exportEntry.
+func itemEntry(item cache.Item) exportEntry {
+	expiresAt, expiresAtRFC3339 := formatTimestamp(item.ExpiresAt)
+	value, valueB64 := responseValue(item.Value)
+	return exportEntry{
+		Key:              item.Key,
+		Value:            value,
+		ValueB64:         valueB64,
+		ExpiresAt:        expiresAt,
+		ExpiresAtRFC3339: expiresAtRFC3339,
+		Tags:             item.Tags,
+	}
+}
+
+// defaultListLimit — число элементов в списках по умолчанию, если параметр n не задан.
+const defaultListLimit = 20
+
+// listLimit возвращает число элементов списка из параметра запроса n.
+func listLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("n")
+	if raw == "" {
+		return defaultListLimit, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, errors.New("n must be a positive integer")
+	}
+	return n, nil
+}
+
+// RecentLRUHandler обрабатывает GET-запрос на получение недавно использованных элементов.
+//
+// Метод:
+// - GET /api/lru/recent?n=N
+//
+// Параметры запроса:
+// - n (int, optional): Число элементов, по умолчанию defaultListLimit.
+//
+// Тело ответа (JSON):
+// - items (array): До n элементов от недавно использованных к давно использованным
+// в формате ExportLRUHandler.
+//
+// Ответы:
+// - 200 OK: Успешный ответ со списком элементов.
+// - 400 Bad Request: Некорректный параметр n.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) RecentLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	n, err := listLimit(r)
+	if err != nil {
+		s.requestLog(r).Error("Invalid n parameter", "n", r.URL.Query().Get("n"))
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+
+	items, err := s.cache.Recent(ctx, n, tenantPrefix(ctx))
+	if err != nil {
+		s.requestLog(r).Error("Failed to list recent keys", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+	s.writeItemList(w, r, tenantItems(ctx, items))
+}
+
+// LeastLRUHandler обрабатывает GET-запрос на получение давно использованных элементов —
+// ближайших кандидатов на вытеснение.
+//
+// Метод:
+// - GET /api/lru/least?n=N
+//
+// Параметры запроса:
+// - n (int, optional): Число элементов, по умолчанию defaultListLimit.
+//
+// Тело ответа (JSON):
+// - items (array): До n элементов от давно использованных к недавно использованным
+// в формате ExportLRUHandler. Истёкшие элементы в конце списка удаляются.
+//
+// Ответы:
+// - 200 OK: Успешный ответ со списком элементов.
+// - 400 Bad Request: Некорректный параметр n.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) LeastLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	n, err := listLimit(r)
+	if err != nil {
+		s.requestLog(r).Error("Invalid n parameter", "n", r.URL.Query().Get("n"))
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error())
+		return
+	}
+
+	items, err := s.cache.Least(ctx, n, tenantPrefix(ctx))
+	if err != nil {
+		s.requestLog(r).Error("Failed to list least recently used keys", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+	s.writeItemList(w, r, tenantItems(ctx, items))
+}
+
+// AgeLRUHandler обрабатывает GET-запрос на получение давности данных в кэше.
+//
+// Метод:
+// - GET /api/lru/age
+//
+// Тело ответа (JSON):
+// - oldest_age_seconds (number): Время в секундах с последнего использования наименее
+// недавно использованного элемента; 0 для пустого кэша.
+// - next_expiry (int): Ближайшее время истечения срока жизни в формате Unix; 0, если истекающих элементов нет.
+// - next_expiry_rfc3339 (string): Ближайшее время истечения срока жизни в формате RFC3339 (UTC).
+//
+// Ответы:
+// - 200 OK: Успешный ответ с давностью данных.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) AgeLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	stats, err := s.cache.AgeStats(ctx)
+	if err != nil {
+		s.requestLog(r).Error("Failed to get cache age stats", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	nextExpiry, nextExpiryRFC3339 := formatTimestamp(stats.NextExpiry)
+	response := struct {
+		OldestAgeSeconds  float64 `json:"oldest_age_seconds"`
+		NextExpiry        int64   `json:"next_expiry"`
+		NextExpiryRFC3339 string  `json:"next_expiry_rfc3339"`
+	}{
+		OldestAgeSeconds:  stats.OldestAge.Seconds(),
+		NextExpiry:        nextExpiry,
+		NextExpiryRFC3339: nextExpiryRFC3339,
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// StatsLRUHandler обрабатывает GET-запрос на получение доли попаданий чтения по ключу
+// за скользящее окно (см. cache.HitRatioWindow).
+//
+// Метод:
+// - GET /api/lru/stats
+//
+// Тело ответа (JSON):
+// - hits (int): Число чтений, нашедших элемент.
+// - misses (int): Число чтений отсутствующих или истёкших элементов.
+// - hit_ratio (number): Доля попаданий hits / (hits + misses); 0, если чтений не было.
+// - window_seconds (int): Длительность окна в секундах.
+//
+// Ответы:
+// - 200 OK: Успешный ответ со статистикой.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+func (s *Server) StatsLRUHandler(w http.ResponseWriter, r *http.Request) {
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	stats := s.cache.HitRatio()
+	response := struct {
+		Hits          uint64  `json:"hits"`
+		Misses        uint64  `json:"misses"`
+		HitRatio      float64 `json:"hit_ratio"`
+		WindowSeconds int64   `json:"window_seconds"`
+	}{
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		HitRatio:      stats.Ratio,
+		WindowSeconds: int64(cache.HitRatioWindow / time.Second),
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// ChangesLRUHandler обрабатывает GET-запрос на получение изменений ключей после версии
+// для инкрементальной синхронизации клиента.
+//
+// Метод:
+// - GET /api/lru/changes?since=N
+//
+// Параметры запроса:
+// - since (int, optional): Версия, полученная в предыдущем ответе. 0 или отсутствие
+// параметра — начальная синхронизация: возвращаются все актуальные ключи.
+//
+// При разделении по арендаторам (WithTenantHeader) возвращаются только ключи арендатора.
+//
+// Тело ответа (JSON):
+// - version (int): Текущая версия кэша для следующего запроса.
+// - changes (array): Изменённые ключи в порядке изменения: key, version и deleted
+// (true, если элемент удалён, истёк или вытеснен).
+//
+// Ответы:
+// - 200 OK: Успешный ответ со списком изменений.
+// - 400 Bad Request: Некорректный параметр since.
+// - 410 Gone: Часть удалений после since уже не хранится; тело JSON с кодом
+// changes_expired. Клиенту нужна полная синхронизация с since=0.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) ChangesLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) {
+		return
+	}
+
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			s.writeValidationErrors(w, r, validationErrors{{Field: "since", Message: "since must be a non-negative integer"}})
+			return
+		}
+		since = parsed
+	}
+
+	changes, version, err := s.cache.Changes(ctx, since)
+	if errors.Is(err, cache.ErrChangesExpired) {
+		s.requestLog(r).Warn("Changes no longer available", "since", since, "version", version)
+		s.writeError(w, r, http.StatusGone, errorCodeChangesExpired, err.Error())
+		return
+	}
+	if err != nil {
+		s.requestLog(r).Error("Failed to list changes", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	type changeEntry struct {
+		Key     string `json:"key"`
+		Version uint64 `json:"version"`
+		Deleted bool   `json:"deleted"`
+	}
+	response := struct {
+		Version uint64        `json:"version"`
+		Changes []changeEntry `json:"changes"`
+	}{Version: version, Changes: []changeEntry{}}
+	for _, change := range changes {
+		key, ok := tenantOwns(ctx, change.Key)
+		if !ok {
+			continue
+		}
+		response.Changes = append(response.Changes, changeEntry{Key: key, Version: change.Version, Deleted: change.Deleted})
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// writeItemList отвечает на запрос r списком элементов кэша.
+func (s *Server) writeItemList(w http.ResponseWriter, r *http.Request, items []cache.Item) {
+	response := struct {
+		Items []exportEntry `json:"items"`
+	}{
+		Items: make([]exportEntry, 0, len(items)),
+	}
+	for _, item := range items {
+		response.Items = append(response.Items, itemEntry(item))
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// Результаты импорта одной строки NDJSON
+const (
+	importImported = iota // Элемент добавлен в кэш
+	importSkipped         // Срок жизни элемента истёк
+	importFailed          // Строка некорректна или элемент не удалось добавить
+)
+
+// importLine добавляет в кэш элемент из одной строки NDJSON и возвращает результат импорта.
+func (s *Server) importLine(r *http.Request, line []byte) int {
+	ctx := r.Context()
+	var entry exportEntry
+	if err := s.decodeJSON(line, &entry); err != nil {
+		s.requestLog(r).Warn("Invalid import line", "error", err)
+		return importFailed
+	}
+
+	value, err := requestValue(entry.Value, entry.ValueB64)
+	if err != nil {
+		s.requestLog(r).Warn("Invalid import value", "key", entry.Key, "error", err)
+		return importFailed
+	}
+
+	var ttl time.Duration
+	if entry.ExpiresAt != 0 {
+		ttl = time.Until(time.Unix(entry.ExpiresAt, 0))
+		if ttl <= 0 {
+			return importSkipped
+		}
+	}
+
+	if err := validateKey(entry.Key); err != nil {
+		s.requestLog(r).Warn("Invalid import key", "key", entry.Key, "error", err)
+		return importFailed
+	}
+	if err := validateTags(entry.Tags); err != nil {
+		s.requestLog(r).Warn("Invalid import tags", "key", entry.Key, "error", err)
+		return importFailed
+	}
+	if _, _, err := s.cache.PutWithOptions(ctx, tenantKey(ctx, entry.Key), value, cache.PutOptions{TTL: ttl, Tags: entry.Tags}); err != nil {
+		s.requestLog(r).Warn("Failed to import key", "key", entry.Key, "error", err)
+		return importFailed
+	}
+	return importImported
+}
+
+// TouchLRUHandler обрабатывает POST-запрос на продление срока жизни набора элементов.
+//
+// Метод:
+// - POST /api/lru/touch
+//
+// Тело запроса (JSON):
+// - keys (array): Ключи элементов.
+// - ttl_seconds (int, optional): Новое время жизни элементов в секундах от текущего момента;
+// без него используется TTL по умолчанию.
+//
+// Отсутствующие и истёкшие ключи, а также закешированное отсутствие значения пропускаются.
+// Версия (ETag) продлённых элементов меняется.
+//
+// Тело ответа (JSON):
+// - touched (int): Число продлённых элементов.
+//
+// Ответы:
+// - 200 OK: Срок жизни элементов продлён.
+// - 400 Bad Request: Некорректный запрос; ответ содержит список ошибок проверки полей.
+// - 415 Unsupported Media Type: Content-Type запроса отсутствует или не является application/json.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) TouchLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) || !s.requireJSON(w, r) {
+		return
+	}
+
+	var touchRequest struct {
+		Keys       []string `json:"keys"`
+		TTLSeconds *int64   `json:"ttl_seconds,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&touchRequest); err != nil {
+		s.writeDecodeError(w, r, err)
+		return
+	}
+
+	var errs validationErrors
+	if len(touchRequest.Keys) == 0 {
+		errs.add("keys", "keys are required")
+	}
+	var ttl time.Duration
+	if touchRequest.TTLSeconds != nil {
+		var err error
+		if ttl, err = ttlFromSeconds(*touchRequest.TTLSeconds); err != nil {
+			errs.add("ttl_seconds", err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		s.writeValidationErrors(w, r, errs)
+		return
+	}
+
+	keys := make([]string, len(touchRequest.Keys))
+	for i, key := range touchRequest.Keys {
+		keys[i] = tenantKey(ctx, key)
+	}
+	touched, err := s.cache.TouchMany(ctx, keys, ttl)
+	if err != nil {
+		s.requestLog(r).Error("Failed to touch keys", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	s.requestLog(r).Info("Keys touched", "requested", len(keys), "touched", touched)
+	response := struct {
+		Touched int `json:"touched"`
+	}{
+		Touched: touched,
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// TTLLRUHandler обрабатывает POST-запрос на получение оставшегося времени жизни
+// набора элементов. Элементы читаются под одной блокировкой и не перемещаются в начало
+// списка, что позволяет клиентам дёшево синхронизировать собственные таймеры истечения.
+//
+// Метод:
+// - POST /api/lru/ttl
+//
+// Тело запроса (JSON):
+// - keys (array): Ключи элементов.
+//
+// Тело ответа (JSON):
+// - remaining_seconds (object): Оставшееся время жизни в секундах по ключам; -1 для
+// бессрочного элемента. Отсутствующие и истёкшие ключи в ответ не попадают.
+//
+// Ответы:
+// - 200 OK: Время жизни элементов получено.
+// - 400 Bad Request: Некорректный запрос; ответ содержит список ошибок проверки полей.
+// - 415 Unsupported Media Type: Content-Type запроса отсутствует или не является application/json.
+// - 499 Client Closed Request: Запрос отменён клиентом.
+// - 500 Internal Server Error: Ошибка сервера.
+func (s *Server) TTLLRUHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.requestLog(r).Info("Processing request")
+	if s.requestCancelled(w, r) || !s.requireJSON(w, r) {
+		return
+	}
+
+	var ttlRequest struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&ttlRequest); err != nil {
+		s.writeDecodeError(w, r, err)
+		return
+	}
+	if len(ttlRequest.Keys) == 0 {
+		s.writeValidationErrors(w, r, validationErrors{{Field: "keys", Message: "keys are required"}})
+		return
+	}
+
+	keys := make([]string, len(ttlRequest.Keys))
+	for i, key := range ttlRequest.Keys {
+		keys[i] = tenantKey(ctx, key)
+	}
+	expiresAt, err := s.cache.ExpiresAtMany(ctx, keys)
+	if err != nil {
+		s.requestLog(r).Error("Failed to get keys TTL", "error", err)
+		s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	remaining := make(map[string]int64, len(expiresAt))
+	for i, key := range ttlRequest.Keys {
+		if t, ok := expiresAt[keys[i]]; ok {
+			remaining[key] = remainingSeconds(t)
+		}
+	}
+	s.requestLog(r).Info("Keys TTL fetched", "requested", len(keys), "found", len(remaining))
+	response := struct {
+		RemainingSeconds map[string]int64 `json:"remaining_seconds"`
+	}{
+		RemainingSeconds: remaining,
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// CapacityCheckHandler обрабатывает GET-запрос на оценку вместимости кэша.
+//
+// Метод:
+// - GET /api/lru/capacity-check?count=N&avg_bytes=B
+//
+// Параметры запроса:
+// - count (int): Ожидаемое число записываемых элементов.
+// - avg_bytes (int, optional): Средний размер значения в байтах.
+//
+// Тело ответа (JSON):
+// - count, avg_bytes: Параметры запроса.
+// - capacity (int): Ёмкость кэша (0 — неограниченный кэш).
+// - max_bytes (int): Бюджет памяти кэша в байтах (0 — не ограничен).
+// - current_size (int): Текущее число элементов.
+// - current_bytes (int): Текущий оценочный размер элементов в байтах.
+// - estimated_bytes (int): Оценка суммарного размера записываемых значений.
+// - fits (bool): Поместятся ли все элементы в кэш вместе с текущими без вытеснения
+// (evicted равно 0).
+// - evicted (int): Оценка числа элементов, которые будут вытеснены при записи: больший из
+// избытков по ёмкости и по бюджету памяти; избыток памяти переводится в число элементов
+// по среднему размеру текущих.
+//
+// Ответы:
+// - 200 OK: Успешный ответ с оценкой.
+// - 400 Bad Request: Некорректные параметры запроса.
+func (s *Server) CapacityCheckHandler(w http.ResponseWriter, r *http.Request) {
+	s.requestLog(r).Info("Processing request")
+
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 0 {
+		s.requestLog(r).Error("Invalid count parameter", "count", r.URL.Query().Get("count"))
+		s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "count must be a non-negative integer")
+		return
+	}
+
+	var avgBytes int
+	if raw := r.URL.Query().Get("avg_bytes"); raw != "" {
+		avgBytes, err = strconv.Atoi(raw)
+		if err != nil || avgBytes < 0 {
+			s.requestLog(r).Error("Invalid avg_bytes parameter", "avg_bytes", raw)
+			s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "avg_bytes must be a non-negative integer")
+			return
+		}
+	}
+
+	capacity := s.cache.Capacity()
+	maxBytes := s.cache.MaxBytes()
+	currentSize := s.cache.Len()
+	currentBytes := s.cache.UsedBytes()
+	estimatedBytes := int64(count) * int64(avgBytes)
+
+	// Неограниченный кэш (ёмкость не больше 0) ограничен только бюджетом памяти
+	var evicted int64
+	if capacity > 0 {
+		evicted = max(int64(currentSize)+int64(count)-int64(capacity), 0)
+	}
+	if excess := currentBytes + estimatedBytes - maxBytes; maxBytes > 0 && excess > 0 {
+		// Вытесняются давно использованные элементы, поэтому избыток переводится в число
+		// элементов по среднему размеру текущих, а для пустого кэша — записываемых
+		entryBytes := int64(avgBytes)
+		if currentSize > 0 {
+			entryBytes = currentBytes / int64(currentSize)
+		}
+		entryBytes = max(entryBytes, 1)
+		byBytes := min((excess+entryBytes-1)/entryBytes, int64(currentSize)+int64(count))
+		evicted = max(evicted, byBytes)
+	}
+
+	response := struct {
+		Count          int   `json:"count"`
+		AvgBytes       int   `json:"avg_bytes"`
+		Capacity       int   `json:"capacity"`
+		MaxBytes       int64 `json:"max_bytes"`
+		CurrentSize    int   `json:"current_size"`
+		CurrentBytes   int64 `json:"current_bytes"`
+		EstimatedBytes int64 `json:"estimated_bytes"`
+		Fits           bool  `json:"fits"`
+		Evicted        int64 `json:"evicted"`
+	}{
+		Count:          count,
+		AvgBytes:       avgBytes,
+		Capacity:       capacity,
+		MaxBytes:       maxBytes,
+		CurrentSize:    currentSize,
+		CurrentBytes:   currentBytes,
+		EstimatedBytes: estimatedBytes,
+		Fits:           evicted == 0,
+		Evicted:        evicted,
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// maxTTLSeconds — максимальное значение ttl_seconds, представимое в time.Duration (около 292 лет).
+const maxTTLSeconds = int64(math.MaxInt64 / time.Second)
+
+// errNegativeTTL возвращается, если TTL в запросе отрицателен.
+var errNegativeTTL = errors.New("ttl cannot be negative")
+
+// errTTLTooLarge возвращается, если ttl_seconds превышает maxTTLSeconds.
+var errTTLTooLarge = fmt.Errorf("ttl_seconds cannot exceed %d", maxTTLSeconds)
+
+// requestTTL определяет TTL элемента по полю ttl_seconds и заголовку X-Cache-TTL.
+// Поле тела имеет приоритет над заголовком. Признак explicit сообщает, что TTL задан
+// явно: в этом случае 0 означает немедленное истечение. Если не задано ни то, ни другое,
+// возвращается 0 и false (TTL по умолчанию).
+func requestTTL(ttlSeconds *int64, ttlHeader string) (ttl time.Duration, explicit bool, err error) {
+	if ttlSeconds != nil {
+		ttl, err = ttlFromSeconds(*ttlSeconds)
+		return ttl, err == nil, err
+	}
+	if ttlHeader == "" {
+		return 0, false, nil
+	}
+	ttl, err = time.ParseDuration(ttlHeader)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid X-Cache-TTL header: %w", err)
+	}
+	if ttl < 0 {
+		return 0, false, errNegativeTTL
+	}
+	return ttl, true, nil
+}
+
+// ttlFromSeconds преобразует ttl_seconds в time.Duration без переполнения.
+func ttlFromSeconds(seconds int64) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, errNegativeTTL
+	}
+	if seconds > maxTTLSeconds {
+		return 0, errTTLTooLarge
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// waitParam разбирает параметр wait запроса GET /api/lru/{key} и ограничивает его
+// максимальным временем ожидания сервера. Пустой параметр означает отсутствие ожидания.
+func (s *Server) waitParam(raw string) (time.Duration, error) {
+	if raw == "" || s.maxWait == 0 {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wait duration: %w", err)
+	}
+	if wait < 0 {
+		return 0, errors.New("wait cannot be negative")
+	}
+	return min(wait, s.maxWait), nil
+}
+
+// waitItem ожидает записи ключа не дольше wait и возвращает записанный элемент.
+// По истечении wait возвращается ошибка контекста.
+func (s *Server) waitItem(ctx context.Context, key string, wait time.Duration) (cache.Item, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	if _, _, err := s.cache.WaitGet(waitCtx, key); err != nil {
+		return cache.Item{}, err
+	}
+	return s.cache.GetItem(ctx, key)
+}
+
+// StatusClientClosedRequest — нестандартный код ответа 499 (Client Closed Request),
+// которым обозначаются запросы, отменённые клиентом до получения ответа.
+const StatusClientClosedRequest = 499
+
+// requestCancelled проверяет, отменён ли контекст запроса, и в этом случае отвечает
+// кодом, заданным WithCancelledStatus. Возвращает true, если обработку нужно прекратить.
+func (s *Server) requestCancelled(w http.ResponseWriter, r *http.Request) bool {
+	if r.Context().Err() == nil {
+		return false
+	}
+	s.requestLog(r).Warn("Request cancelled")
+	s.writeError(w, r, s.cancelledStatus, errorCodeRequestCancelled, "request cancelled")
+	return true
+}
+
+// writeCacheError отвечает на ошибку чтения или удаления элемента и записывает её в журнал
+// с сообщением message: отменённый запрос — через requestCancelled, отсутствующий элемент
+// (cache.IsNotFound) — кодом 404 key_not_found, остальные ошибки, в том числе закрытый
+// кэш, — кодом 500.
+func (s *Server) writeCacheError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if s.requestCancelled(w, r) {
+		return
+	}
+	s.requestLog(r).Error(message, "error", err)
+	if cache.IsNotFound(err) {
+		s.writeError(w, r, http.StatusNotFound, errorCodeKeyNotFound, err.Error())
+		return
+	}
+	s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, err.Error())
+}
+
+// errValueConflict возвращается, если в запросе заданы одновременно value и value_b64.
+var errValueConflict = errors.New("value and value_b64 are mutually exclusive")
+
+// requireJSON проверяет, что тело запроса передано в формате application/json,
+// и иначе отвечает кодом 415. Возвращает false, если обработку нужно прекратить.
+func (s *Server) requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "application/json" {
+		return true
+	}
+	s.requestLog(r).Warn("Unsupported content type", "content_type", contentType)
+	s.writeError(w, r, http.StatusUnsupportedMediaType, errorCodeUnsupportedMediaType, "content type must be application/json")
+	return false
+}
+
+// newDecoder создаёт декодер JSON для тела запроса со значениями элементов. С WithJSONNumbers
+// числа в значениях декодируются как json.Number, а не float64.
+func (s *Server) newDecoder(r io.Reader) *json.Decoder {
+	decoder := json.NewDecoder(r)
+	if s.jsonNumbers {
+		decoder.UseNumber()
+	}
+	return decoder
+}
+
+// decodeJSON разбирает data в v так же, как json.Unmarshal, с учётом WithJSONNumbers.
+func (s *Server) decodeJSON(data []byte, v interface{}) error {
+	if !s.jsonNumbers {
+		return json.Unmarshal(data, v)
+	}
+	decoder := s.newDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after top-level value")
+	}
+	return nil
+}
+
+// compactJSON возвращает копию корректного JSON без незначащих пробелов.
+func compactJSON(data json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return append(json.RawMessage(nil), data...)
+	}
+	return buf.Bytes()
+}
+
+// requestValue возвращает значение элемента из полей value и value_b64.
+// Значение value_b64 декодируется из base64 и хранится как []byte.
+func requestValue(value interface{}, valueB64 *string) (interface{}, error) {
+	if valueB64 == nil {
+		return value, nil
+	}
+	if value != nil {
+		return nil, errValueConflict
+	}
+	data, err := base64.StdEncoding.DecodeString(*valueB64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value_b64: %w", err)
+	}
+	return data, nil
+}
+
+// reservedKeys — ключи, совпадающие с путями служебных маршрутов /api/lru (см. NewServer).
+// Такой элемент нельзя было бы прочитать или извлечь по /api/lru/{key}, поэтому запись
+// с ним отклоняется.
+var reservedKeys = map[string]bool{
+	"age":            true,
+	"capacity-check": true,
+	"changes":        true,
+	"export":         true,
+	"import":         true,
+	"least":          true,
+	"recent":         true,
+	"stats":          true,
+	"touch":          true,
+	"ttl":            true,
+}
+
+// validateKey проверяет, что ключ элемента не совпадает с зарезервированным (см. reservedKeys).
+func validateKey(key string) error {
+	if reservedKeys[key] {
+		return fmt.Errorf("key %q is reserved for an operational endpoint", key)
+	}
+	return nil
+}
+
+// validateTags проверяет имена тегов элемента: имя не может быть пустым или содержать «:»,
+// которым в фильтре tag отделяется значение тега.
+func validateTags(tags cache.Tags) error {
+	for name := range tags {
+		if name == "" || strings.Contains(name, ":") {
+			return fmt.Errorf("invalid tag name %q: must be non-empty and must not contain ':'", name)
+		}
+	}
+	return nil
+}
+
+// responseValue возвращает значение элемента для ответа: двоичные значения
+// передаются в поле value_b64 в кодировке base64, а value остаётся пустым.
+func responseValue(value interface{}) (interface{}, *string) {
+	data, ok := value.([]byte)
+	if !ok {
+		return value, nil
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return nil, &encoded
+}
+
+// keyParam извлекает ключ элемента из пути запроса.
+//
+// chi сопоставляет маршрут по r.URL.RawPath, если он задан (в пути есть закодированные
+// символы вроде %2F), и по декодированному r.URL.Path в остальных случаях,
+// поэтому значение декодируется только в первом случае.
+func keyParam(r *http.Request) (string, error) {
+	return decodeKey(r, chi.URLParam(r, "*"))
+}
+
+// decodeKey декодирует ключ, извлечённый из пути запроса (см. keyParam).
+func decodeKey(r *http.Request, key string) (string, error) {
+	if r.URL.RawPath == "" {
+		return key, nil
+	}
+	return url.PathUnescape(key)
+}
+
+// formatETag возвращает ETag элемента по его версии.
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// collectionETag возвращает ETag списка элементов — хеш FNV-1a закодированного тела ответа.
+// В отличие от версии кэша, хеш меняется и при истечении элементов, не увеличивающем версию.
+func collectionETag(body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	return `"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// etagMatches сообщает, совпадает ли etag с одним из значений заголовка If-None-Match.
+// Используется слабое сравнение: префикс W/ не учитывается; «*» совпадает с любым ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseETag извлекает версию элемента из ETag, сформированного formatETag.
+// Возвращает false, если значение не является таким ETag.
+func parseETag(etag string) (uint64, bool) {
+	etag = strings.TrimSpace(etag)
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(etag[1:len(etag)-1], 10, 64)
+	if err != nil || version == 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// formatTimestamp возвращает метку времени в секундах Unix и в формате RFC3339 (UTC).
+// Все метки времени в ответах API сериализуются через эту функцию парой полей
+// <name> и <name>_rfc3339. Нулевое время (бессрочный элемент) сериализуется как 0 и пустая строка.
+func formatTimestamp(t time.Time) (unix int64, rfc3339 string) {
+	if t.IsZero() {
+		return 0, ""
+	}
+	t = t.UTC()
+	return t.Unix(), t.Format(time.RFC3339)
+}
+
+// remainingSeconds возвращает количество секунд до истечения срока жизни элемента.
+// Если срок жизни уже истёк, возвращается 0; для бессрочного элемента возвращается -1.
+func remainingSeconds(expiresAt time.Time) int64 {
+	if expiresAt.IsZero() {
+		return -1
+	}
+	remaining := time.Until(expiresAt)
+	if remaining < 0 {
+		return 0
+	}
+	return int64(remaining / time.Second)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expandKeyTemplate строит ключ элемента по шаблону template, подставляя вместо
+// каждого заполнителя {field} значение поля field объекта fields. Поле должно
+// присутствовать и быть строкой, числом или логическим значением; «{{» и «}}»
+// обозначают литеральные фигурные скобки. Ошибка описывает первое нарушение.
+func expandKeyTemplate(template string, fields map[string]interface{}) (string, error) {
+	var key strings.Builder
+	for i := 0; i < len(template); i++ {
+		switch ch := template[i]; {
+		case ch == '{' && strings.HasPrefix(template[i:], "{{"):
+			key.WriteByte('{')
+			i++
+		case ch == '}' && strings.HasPrefix(template[i:], "}}"):
+			key.WriteByte('}')
+			i++
+		case ch == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return "", errors.New("unclosed placeholder")
+			}
+			name := template[i+1 : i+end]
+			if name == "" {
+				return "", errors.New("empty placeholder")
+			}
+			part, err := keyTemplateField(fields, name)
+			if err != nil {
+				return "", err
+			}
+			key.WriteString(part)
+			i += end
+		case ch == '}':
+			return "", errors.New("unmatched }")
+		default:
+			key.WriteByte(ch)
+		}
+	}
+	return key.String(), nil
+}
+
+// keyTemplateField возвращает строковое представление поля name для подстановки в ключ.
+func keyTemplateField(fields map[string]interface{}, name string) (string, error) {
+	value, ok := fields[name]
+	if !ok || value == nil {
+		return "", fmt.Errorf("value field %q is missing", name)
+	}
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			return "", fmt.Errorf("value field %q is empty", name)
+		}
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", fmt.Errorf("value field %q must be a string, number or boolean", name)
+	}
+}
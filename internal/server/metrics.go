@@ -0,0 +1,195 @@
+package server
+
+import (
+	"cache_service/internal/cache"
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unmatchedRoute — значение метки route для запросов, не сопоставленных ни одному маршруту.
+const unmatchedRoute = "unmatched"
+
+// metrics содержит реестр и метрики HTTP-сервера и кэша.
+type metrics struct {
+	registry        *prometheus.Registry     // Реестр метрик сервера
+	requestsTotal   *prometheus.CounterVec   // Число обработанных запросов
+	requestDuration *prometheus.HistogramVec // Время обработки запросов
+	requestErrors   *prometheus.CounterVec   // Число некорректных тел запросов и отказов кэша в записи
+}
+
+// newMetrics создаёт реестр с метриками HTTP-запросов, состояния кэша и среды выполнения Go
+// (горутины, сборка мусора, куча), чтобы поведение кэша можно было сопоставить
+// с состоянием процесса в одном опросе.
+//
+// HTTP-метрики помечаются шаблоном маршрута (например, /api/lru/*), а не путём запроса,
+// поэтому число временных рядов не растёт с числом ключей.
+func newMetrics(cacheInstance *cache.LRUCache) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration by route template and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		requestErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "request_errors_total",
+			Help: "Number of rejected write requests by kind: client_decode (malformed body) or cache_rejected (refused by the cache).",
+		}, []string{"kind"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestsTotal,
+		m.requestDuration,
+		m.requestErrors,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_entries",
+			Help: "Number of entries in the cache, including expired ones not yet removed.",
+		}, func() float64 { return float64(cacheInstance.Len()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_capacity",
+			Help: "Maximum number of entries in the cache.",
+		}, func() float64 { return float64(cacheInstance.Capacity()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_used_bytes",
+			Help: "Estimated size of cache entries in bytes.",
+		}, func() float64 { return float64(cacheInstance.UsedBytes()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "cache_evictions_total",
+			Help: "Number of entries evicted by capacity or byte budget.",
+		}, func() float64 { return float64(cacheInstance.EvictionStats().Evicted) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_oldest_entry_age_seconds",
+			Help: "Time since the least recently used live entry was last used.",
+		}, func() float64 {
+			stats, _ := cacheInstance.AgeStats(context.Background())
+			return stats.OldestAge.Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_hit_ratio",
+			Help: "Share of key lookups that found an entry over the last minute; 0 without lookups.",
+		}, func() float64 { return cacheInstance.HitRatio().Ratio }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "cache_soft_limit_reached",
+			Help: "Number of times the entry count exceeded the soft limit.",
+		}, func() float64 { return float64(cacheInstance.EvictionStats().SoftLimitReached) }),
+		&ttlCollector{cache: cacheInstance},
+		&fullnessCollector{cache: cacheInstance},
+	)
+	return m
+}
+
+// ttlEntriesDesc описывает метрику распределения элементов по оставшемуся времени жизни.
+var ttlEntriesDesc = prometheus.NewDesc(
+	"cache_entries_by_remaining_ttl",
+	"Number of live entries by remaining TTL bucket (lt_<bound>, longer or never).",
+	[]string{"bucket"}, nil,
+)
+
+// ttlCollector отдаёт распределение элементов по оставшемуся TTL, вычисляя его
+// одним обходом кэша на каждый опрос.
+type ttlCollector struct {
+	cache *cache.LRUCache // Экземпляр LRU-кэша
+}
+
+// Describe передаёт описание метрики распределения по TTL.
+func (c *ttlCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ttlEntriesDesc
+}
+
+// Collect вычисляет распределение элементов по TTL и передаёт его значения.
+func (c *ttlCollector) Collect(ch chan<- prometheus.Metric) {
+	hist, err := c.cache.TTLHistogram(context.Background())
+	if err != nil {
+		return
+	}
+	for i, bound := range cache.TTLBucketBounds {
+		ch <- prometheus.MustNewConstMetric(ttlEntriesDesc, prometheus.GaugeValue, float64(hist.Buckets[i]), ttlBucketLabel(bound))
+	}
+	ch <- prometheus.MustNewConstMetric(ttlEntriesDesc, prometheus.GaugeValue, float64(hist.Longer), "longer")
+	ch <- prometheus.MustNewConstMetric(ttlEntriesDesc, prometheus.GaugeValue, float64(hist.Never), "never")
+}
+
+// ttlBucketLabel возвращает значение метки bucket для границы интервала, например lt_1m.
+func ttlBucketLabel(bound time.Duration) string {
+	label := bound.String()
+	if strings.HasSuffix(label, "m0s") {
+		label = strings.TrimSuffix(label, "0s")
+	}
+	if strings.HasSuffix(label, "h0m") {
+		label = strings.TrimSuffix(label, "0m")
+	}
+	return "lt_" + label
+}
+
+// fullnessCrossingsDesc описывает метрику пересечений порогов заполненности кэша.
+var fullnessCrossingsDesc = prometheus.NewDesc(
+	"cache_fullness_threshold_crossings_total",
+	"Number of times the entry count rose past a fullness threshold (percent of capacity).",
+	[]string{"threshold"}, nil,
+)
+
+// fullnessCollector отдаёт число пересечений каждого порога заполненности кэша.
+type fullnessCollector struct {
+	cache *cache.LRUCache // Экземпляр LRU-кэша
+}
+
+// Describe передаёт описание метрики пересечений порогов заполненности.
+func (c *fullnessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fullnessCrossingsDesc
+}
+
+// Collect передаёт число пересечений каждого порога заполненности.
+func (c *fullnessCollector) Collect(ch chan<- prometheus.Metric) {
+	for threshold, crossings := range c.cache.FullnessCrossings() {
+		ch <- prometheus.MustNewConstMetric(fullnessCrossingsDesc, prometheus.CounterValue, float64(crossings), strconv.Itoa(threshold))
+	}
+}
+
+// handler возвращает обработчик, отдающий метрики в формате Prometheus.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// metricsMiddleware учитывает запрос в HTTP-метриках после его обработки.
+//
+// Шаблон маршрута доступен только после сопоставления запроса маршрутизатором,
+// поэтому он определяется после вызова следующего обработчика.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := routePattern(r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		s.metrics.requestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		s.metrics.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routePattern возвращает шаблон маршрута, сопоставленного запросу, или unmatchedRoute.
+// Доступен только после обработки запроса маршрутизатором.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return unmatchedRoute
+}
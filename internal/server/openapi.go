@@ -0,0 +1,26 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec — описание API сервиса в формате OpenAPI 3, поддерживается вручную.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPIHandler обрабатывает GET-запрос на получение описания API.
+//
+// Метод:
+// - GET /openapi.json
+//
+// Ответы:
+// - 200 OK: Документ OpenAPI 3 с описанием маршрутов /api/lru.
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openAPISpec); err != nil {
+		s.requestLog(r).Error("Failed to write OpenAPI document", "error", err)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// WithVerbosePanics включает подробности паники (значение и стек вызовов) в теле
+// ответа 500. Режим предназначен для разработки: по умолчанию клиент получает только
+// общее сообщение, а подробности записываются в журнал.
+func WithVerbosePanics(enabled bool) Option {
+	return func(s *Server) {
+		s.verbosePanics = enabled
+	}
+}
+
+// recoverMiddleware перехватывает панику обработчика, записывает её значение и стек
+// вызовов в журнал запроса на уровне ERROR и отвечает кодом 500 с телом
+// JSON, как остальные ошибки. Паника http.ErrAbortHandler пробрасывается дальше,
+// чтобы net/http прервал ответ.
+func (s *Server) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			stack := debug.Stack()
+			s.requestLog(r).Error("Panic while handling request",
+				"panic", fmt.Sprint(rec),
+				"stack", string(stack),
+			)
+
+			message := "internal server error"
+			if s.verbosePanics {
+				message = fmt.Sprintf("panic: %v\n%s", rec, stack)
+			}
+			s.writeError(w, r, http.StatusInternalServerError, errorCodeInternal, message)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
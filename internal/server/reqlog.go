@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+)
+
+// requestLoggerContextKey — ключ контекста запроса для логгера запроса.
+type requestLoggerContextKey struct{}
+
+// requestLoggerMiddleware создаёт логгер запроса с предустановленными полями method,
+// path, request_id и remote_ip и сохраняет его в контексте, чтобы все записи обработчиков
+// содержали одинаковый набор полей. Должен выполняться после middleware.RequestID
+// и clientIPMiddleware.
+func (s *Server) requestLoggerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestLoggerContextKey{}, s.newRequestLog(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestLog возвращает логгер запроса, созданный requestLoggerMiddleware. Если запрос
+// обрабатывается без него, логгер с теми же полями создаётся из самого запроса.
+func (s *Server) requestLog(r *http.Request) *slog.Logger {
+	if log, ok := r.Context().Value(requestLoggerContextKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return s.newRequestLog(r)
+}
+
+// newRequestLog создаёт логгер запроса r с полями method, path, request_id и remote_ip.
+func (s *Server) newRequestLog(r *http.Request) *slog.Logger {
+	return s.log.With(
+		"method", r.Method,
+		"path", r.URL.Path,
+		"request_id", middleware.GetReqID(r.Context()),
+		"remote_ip", clientIP(r.Context()),
+	)
+}
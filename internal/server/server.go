@@ -4,15 +4,117 @@ import (
 	"cache_service/internal/cache"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"time"
 )
 
 // Server содержит зависимости для работы HTTP-сервера.
 type Server struct {
-	cache *cache.LRUCache // Экземпляр LRU-кэша
-	log   *slog.Logger    // Логгер для записи сообщений
+	cache           *cache.LRUCache // Экземпляр LRU-кэша
+	log             *slog.Logger    // Логгер для записи сообщений
+	maxConcurrent   int             // Максимальное число одновременно обрабатываемых запросов
+	cacheHeaders    bool            // Добавлять заголовки X-Cache-Size и X-Cache-Capacity
+	cancelledStatus int             // Код ответа на запрос, отменённый клиентом
+	metrics         *metrics        // Метрики сервера и кэша
+	tenantHeader    string          // Заголовок с идентификатором арендатора (пусто — без разделения)
+	trustedProxies  []netip.Prefix  // Доверенные прокси, которым разрешено передавать адрес клиента
+	shutdown        <-chan struct{} // Закрывается при начале остановки сервиса (nil — не задан)
+	slowThreshold   time.Duration   // Время обработки, начиная с которого запрос логируется как медленный (0 — отключено)
+	accessLog       *accessLogger   // Журнал доступа в Combined Log Format (nil — структурированные логи)
+	maxListEntries  int             // Максимальное число элементов в ответе GET /api/lru (0 — без ограничения)
+	admin           chi.Router      // Маршрутизатор служебных маршрутов на отдельном порту (nil — основной)
+	tracer          trace.Tracer    // Трассировщик OpenTelemetry (по умолчанию не создаёт спанов)
+	rawJSON         bool            // Хранить значения как исходный JSON (см. WithRawJSONValues)
+	jsonNumbers     bool            // Декодировать числа в значениях как json.Number (см. WithJSONNumbers)
+	maxWait         time.Duration   // Максимальное время ожидания ключа в GET /api/lru/{key}?wait (0 — без ожидания)
+	verbosePanics   bool            // Включать значение и стек паники в ответ 500 (см. WithVerbosePanics)
+}
+
+// Option задаёт дополнительный параметр HTTP-сервера.
+type Option func(*Server)
+
+// WithMaxConcurrentRequests ограничивает число одновременно обрабатываемых запросов.
+// Запросы сверх лимита не ставятся в очередь и получают ответ 503 Service Unavailable.
+// Значение 0 означает отсутствие ограничения.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(s *Server) {
+		s.maxConcurrent = n
+	}
+}
+
+// WithCacheHeaders включает заголовки X-Cache-Size и X-Cache-Capacity в ответах /api/lru.
+func WithCacheHeaders(enabled bool) Option {
+	return func(s *Server) {
+		s.cacheHeaders = enabled
+	}
+}
+
+// WithCancelledStatus задаёт код ответа на запросы, отменённые клиентом.
+// По умолчанию используется StatusClientClosedRequest (499); альтернатива —
+// http.StatusRequestTimeout (408) для клиентов, не знающих нестандартных кодов.
+func WithCancelledStatus(code int) Option {
+	return func(s *Server) {
+		s.cancelledStatus = code
+	}
+}
+
+// WithMaxResponseEntries ограничивает число элементов в ответе GET /api/lru без параметров key.
+// Сверх лимита элементы отбрасываются, а ответ помечается флагом truncated, что защищает
+// память сервера и клиента при большом кэше. Полный список следует получать через
+// GET /api/lru/export. Значение 0 означает отсутствие ограничения.
+func WithMaxResponseEntries(n int) Option {
+	return func(s *Server) {
+		s.maxListEntries = n
+	}
+}
+
+// WithRawJSONValues включает хранение значений, записанных через POST /api/lru, в виде
+// исходного JSON (json.RawMessage) вместо разобранного interface{}. Значение возвращается
+// без повторного кодирования, поэтому порядок ключей вложенных объектов и запись чисел
+// сохраняются; удаляются только незначащие пробелы.
+func WithRawJSONValues(enabled bool) Option {
+	return func(s *Server) {
+		s.rawJSON = enabled
+	}
+}
+
+// WithJSONNumbers включает декодирование чисел в значениях элементов как json.Number
+// вместо float64. Целые числа, в том числе больше 2^53, хранятся и возвращаются без потери
+// точности и в исходной записи; числа с дробной частью также возвращаются как записаны.
+// Действует на POST /api/lru, PUT /api/lru и импорт.
+func WithJSONNumbers(enabled bool) Option {
+	return func(s *Server) {
+		s.jsonNumbers = enabled
+	}
+}
+
+// defaultMaxWait — максимальное время ожидания ключа в GET /api/lru/{key}?wait по умолчанию.
+// Оно меньше таймаута записи HTTP-сервера по умолчанию, чтобы ответ успел дойти до клиента.
+const defaultMaxWait = 5 * time.Second
+
+// WithMaxWait ограничивает время ожидания ключа в GET /api/lru/{key}?wait: больший
+// запрошенный интервал сокращается до max. Значение должно быть меньше таймаута записи
+// HTTP-сервера. Значение 0 отключает ожидание, и параметр wait игнорируется.
+// По умолчанию используется defaultMaxWait.
+func WithMaxWait(max time.Duration) Option {
+	return func(s *Server) {
+		s.maxWait = max
+	}
+}
+
+// WithSlowRequestThreshold задаёт время обработки, начиная с которого запрос
+// логируется на уровне WARN с маршрутом и длительностью. Остальные запросы
+// по-прежнему логируются на уровне DEBUG. Значение 0 отключает предупреждения.
+func WithSlowRequestThreshold(threshold time.Duration) Option {
+	return func(s *Server) {
+		s.slowThreshold = threshold
+	}
 }
 
 // NewServer создаёт HTTP-сервер с поддержкой маршрутов для работы с кэшем.
@@ -20,48 +122,215 @@ type Server struct {
 // Параметры:
 // - cacheInstance: экземпляр LRU-кэша.
 // - log: экземпляр логгера.
-func NewServer(cacheInstance *cache.LRUCache, log *slog.Logger) *chi.Mux {
+// - opts: дополнительные параметры сервера.
+func NewServer(cacheInstance *cache.LRUCache, log *slog.Logger, opts ...Option) *chi.Mux {
 	server := &Server{
-		cache: cacheInstance,
-		log:   log,
+		cache:           cacheInstance,
+		log:             log,
+		cancelledStatus: StatusClientClosedRequest,
+		metrics:         newMetrics(cacheInstance),
+		tracer:          defaultTracer(),
+		maxWait:         defaultMaxWait,
+	}
+	for _, opt := range opts {
+		opt(server)
 	}
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(server.loggingMiddleware) // Логирование входящих запросов
-	r.Use(middleware.Recoverer)     // Перехват паник
-	r.Use(middleware.RequestID)     // Генерация Request ID
+	r.Use(server.tracingMiddleware)       // Серверные спаны OpenTelemetry (без WithTracerProvider не создаются)
+	r.Use(server.clientIPMiddleware)      // Определение IP-адреса клиента
+	r.Use(middleware.RequestID)           // Генерация Request ID
+	r.Use(server.requestLoggerMiddleware) // Логгер запроса с method, path, request_id и remote_ip
+	r.Use(server.loggingMiddleware)       // Логирование входящих запросов
+	r.Use(server.metricsMiddleware)       // Метрики запросов по шаблонам маршрутов
+	r.Use(server.recoverMiddleware)       // Перехват паник с ответом 500 в формате JSON
+	if server.maxConcurrent > 0 {
+		r.Use(server.concurrencyLimitMiddleware) // Ограничение числа одновременных запросов
+	}
 
 	//Маршруты
+	r.NotFound(server.NotFoundHandler)
+	r.Get("/openapi.json", server.OpenAPIHandler)
+	r.Get("/version", server.VersionHandler)
+	if server.admin != nil {
+		server.admin.NotFound(server.NotFoundHandler)
+		server.mountAdmin(server.admin, true)
+	} else {
+		server.mountAdmin(r, false)
+	}
 	r.Route("/api/lru", func(r chi.Router) {
+		r.Use(server.drainMiddleware) // Отклонение запросов во время остановки
+		if server.cacheHeaders {
+			r.Use(server.cacheHeadersMiddleware) // Заголовки с заполненностью кэша
+		}
+		if server.tenantHeader != "" {
+			r.Use(server.tenantMiddleware) // Разделение ключей по арендаторам
+		}
 		r.Post("/", server.CreateLRUHandler)
-		r.Get("/{key}", server.GetLRUHandler)
+		// Служебные маршруты перекрывают /{key}, поэтому их имена зарезервированы (см. reservedKeys)
+		r.Get("/capacity-check", server.CapacityCheckHandler)
+		r.Get("/export", server.ExportLRUHandler)
+		r.Get("/recent", server.RecentLRUHandler)
+		r.Get("/least", server.LeastLRUHandler)
+		r.Get("/age", server.AgeLRUHandler)
+		r.Get("/stats", server.StatsLRUHandler)
+		r.Get("/changes", server.ChangesLRUHandler)
+		r.Post("/import", server.ImportLRUHandler)
+		r.Post("/touch", server.TouchLRUHandler)
+		r.Post("/ttl", server.TTLLRUHandler)
+		r.Post("/*", server.PopLRUHandler)
+		r.Get("/*", server.GetLRUHandler)
 		r.Get("/", server.GetAllLRUHandler)
-		r.Delete("/{key}", server.DeleteLRUHandler)
+		r.Put("/", server.ReplaceAllLRUHandler)
+		r.Delete("/*", server.DeleteLRUHandler)
 		r.Delete("/", server.DeleteAllLRUHandler)
 	})
 
 	return r
 }
 
+// Timeouts содержит таймауты HTTP-сервера.
+type Timeouts struct {
+	ReadHeader time.Duration // Таймаут чтения заголовков запроса
+	Read       time.Duration // Таймаут чтения запроса целиком
+	Write      time.Duration // Таймаут записи ответа
+	Idle       time.Duration // Таймаут простоя keep-alive соединения
+}
+
+// NewHTTPServer создаёт HTTP-сервер с заданными таймаутами.
+//
+// Явные таймауты защищают сервер от медленных клиентов, удерживающих соединения
+// (slowloris). Нулевое значение таймаута означает его отсутствие. Сервер учитывает
+// активные запросы, чтобы сообщить о прерванных при остановке (см. HTTPServer.Drain).
+//
+// Параметры:
+// - addr: адрес и порт для прослушивания.
+// - handler: обработчик запросов.
+// - timeouts: таймауты сервера.
+func NewHTTPServer(addr string, handler http.Handler, timeouts Timeouts) *HTTPServer {
+	srv := &HTTPServer{}
+	srv.Server = &http.Server{
+		Addr:              addr,
+		Handler:           srv.track(handler),
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+	return srv
+}
+
+// NewH2CHandler оборачивает обработчик для поддержки HTTP/2 без TLS (h2c).
+//
+// Сервер принимает HTTP/2-соединения с предварительным знанием протокола (prior knowledge)
+// и запросы на переход через Upgrade: h2c, продолжая обслуживать HTTP/1.1. Предназначен
+// для доверенных сетей, где клиенты мультиплексируют запросы в долгоживущем соединении.
+func NewH2CHandler(handler http.Handler) http.Handler {
+	return h2c.NewHandler(handler, &http2.Server{})
+}
+
 // loggingMiddleware логирует все входящие HTTP-запросы.
 //
 // Логи включают:
 // - Метод запроса.
 // - Путь запроса.
+// - IP-адрес клиента (с учётом доверенных прокси).
 // - Время обработки.
 //
-// Логи пишутся на уровне DEBUG.
+// Логи пишутся на уровне DEBUG, а запросы дольше порога WithSlowRequestThreshold —
+// на уровне WARN с шаблоном маршрута. При WithCombinedAccessLog вместо записи DEBUG
+// пишется строка журнала доступа.
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
+		if s.accessLog != nil {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			s.accessLog.log(r, ww, start)
+		} else {
+			next.ServeHTTP(w, r)
+		}
 		duration := time.Since(start)
 
-		s.log.Debug("Request completed",
-			"method", r.Method,
-			"path", r.URL.Path,
+		if s.slowThreshold > 0 && duration >= s.slowThreshold {
+			s.requestLog(r).Warn("Slow request",
+				"route", routePattern(r),
+				"duration", duration.String(),
+				"threshold", s.slowThreshold.String(),
+			)
+			return
+		}
+		if s.accessLog != nil {
+			return
+		}
+		s.requestLog(r).Debug("Request completed",
 			"duration", duration.String(),
 		)
 	})
 }
+
+// concurrencyLimitMiddleware ограничивает число одновременно обрабатываемых запросов.
+//
+// Если лимит исчерпан, запрос отклоняется с кодом 503 и заголовком Retry-After.
+func (s *Server) concurrencyLimitMiddleware(next http.Handler) http.Handler {
+	sem := make(chan struct{}, s.maxConcurrent)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		default:
+			s.requestLog(r).Warn("Too many concurrent requests", "limit", s.maxConcurrent)
+			w.Header().Set("Retry-After", "1")
+			s.writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "too many concurrent requests")
+		}
+	})
+}
+
+// cacheHeadersMiddleware добавляет в ответ заголовки с заполненностью кэша:
+// - X-Cache-Size: текущее число элементов.
+// - X-Cache-Capacity: ёмкость кэша.
+//
+// Значения вычисляются непосредственно перед отправкой заголовков и учитывают
+// изменения, внесённые обработчиком запроса.
+func (s *Server) cacheHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheHeadersWriter{ResponseWriter: w, cache: s.cache}, r)
+	})
+}
+
+// cacheHeadersWriter добавляет заголовки с заполненностью кэша перед записью ответа.
+type cacheHeadersWriter struct {
+	http.ResponseWriter
+	cache       *cache.LRUCache // Кэш, заполненность которого сообщается
+	wroteHeader bool            // Заголовки уже отправлены
+}
+
+// WriteHeader добавляет заголовки с заполненностью кэша и отправляет код ответа.
+func (w *cacheHeadersWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("X-Cache-Size", strconv.Itoa(w.cache.Len()))
+		w.Header().Set("X-Cache-Capacity", strconv.Itoa(w.cache.Capacity()))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write записывает тело ответа, при необходимости отправляя заголовки.
+func (w *cacheHeadersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush передаёт буферизованные данные клиенту, если это поддерживается.
+func (w *cacheHeadersWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -4,10 +4,30 @@ import (
 	"bytes"
 	"cache_service/internal/cache"
 	"cache_service/internal/logger"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestServer_DeleteAll(t *testing.T) {
@@ -40,6 +60,64 @@ func TestServer_DeleteAll(t *testing.T) {
 	if w.Code != http.StatusNotFound {
 		t.Errorf("expected status 404, got %d", w.Code)
 	}
+
+	// Полная очистка записывается в журнал с причиной удаления
+	var logs bytes.Buffer
+	logged := NewServer(cache.NewLRUCache(10, 0), slog.New(slog.NewTextHandler(&logs, nil)))
+	logged.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/lru", nil))
+	if !strings.Contains(logs.String(), `msg="All keys successfully deleted from cache"`) || !strings.Contains(logs.String(), "reason=deleted") {
+		t.Errorf("expected delete-all log line with reason, got %q", logs.String())
+	}
+
+	// Очистка пустого кэша тоже успешна
+	req = httptest.NewRequest(http.MethodDelete, "/api/lru", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("expected status 204 with empty body, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServer_GetAllTruncated(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, 0)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithMaxResponseEntries(2))
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		_ = cacheInstance.Put(nil, key, "value", 0)
+	}
+
+	// Ответ без постраничной выборки ограничен и помечен флагом truncated
+	req := httptest.NewRequest(http.MethodGet, "/api/lru", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Keys      []string      `json:"keys"`
+		Values    []interface{} `json:"values"`
+		Truncated bool          `json:"truncated"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Keys) != 2 || len(response.Values) != 2 || !response.Truncated {
+		t.Errorf("expected 2 entries and truncated flag, got %+v", response)
+	}
+
+	// В пределах лимита флаг не выставляется
+	_, _ = cacheInstance.Evict(context.Background(), "key3")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru", nil))
+	response.Truncated = true
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Keys) != 2 || response.Truncated {
+		t.Errorf("expected complete listing, got %+v", response)
+	}
 }
 
 func TestServer_InvalidPostRequest(t *testing.T) {
@@ -49,6 +127,7 @@ func TestServer_InvalidPostRequest(t *testing.T) {
 
 	// Пустое тело
 	req := httptest.NewRequest(http.MethodPost, "/api/lru", nil)
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusBadRequest {
@@ -95,3 +174,2794 @@ func TestServer_GetAll(t *testing.T) {
 		t.Errorf("expected 2 keys and values, got %d and %d", len(response.Keys), len(response.Values))
 	}
 }
+
+func TestServer_GetRemainingSeconds(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 30*time.Second)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		ExpiresAt        int64 `json:"expires_at"`
+		RemainingSeconds int64 `json:"remaining_seconds"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.RemainingSeconds <= 0 {
+		t.Errorf("expected positive remaining_seconds, got %d", response.RemainingSeconds)
+	}
+	if response.RemainingSeconds < 28 || response.RemainingSeconds > 30 {
+		t.Errorf("expected remaining_seconds close to 30, got %d", response.RemainingSeconds)
+	}
+	if response.ExpiresAt == 0 {
+		t.Error("expected expires_at to be present")
+	}
+}
+
+func TestServer_GetTimestampFormats(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		ExpiresAt        int64  `json:"expires_at"`
+		ExpiresAtRFC3339 string `json:"expires_at_rfc3339"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	parsed, err := time.Parse(time.RFC3339, response.ExpiresAtRFC3339)
+	if err != nil {
+		t.Fatalf("failed to parse expires_at_rfc3339 %q: %v", response.ExpiresAtRFC3339, err)
+	}
+	if parsed.Unix() != response.ExpiresAt {
+		t.Errorf("expected timestamps to agree, got %d and %d", response.ExpiresAt, parsed.Unix())
+	}
+}
+
+func TestServer_ConcurrencyLimit(t *testing.T) {
+	var logs bytes.Buffer
+	s := &Server{log: slog.New(slog.NewTextHandler(&logs, nil)), maxConcurrent: 2}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	h := middleware.RequestID(s.concurrencyLimitMiddleware(slow))
+
+	const requests = 5
+	codes := make(chan *httptest.ResponseRecorder, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru", nil))
+			codes <- w
+		}()
+	}
+
+	// Дожидаемся, пока лимит будет занят
+	<-started
+	<-started
+
+	for i := 0; i < requests-2; i++ {
+		w := <-codes
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("expected Retry-After header")
+		}
+	}
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if w := <-codes; w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	}
+
+	// Отклонённые запросы записываются в журнал запроса
+	if got := strings.Count(logs.String(), `msg="Too many concurrent requests"`); got != requests-2 {
+		t.Errorf("expected %d rejection log lines, got %q", requests-2, logs.String())
+	}
+	if !strings.Contains(logs.String(), "request_id=") || !strings.Contains(logs.String(), "path=/api/lru") {
+		t.Errorf("expected request fields in rejection log, got %q", logs.String())
+	}
+}
+
+func TestServer_CreateLocationHeader(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	reqBody := []byte(`{"key":"a b/c?d","value":"value1"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+
+	if location := w.Header().Get("Location"); location != "/api/lru/a%20b%2Fc%3Fd" {
+		t.Errorf("expected escaped Location header, got %q", location)
+	}
+}
+
+func TestServer_SpecialCharacterKeys(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	keys := []string{"a/b/c", "with space", "100%", "mixed/100% done?"}
+	for _, key := range keys {
+		body, _ := json.Marshal(map[string]string{"key": key, "value": "value-" + key})
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("key %q: expected status 201, got %d", key, w.Code)
+		}
+
+		// Получаем элемент по закодированному ключу
+		req = httptest.NewRequest(http.MethodGet, "/api/lru/"+url.PathEscape(key), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("key %q: expected status 200, got %d", key, w.Code)
+		}
+		var response struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Key != key || response.Value != "value-"+key {
+			t.Errorf("key %q: unexpected response %+v", key, response)
+		}
+
+		// Удаляем элемент
+		req = httptest.NewRequest(http.MethodDelete, "/api/lru/"+url.PathEscape(key), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("key %q: expected status 204, got %d", key, w.Code)
+		}
+	}
+
+	// Ключ со слешами доступен и без кодирования
+	_ = cacheInstance.Put(context.Background(), "x/y", "value", 0)
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/x/y", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for unescaped slash key, got %d", w.Code)
+	}
+}
+
+func TestNewHTTPServer_Timeouts(t *testing.T) {
+	timeouts := Timeouts{
+		ReadHeader: 1 * time.Second,
+		Read:       2 * time.Second,
+		Write:      3 * time.Second,
+		Idle:       4 * time.Second,
+	}
+	srv := NewHTTPServer("localhost:8080", http.NotFoundHandler(), timeouts)
+
+	if srv.Addr != "localhost:8080" {
+		t.Errorf("expected addr localhost:8080, got %s", srv.Addr)
+	}
+	if srv.ReadHeaderTimeout != timeouts.ReadHeader || srv.ReadTimeout != timeouts.Read ||
+		srv.WriteTimeout != timeouts.Write || srv.IdleTimeout != timeouts.Idle {
+		t.Errorf("unexpected timeouts: header=%s read=%s write=%s idle=%s",
+			srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestHTTPServer_Drain(t *testing.T) {
+	const grace = 200 * time.Millisecond
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	// Таймаут записи заметно больше grace: остановка не должна от него зависеть
+	srv := NewHTTPServer(ln.Addr().String(), slow, Timeouts{Write: time.Minute})
+	go func() { _ = srv.Serve(ln) }()
+
+	requestDone := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestDone <- err
+	}()
+	<-started
+
+	// Медленный запрос не завершается, поэтому остановка ждёт grace и закрывает соединение
+	var logs bytes.Buffer
+	start := time.Now()
+	if err := srv.Drain(grace, slog.New(slog.NewTextHandler(&logs, nil))); err != nil {
+		t.Fatalf("unexpected drain error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < grace || elapsed > 2*time.Second {
+		t.Errorf("expected drain to wait about %s, took %s", grace, elapsed)
+	}
+	if !strings.Contains(logs.String(), "in_flight=1") {
+		t.Errorf("expected in-flight request count to be logged, got %q", logs.String())
+	}
+	select {
+	case err := <-requestDone:
+		if err == nil {
+			t.Error("expected the in-flight request to be aborted")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the in-flight request to be aborted after the grace period")
+	}
+
+	// Новые соединения не принимаются
+	if conn, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("expected new connections to be refused after drain")
+	}
+}
+
+func TestHTTPServer_DrainIdle(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := NewHTTPServer(ln.Addr().String(), http.NotFoundHandler(), Timeouts{})
+	go func() { _ = srv.Serve(ln) }()
+
+	// Без активных запросов остановка не ждёт grace и ничего не сообщает
+	var logs bytes.Buffer
+	start := time.Now()
+	if err := srv.Drain(time.Minute, slog.New(slog.NewTextHandler(&logs, nil))); err != nil {
+		t.Fatalf("unexpected drain error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected idle drain to return immediately, took %s", elapsed)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no warning for idle drain, got %q", logs.String())
+	}
+}
+
+func TestServer_CreateVsUpdateStatus(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Первая запись создаёт элемент
+	if w := post(`{"key":"key1","value":"value1"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected status 201 on create, got %d", w.Code)
+	}
+
+	// Повторная запись обновляет элемент
+	w := post(`{"key":"key1","value":"value2"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 on update, got %d", w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "" {
+		t.Errorf("expected no Location header on update, got %q", location)
+	}
+
+	val, _, _ := cacheInstance.Get(context.Background(), "key1")
+	if val != "value2" {
+		t.Errorf("expected value2, got %v", val)
+	}
+}
+
+func TestServer_CapacityCheck(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+	_ = cacheInstance.Put(context.Background(), "key2", "value2", 0)
+	_ = cacheInstance.Put(context.Background(), "key3", "value3", 0)
+
+	tests := []struct {
+		query   string
+		fits    bool
+		evicted int
+	}{
+		{query: "count=5&avg_bytes=100", fits: true, evicted: 0},
+		{query: "count=20&avg_bytes=100", fits: false, evicted: 13},
+		// Частично заполненный кэш: новые элементы помещаются, только вытеснив текущие
+		{query: "count=8&avg_bytes=100", fits: false, evicted: 1},
+		{query: "count=7&avg_bytes=100", fits: true, evicted: 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/lru/capacity-check?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.query, w.Code)
+		}
+
+		var response struct {
+			Fits           bool  `json:"fits"`
+			Evicted        int   `json:"evicted"`
+			EstimatedBytes int64 `json:"estimated_bytes"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Fits != tt.fits || response.Evicted != tt.evicted {
+			t.Errorf("%s: expected fits=%v evicted=%d, got fits=%v evicted=%d",
+				tt.query, tt.fits, tt.evicted, response.Fits, response.Evicted)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/capacity-check?count=-1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for negative count, got %d", w.Code)
+	}
+
+	// Бюджет памяти учитывает уже занятые байты
+	bounded := cache.NewLRUCache(100, time.Minute, cache.WithMaxBytes(1000))
+	for i := 0; i < 8; i++ {
+		_ = bounded.Put(context.Background(), fmt.Sprintf("key%d", i), strings.Repeat("x", 96), 0)
+	}
+	used := bounded.UsedBytes()
+	req = httptest.NewRequest(http.MethodGet, "/api/lru/capacity-check?count=3&avg_bytes=100", nil)
+	w = httptest.NewRecorder()
+	NewServer(bounded, log).ServeHTTP(w, req)
+	var response struct {
+		Fits         bool  `json:"fits"`
+		Evicted      int   `json:"evicted"`
+		CurrentBytes int64 `json:"current_bytes"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.CurrentBytes != used || response.Fits || response.Evicted != 1 {
+		t.Errorf("expected current_bytes=%d fits=false evicted=1, got %+v", used, response)
+	}
+}
+
+func TestServer_CreateHugeTTL(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	reqBody := []byte(`{"key":"key1","value":"value1","ttl_seconds":9223372036854775807}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+
+	if _, _, err := cacheInstance.Get(context.Background(), "key1"); err == nil {
+		t.Error("expected key not to be stored")
+	}
+
+	// Максимально допустимый TTL принимается
+	reqBody = []byte(fmt.Sprintf(`{"key":"key1","value":"value1","ttl_seconds":%d}`, maxTTLSeconds))
+	req = httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", w.Code)
+	}
+	_, expiresAt, err := cacheInstance.Get(context.Background(), "key1")
+	if err != nil || !expiresAt.After(time.Now()) {
+		t.Errorf("expected expiry in the future, got %v (err %v)", expiresAt, err)
+	}
+}
+
+func TestServer_Export(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	expected := map[string]interface{}{"key1": "value1", "key2": 2.5, "key3": true}
+	for key, value := range expected {
+		_ = cacheInstance.Put(context.Background(), key, value, 0)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/export", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected NDJSON content type, got %q", ct)
+	}
+
+	decoder := json.NewDecoder(w.Body)
+	exported := make(map[string]interface{})
+	for decoder.More() {
+		var entry exportEntry
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("failed to decode NDJSON line: %v", err)
+		}
+		if entry.ExpiresAt <= time.Now().Unix() {
+			t.Errorf("key %s: expected expiry in the future, got %d", entry.Key, entry.ExpiresAt)
+		}
+		exported[entry.Key] = entry.Value
+	}
+
+	if len(exported) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(exported))
+	}
+	for key, value := range expected {
+		if exported[key] != value {
+			t.Errorf("key %s: expected %v, got %v", key, value, exported[key])
+		}
+	}
+}
+
+func TestServer_Import(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	body := fmt.Sprintf(`{"key":"key1","value":"value1","expires_at":%d}
+{"key":"key2","value":42}
+{"key":"expired","value":"old","expires_at":%d}
+not json
+{"key":"","value":"empty key"}
+`, future, past)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/lru/import", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var summary struct {
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+		Failed   int `json:"failed"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if summary.Imported != 2 || summary.Skipped != 1 || summary.Failed != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	val, expiresAt, err := cacheInstance.Get(context.Background(), "key1")
+	if err != nil || val != "value1" {
+		t.Errorf("expected key1 to be imported, got %v (err %v)", val, err)
+	}
+	if diff := expiresAt.Unix() - future; diff < -1 || diff > 1 {
+		t.Errorf("expected key1 to keep its expiry %d, got %d", future, expiresAt.Unix())
+	}
+	if _, _, err := cacheInstance.Get(context.Background(), "key2"); err != nil {
+		t.Errorf("expected key2 to be imported, got %v", err)
+	}
+	if _, _, err := cacheInstance.Get(context.Background(), "expired"); err == nil {
+		t.Error("expected expired entry to be skipped")
+	}
+}
+
+func TestServer_CacheHeaders(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithCacheHeaders(true))
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		body := []byte(`{"key":"` + key + `","value":"value"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if size := w.Header().Get("X-Cache-Size"); size != "3" {
+		t.Errorf("expected X-Cache-Size 3, got %q", size)
+	}
+	if capacity := w.Header().Get("X-Cache-Capacity"); capacity != "10" {
+		t.Errorf("expected X-Cache-Capacity 10, got %q", capacity)
+	}
+
+	// Без включённой опции заголовки не добавляются
+	r = NewServer(cacheInstance, log)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil))
+	if size := w.Header().Get("X-Cache-Size"); size != "" {
+		t.Errorf("expected no X-Cache-Size header, got %q", size)
+	}
+}
+
+func TestServer_CreateIfMatch(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"key":"key1","value":"value1"}`, "")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header on create")
+	}
+
+	// GET возвращает тот же ETag
+	getW := httptest.NewRecorder()
+	r.ServeHTTP(getW, httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil))
+	if got := getW.Header().Get("ETag"); got != etag {
+		t.Errorf("expected GET ETag %s, got %s", etag, got)
+	}
+
+	// Совпадающий ETag
+	w = post(`{"key":"key1","value":"value2"}`, etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for matching ETag, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("expected new ETag after update")
+	}
+
+	// Устаревший ETag
+	if w = post(`{"key":"key1","value":"value3"}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 for stale ETag, got %d", w.Code)
+	}
+	val, _, _ := cacheInstance.Get(context.Background(), "key1")
+	if val != "value2" {
+		t.Errorf("expected value2 to be kept, got %v", val)
+	}
+
+	// Отсутствующий ключ
+	if w = post(`{"key":"missing","value":"value"}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 for missing key, got %d", w.Code)
+	}
+	if _, _, err := cacheInstance.Get(context.Background(), "missing"); err == nil {
+		t.Error("expected missing key not to be created")
+	}
+}
+
+func TestServer_CreateExplicitZeroTTL(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d", w.Code)
+		}
+	}
+
+	// ttl_seconds не указан: используется TTL по умолчанию
+	post(`{"key":"omitted","value":"value"}`)
+	_, expiresAt, err := cacheInstance.Get(context.Background(), "omitted")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if remaining := time.Until(expiresAt); remaining < 59*time.Second {
+		t.Errorf("expected default TTL (~1m), got %s", remaining)
+	}
+
+	// Положительный TTL
+	post(`{"key":"positive","value":"value","ttl_seconds":5}`)
+	_, expiresAt, err = cacheInstance.Get(context.Background(), "positive")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if remaining := time.Until(expiresAt); remaining > 5*time.Second || remaining < 4*time.Second {
+		t.Errorf("expected TTL of 5s, got %s", remaining)
+	}
+
+	// Явный нулевой TTL: элемент истекает сразу
+	post(`{"key":"zero","value":"value","ttl_seconds":0}`)
+	time.Sleep(time.Millisecond)
+	if _, _, err := cacheInstance.Get(context.Background(), "zero"); err == nil {
+		t.Error("expected explicitly zero TTL entry to expire immediately")
+	}
+}
+
+func TestServer_CreateTTLHeader(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body, ttlHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Cache-TTL", ttlHeader)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Только заголовок
+	if w := post(`{"key":"header","value":"value"}`, "10m"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	_, expiresAt, _ := cacheInstance.Get(context.Background(), "header")
+	if remaining := time.Until(expiresAt); remaining < 9*time.Minute || remaining > 10*time.Minute {
+		t.Errorf("expected TTL from header (~10m), got %s", remaining)
+	}
+
+	// Поле тела имеет приоритет над заголовком
+	if w := post(`{"key":"body","value":"value","ttl_seconds":30}`, "10m"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	_, expiresAt, _ = cacheInstance.Get(context.Background(), "body")
+	if remaining := time.Until(expiresAt); remaining > 30*time.Second {
+		t.Errorf("expected TTL from body (30s), got %s", remaining)
+	}
+
+	// Некорректная длительность
+	if w := post(`{"key":"invalid","value":"value"}`, "soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid header, got %d", w.Code)
+	}
+}
+
+func TestServer_OpenAPI(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeJSON {
+		t.Errorf("expected %s, got %q", contentTypeJSON, ct)
+	}
+
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("expected OpenAPI 3 document, got version %q", spec.OpenAPI)
+	}
+	for _, path := range []string{"/api/lru", "/api/lru/{key}", "/api/lru/capacity-check", "/api/lru/export", "/api/lru/import"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("expected path %s in document", path)
+		}
+	}
+}
+
+func TestServer_H2C(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+
+	ts := httptest.NewServer(NewH2CHandler(NewServer(cacheInstance, log)))
+	defer ts.Close()
+
+	// Клиент HTTP/2 с предварительным знанием протокола поверх открытого TCP
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+
+	resp, err := client.Get(ts.URL + "/api/lru/key1")
+	if err != nil {
+		t.Fatalf("h2c request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, got %s", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_CancelledRequestStatus(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected int
+	}{
+		{"default", nil, StatusClientClosedRequest},
+		{"configured", []Option{WithCancelledStatus(http.StatusRequestTimeout)}, http.StatusRequestTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewServer(cacheInstance, log, tt.opts...)
+			req := httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestServer_WriteCacheError(t *testing.T) {
+	s := &Server{log: logger.NewLogger("ERROR"), cancelledStatus: StatusClientClosedRequest}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	closed := cache.NewLRUCache(10, time.Minute)
+	_ = closed.Close()
+	_, _, closedErr := closed.Get(context.Background(), "key")
+	_, _, missingErr := cache.NewLRUCache(10, time.Minute).Get(context.Background(), "key")
+
+	// Ошибку отсутствия элемента отличают от отмены запроса и закрытого кэша
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected int
+	}{
+		{"not found", context.Background(), missingErr, http.StatusNotFound},
+		{"closed", context.Background(), closedErr, http.StatusInternalServerError},
+		{"cancelled", ctx, context.Canceled, StatusClientClosedRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/lru/key", nil).WithContext(tt.ctx)
+		s.writeCacheError(w, req, tt.err, "Failed to get key from cache")
+		if w.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, w.Code)
+		}
+	}
+}
+
+func TestServer_MetricsRouteLabels(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	keys := []string{"alpha", "beta", "gamma/delta"}
+	for _, key := range keys {
+		_ = cacheInstance.Put(context.Background(), key, "value", 0)
+		req := httptest.NewRequest(http.MethodGet, "/api/lru/"+key, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	// Все запросы по ключам учитываются в одном временном ряду шаблона маршрута
+	var series []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.HasPrefix(line, "http_requests_total{") && strings.Contains(line, `method="GET"`) &&
+			!strings.Contains(line, `route="/metrics"`) {
+			series = append(series, line)
+		}
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected one series per route template, got %v", series)
+	}
+	if !strings.Contains(series[0], `route="/api/lru/*"`) || !strings.HasSuffix(series[0], " 3") {
+		t.Errorf("unexpected series %q", series[0])
+	}
+	for _, key := range keys {
+		if strings.Contains(w.Body.String(), key) {
+			t.Errorf("metrics must not contain raw key %q", key)
+		}
+	}
+}
+
+func TestServer_BinaryValue(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	// Все возможные значения байта, включая не-UTF-8 последовательности
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(`{"key":"binary","value_b64":"` + encoded + `"}`); code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/binary", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		Value    interface{} `json:"value"`
+		ValueB64 string      `json:"value_b64"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(response.ValueB64)
+	if err != nil {
+		t.Fatalf("invalid value_b64 in response: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("binary value was not preserved byte-for-byte")
+	}
+	if response.Value != nil {
+		t.Errorf("expected null value for binary entry, got %v", response.Value)
+	}
+
+	// Некорректный base64 и одновременное указание value и value_b64
+	if code := post(`{"key":"invalid","value_b64":"not base64!"}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid base64, got %d", code)
+	}
+	if code := post(`{"key":"both","value":"text","value_b64":"AA=="}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for both value fields, got %d", code)
+	}
+}
+
+func TestServer_ExportImportBinaryValue(t *testing.T) {
+	log := logger.NewLogger("DEBUG")
+	source := cache.NewLRUCache(10, time.Minute)
+	_ = source.Put(context.Background(), "binary", []byte{0x00, 0xff, 0x10}, 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/export", nil)
+	w := httptest.NewRecorder()
+	NewServer(source, log).ServeHTTP(w, req)
+
+	target := cache.NewLRUCache(10, time.Minute)
+	req = httptest.NewRequest(http.MethodPost, "/api/lru/import", bytes.NewReader(w.Body.Bytes()))
+	NewServer(target, log).ServeHTTP(httptest.NewRecorder(), req)
+
+	value, _, err := target.Get(context.Background(), "binary")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, ok := value.([]byte); !ok || !bytes.Equal(data, []byte{0x00, 0xff, 0x10}) {
+		t.Errorf("expected binary value to survive export and import, got %#v", value)
+	}
+}
+
+func TestServer_TenantIsolation(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithTenantHeader("X-Tenant-ID"))
+
+	do := func(method, path, tenant, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Одинаковый логический ключ у двух арендаторов
+	for _, tenant := range []string{"acme", "globex"} {
+		if w := do(http.MethodPost, "/api/lru", tenant, `{"key":"shared","value":"`+tenant+`"}`); w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201 for tenant %s, got %d", tenant, w.Code)
+		}
+	}
+	if cacheInstance.Len() != 2 {
+		t.Fatalf("expected 2 separate entries, got %d", cacheInstance.Len())
+	}
+
+	for _, tenant := range []string{"acme", "globex"} {
+		w := do(http.MethodGet, "/api/lru/shared", tenant, "")
+		var response struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		if response.Key != "shared" || response.Value != tenant {
+			t.Errorf("tenant %s got key %q value %q", tenant, response.Key, response.Value)
+		}
+	}
+
+	// GetAll возвращает только ключи арендатора без префикса
+	w := do(http.MethodGet, "/api/lru", "acme", "")
+	var all struct {
+		Keys []string `json:"keys"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &all)
+	if len(all.Keys) != 1 || all.Keys[0] != "shared" {
+		t.Errorf("expected only tenant keys, got %v", all.Keys)
+	}
+
+	// Удаление всех элементов затрагивает только арендатора
+	if w := do(http.MethodDelete, "/api/lru", "acme", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/lru/shared", "globex", ""); w.Code != http.StatusOK {
+		t.Errorf("expected other tenant's key to survive, got %d", w.Code)
+	}
+
+	// Запрос без заголовка арендатора отклоняется
+	if w := do(http.MethodGet, "/api/lru/shared", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without tenant header, got %d", w.Code)
+	}
+}
+
+func TestServer_MetricsRuntime(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, name := range []string{"go_goroutines", "go_memstats_heap_alloc_bytes", "go_gc_duration_seconds", "cache_entries"} {
+		if !strings.Contains(body, "\n"+name) {
+			t.Errorf("expected metric %s in scrape", name)
+		}
+	}
+}
+
+func TestServer_CreateSliding(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"session","value":"v","ttl_seconds":2,"sliding":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	_, initial, _ := cacheInstance.Get(context.Background(), "session")
+	time.Sleep(10 * time.Millisecond)
+
+	// Чтение через API продлевает срок жизни элемента
+	req = httptest.NewRequest(http.MethodGet, "/api/lru/session", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	_, extended, _ := cacheInstance.Get(context.Background(), "session")
+	if !extended.After(initial) {
+		t.Errorf("expected sliding TTL to be extended, was %s now %s", initial, extended)
+	}
+}
+
+func TestServer_CreateValidationErrors(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	// Пустой ключ, отсутствующее значение и отрицательный TTL
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"","ttl_seconds":-5}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var response struct {
+		Errors []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	fields := map[string]bool{}
+	for _, e := range response.Errors {
+		fields[e.Field] = true
+		if e.Message == "" {
+			t.Errorf("expected message for field %s", e.Field)
+		}
+	}
+	for _, field := range []string{"key", "value", "ttl_seconds"} {
+		if !fields[field] {
+			t.Errorf("expected error for field %s, got %+v", field, response.Errors)
+		}
+	}
+	if cacheInstance.Len() != 0 {
+		t.Error("expected nothing to be stored")
+	}
+}
+
+func TestServer_ReservedKeys(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	r := NewServer(cacheInstance, log)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Ключ, совпадающий со служебным маршрутом, нельзя было бы прочитать, поэтому запись отклоняется
+	for _, body := range []string{`{"key":"stats","value":1}`, `{"key_template":"{name}","value":{"name":"export"}}`} {
+		if w := send(http.MethodPost, "/api/lru", body); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+	if w := send(http.MethodPut, "/api/lru", `{"entries":[{"key":"recent","value":1}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for replace, got %d", w.Code)
+	}
+	w := send(http.MethodPost, "/api/lru/import", `{"key":"touch","value":1}`+"\n")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"failed":1`) {
+		t.Errorf("expected reserved key to fail import, got %d %s", w.Code, w.Body.String())
+	}
+	if cacheInstance.Len() != 0 {
+		t.Errorf("expected nothing to be stored, got %d entries", cacheInstance.Len())
+	}
+
+	// Ключи, лишь начинающиеся со служебного имени, читаются как обычно
+	if w := send(http.MethodPost, "/api/lru", `{"key":"stats/daily","value":1}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/api/lru/stats/daily", ""); w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestServer_CreateNullValue(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	// Значение null отклоняется, чтобы Get не путал его с отсутствием значения
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"k","value":null}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"field":"value"`) {
+		t.Errorf("expected validation error for value, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/lru/k", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected null value not to be stored, got status %d", w.Code)
+	}
+}
+
+func TestServer_ResolveClientIP(t *testing.T) {
+	trusted := &Server{trustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	untrusted := &Server{}
+
+	tests := []struct {
+		name       string
+		server     *Server
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expected   string
+	}{
+		{"no proxies ignores headers", untrusted, "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"untrusted peer ignores headers", trusted, "203.0.113.7:5000", "198.51.100.1", "", "203.0.113.7"},
+		{"trusted proxy forwards client", trusted, "10.0.0.1:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed leftmost hop is skipped", trusted, "10.0.0.1:5000", "1.2.3.4, 198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"trusted proxy with real ip", trusted, "10.0.0.1:5000", "", "198.51.100.3", "198.51.100.3"},
+		{"trusted proxy without headers", trusted, "10.0.0.1:5000", "", "", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/lru", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if ip := tt.server.resolveClientIP(req); ip != tt.expected {
+				t.Errorf("expected client IP %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestServer_Recent(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	for _, key := range []string{"a", "b", "c"} {
+		_ = cacheInstance.Put(context.Background(), key, key, 0)
+	}
+
+	get := func(query string) (int, []string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/lru/recent"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var response struct {
+			Items []struct {
+				Key       string `json:"key"`
+				ExpiresAt int64  `json:"expires_at"`
+			} `json:"items"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		var keys []string
+		for _, item := range response.Items {
+			keys = append(keys, item.Key)
+			if item.ExpiresAt == 0 {
+				t.Errorf("expected expiry for key %s", item.Key)
+			}
+		}
+		return w.Code, keys
+	}
+
+	if code, keys := get("?n=2"); code != http.StatusOK || strings.Join(keys, ",") != "c,b" {
+		t.Errorf("expected 200 with c,b, got %d with %v", code, keys)
+	}
+	if _, keys := get("?n=50"); strings.Join(keys, ",") != "c,b,a" {
+		t.Errorf("expected all entries, got %v", keys)
+	}
+	if code, _ := get("?n=0"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for n=0, got %d", code)
+	}
+}
+
+func TestServer_Least(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	for _, key := range []string{"a", "b", "c"} {
+		_ = cacheInstance.Put(context.Background(), key, key, 0)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/least?n=2", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Items []struct {
+			Key string `json:"key"`
+		} `json:"items"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Items) != 2 || response.Items[0].Key != "a" || response.Items[1].Key != "b" {
+		t.Errorf("expected a,b as eviction candidates, got %+v", response.Items)
+	}
+}
+
+func TestServer_DeleteConditional(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	del := func(key, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/lru/"+key, nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+	getW := httptest.NewRecorder()
+	r.ServeHTTP(getW, httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil))
+	etag := getW.Header().Get("ETag")
+	lastModified := getW.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("expected Last-Modified header on GET")
+	}
+
+	// Элемент обновлён другим клиентом после чтения
+	_ = cacheInstance.Put(context.Background(), "key1", "value2", 0)
+	if w := del("key1", "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 for stale ETag, got %d", w.Code)
+	}
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if w := del("key1", "If-Unmodified-Since", past); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 for entry modified since, got %d", w.Code)
+	}
+	if _, _, err := cacheInstance.Get(context.Background(), "key1"); err != nil {
+		t.Fatalf("expected entry to be kept, got %v", err)
+	}
+
+	// Условие выполнено
+	getW = httptest.NewRecorder()
+	r.ServeHTTP(getW, httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil))
+	if w := del("key1", "If-Match", getW.Header().Get("ETag")); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 for matching ETag, got %d", w.Code)
+	}
+
+	_ = cacheInstance.Put(context.Background(), "key2", "value", 0)
+	getW = httptest.NewRecorder()
+	r.ServeHTTP(getW, httptest.NewRequest(http.MethodGet, "/api/lru/key2", nil))
+	if w := del("key2", "If-Unmodified-Since", getW.Header().Get("Last-Modified")); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 for unmodified entry, got %d", w.Code)
+	}
+	if cacheInstance.Len() != 0 {
+		t.Errorf("expected empty cache, got %d entries", cacheInstance.Len())
+	}
+}
+
+func TestServer_Touch(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "a", "a", time.Second)
+	_ = cacheInstance.Put(context.Background(), "b", "b", time.Second)
+
+	body := `{"keys":["a","b","missing"],"ttl_seconds":3600}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lru/touch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Touched int `json:"touched"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Touched != 2 {
+		t.Errorf("expected 2 touched keys, got %d", response.Touched)
+	}
+	if _, expiresAt, _ := cacheInstance.Get(context.Background(), "a"); time.Until(expiresAt) < 59*time.Minute {
+		t.Errorf("expected extended TTL, got %s", time.Until(expiresAt))
+	}
+
+	// Пустой список ключей отклоняется
+	req = httptest.NewRequest(http.MethodPost, "/api/lru/touch", bytes.NewBufferString(`{"keys":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for empty keys, got %d", w.Code)
+	}
+}
+
+func TestServer_CreateUnsupportedContentType(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	for _, contentType := range []string{"", "application/x-www-form-urlencoded", "text/plain"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"key1","value":"value1"}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected status 415 for Content-Type %q, got %d", contentType, w.Code)
+		}
+	}
+	if cacheInstance.Len() != 0 {
+		t.Errorf("expected no entries to be created, got %d", cacheInstance.Len())
+	}
+
+	// Параметры типа допускаются
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"key1","value":"value1"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status 201 with charset parameter, got %d", w.Code)
+	}
+}
+
+func TestServer_Draining(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	shutdown := make(chan struct{})
+	r := NewServer(cacheInstance, log, WithShutdownSignal(shutdown))
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 before shutdown, got %d", w.Code)
+	}
+
+	// После сигнала остановки новые запросы к кэшу отклоняются
+	close(shutdown)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/key1", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 during shutdown, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	// Служебные маршруты остаются доступны
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for /metrics during shutdown, got %d", w.Code)
+	}
+
+	// Закрытый кэш также переводит сервер в состояние остановки
+	closedCache := cache.NewLRUCache(10, time.Minute)
+	_ = closedCache.Close()
+	w = httptest.NewRecorder()
+	NewServer(closedCache, log).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 for closed cache, got %d", w.Code)
+	}
+}
+
+func TestServer_MetricsSoftLimit(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute, cache.WithSoftLimit(2))
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Body.String()
+	}
+
+	if body := scrape(); !strings.Contains(body, "\ncache_soft_limit_reached 0\n") {
+		t.Error("expected cache_soft_limit_reached 0 before crossing the soft limit")
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		_ = cacheInstance.Put(context.Background(), key, key, 0)
+	}
+	if body := scrape(); !strings.Contains(body, "\ncache_soft_limit_reached 1\n") {
+		t.Error("expected cache_soft_limit_reached 1 after crossing the soft limit")
+	}
+}
+
+func TestServer_MetricsTTLHistogram(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, 0)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	_ = cacheInstance.Put(context.Background(), "minute", "v", 30*time.Minute)
+	_ = cacheInstance.Put(context.Background(), "day", "v", 24*time.Hour)
+	_ = cacheInstance.Put(context.Background(), "never", "v", 0)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`cache_entries_by_remaining_ttl{bucket="lt_1s"} 0`,
+		`cache_entries_by_remaining_ttl{bucket="lt_1h"} 1`,
+		`cache_entries_by_remaining_ttl{bucket="longer"} 1`,
+		`cache_entries_by_remaining_ttl{bucket="never"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("expected %q in metrics", line)
+		}
+	}
+}
+
+func TestServer_NotFoundSources(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	errorCode := func(path string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404 for %s, got %d", path, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != contentTypeJSON {
+			t.Errorf("expected JSON content type for %s, got %q", path, ct)
+		}
+		var response struct {
+			Code  string `json:"code"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("expected JSON body for %s: %v", path, err)
+		}
+		return response.Code
+	}
+
+	// Промах кэша и несуществующий маршрут различаются по коду ошибки в теле
+	if code := errorCode("/api/lru/missing"); code != "key_not_found" {
+		t.Errorf("expected key_not_found for cache miss, got %q", code)
+	}
+	if code := errorCode("/api/unknown"); code != "route_not_found" {
+		t.Errorf("expected route_not_found for unknown route, got %q", code)
+	}
+}
+
+func TestServer_GetMany(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "a", "1", 0)
+	_ = cacheInstance.Put(context.Background(), "b", "2", 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru?key=a&key=b&key=missing", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Entries map[string]struct {
+			Found     bool        `json:"found"`
+			Value     interface{} `json:"value"`
+			ExpiresAt int64       `json:"expires_at"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", response.Entries)
+	}
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		entry := response.Entries[key]
+		if !entry.Found || entry.Value != want || entry.ExpiresAt == 0 {
+			t.Errorf("unexpected entry for %s: %+v", key, entry)
+		}
+	}
+	if missing := response.Entries["missing"]; missing.Found || missing.Value != nil {
+		t.Errorf("expected missing key not to be found, got %+v", missing)
+	}
+}
+
+func TestServer_Age(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", time.Hour)
+	time.Sleep(20 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/age", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		OldestAgeSeconds float64 `json:"oldest_age_seconds"`
+		NextExpiry       int64   `json:"next_expiry"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if response.OldestAgeSeconds < 0.02 || response.OldestAgeSeconds > 1 {
+		t.Errorf("expected oldest age of about 20ms, got %gs", response.OldestAgeSeconds)
+	}
+	if until := time.Until(time.Unix(response.NextExpiry, 0)); until < 59*time.Minute {
+		t.Errorf("expected next expiry in about an hour, got %s", until)
+	}
+}
+
+func TestServer_HitRatio(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", time.Hour)
+
+	// 3 попадания и 1 промах
+	for _, key := range []string{"key1", "key1", "missing", "key1"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lru/"+key, nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Hits          uint64  `json:"hits"`
+		Misses        uint64  `json:"misses"`
+		HitRatio      float64 `json:"hit_ratio"`
+		WindowSeconds int64   `json:"window_seconds"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Hits != 3 || response.Misses != 1 || math.Abs(response.HitRatio-0.75) > 1e-9 {
+		t.Errorf("expected 3 hits, 1 miss and ratio 0.75, got %+v", response)
+	}
+	if response.WindowSeconds != 60 {
+		t.Errorf("expected 60s window, got %d", response.WindowSeconds)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "\ncache_hit_ratio 0.75\n") {
+		t.Error("expected cache_hit_ratio 0.75 in metrics")
+	}
+}
+
+func TestServer_SlowRequestLog(t *testing.T) {
+	var logs bytes.Buffer
+	s := &Server{
+		log:           slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn})),
+		slowThreshold: 10 * time.Millisecond,
+	}
+	r := chi.NewRouter()
+	r.Use(s.loggingMiddleware)
+	r.Get("/slow/{id}", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	})
+	r.Get("/fast", func(w http.ResponseWriter, r *http.Request) {})
+
+	// Быстрый запрос не попадает в лог уровня WARN
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if logs.Len() != 0 {
+		t.Errorf("expected no warnings for fast request, got %q", logs.String())
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow/1", nil))
+	line := logs.String()
+	for _, want := range []string{"level=WARN", `msg="Slow request"`, "route=/slow/{id}", "duration="} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %s in slow request log, got %q", want, line)
+		}
+	}
+}
+
+func TestServer_CombinedAccessLog(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	var accessLog bytes.Buffer
+	r := NewServer(cacheInstance, log, WithCombinedAccessLog(&accessLog))
+	_ = cacheInstance.Put(context.Background(), "key1", "value1", 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lru/key1?x=1", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("Referer", "https://example.com/page")
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	line := accessLog.String()
+	pattern := `^203\.0\.113\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+		`"GET /api/lru/key1\?x=1 HTTP/1\.1" 200 (\d+) "https://example\.com/page" "test-agent/1\.0"\n$`
+	match := regexp.MustCompile(pattern).FindStringSubmatch(line)
+	if match == nil {
+		t.Fatalf("unexpected access log line %q", line)
+	}
+	if match[1] != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("expected %d bytes in access log, got %s", w.Body.Len(), match[1])
+	}
+}
+
+func TestServer_AdminListener(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	admin := chi.NewRouter()
+	public := httptest.NewServer(NewServer(cacheInstance, log, WithAdminRouter(admin)))
+	defer public.Close()
+	private := httptest.NewServer(admin)
+	defer private.Close()
+
+	status := func(baseURL, path string) int {
+		t.Helper()
+		resp, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Служебные маршруты доступны только на служебном порту
+	for _, path := range []string{"/metrics", "/healthz", "/debug/pprof/"} {
+		if code := status(private.URL, path); code != http.StatusOK {
+			t.Errorf("expected %s on admin port to return 200, got %d", path, code)
+		}
+		if code := status(public.URL, path); code != http.StatusNotFound {
+			t.Errorf("expected %s on main port to return 404, got %d", path, code)
+		}
+	}
+
+	// API кэша остаётся на основном порту
+	if code := status(public.URL, "/api/lru"); code != http.StatusNoContent {
+		t.Errorf("expected API on main port, got %d", code)
+	}
+	if code := status(private.URL, "/api/lru"); code != http.StatusNotFound {
+		t.Errorf("expected API to be absent on admin port, got %d", code)
+	}
+}
+
+func TestServer_FindByValue(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	admin := chi.NewRouter()
+	public := NewServer(cacheInstance, log, WithAdminRouter(admin))
+	_ = cacheInstance.Put(context.Background(), "a", "target", 0)
+	_ = cacheInstance.Put(context.Background(), "b", "other", 0)
+	_ = cacheInstance.Put(context.Background(), "c", "target", 0)
+	_ = cacheInstance.Put(context.Background(), "n", json.Number("42"), 0)
+
+	find := func(router http.Handler, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/lru/find?"+query, nil))
+		return w
+	}
+
+	// Точное совпадение значения с JSON-аргументом
+	w := find(admin, "predicate=equals&value="+url.QueryEscape(`"target"`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Keys  []string `json:"keys"`
+		Count int      `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if strings.Join(response.Keys, ",") != "c,a" || response.Count != 2 {
+		t.Errorf("expected keys c,a, got %+v", response)
+	}
+
+	w = find(admin, "predicate=equals&value=42")
+	if !strings.Contains(w.Body.String(), `"keys":["n"]`) {
+		t.Errorf("expected number match, got %s", w.Body.String())
+	}
+	w = find(admin, "predicate=type&value=string")
+	if !strings.Contains(w.Body.String(), `"count":3`) {
+		t.Errorf("expected 3 string values, got %s", w.Body.String())
+	}
+
+	// Некорректные аргументы отклоняются
+	for _, query := range []string{"", "predicate=regex&value=x", "predicate=equals&value=target", "predicate=type&value=date"} {
+		if w := find(admin, query); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %q, got %d", query, w.Code)
+		}
+	}
+
+	// Профилирование по-прежнему обслуживается под /debug
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected pprof to remain available, got %d", w.Code)
+	}
+
+	// На основном порту маршрут недоступен
+	if w := find(public, "predicate=equals&value=1"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 on main port, got %d", w.Code)
+	}
+}
+
+func TestServer_Version(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	shutdown := make(chan struct{})
+	r := NewServer(cacheInstance, logger.NewLogger("ERROR"), WithShutdownSignal(shutdown))
+
+	// Без -ldflags возвращаются значения по умолчанию; маршрут доступен и во время остановки
+	close(shutdown)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+	var response map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response["version"] != "dev" || response["commit"] != "unknown" || response["build_time"] != "unknown" {
+		t.Errorf("expected default build metadata, got %v", response)
+	}
+}
+
+func TestServer_Health(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	shutdown := make(chan struct{})
+	r := NewServer(cacheInstance, log, WithShutdownSignal(shutdown))
+
+	// Без отдельного порта /healthz обслуживается основным маршрутизатором, а pprof не подключён
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected pprof to be disabled, got %d", w.Code)
+	}
+
+	// Во время остановки проверка работоспособности не проходит
+	close(shutdown)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
+
+func TestServer_CaseInsensitiveTenantKeys(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute, cache.WithCaseInsensitiveKeys(true))
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithTenantHeader("X-Tenant-ID"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"User:1","value":"v"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "Acme")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+
+	// Ключ и идентификатор арендатора не зависят от регистра, в том числе в списке элементов
+	req = httptest.NewRequest(http.MethodGet, "/api/lru/USER:1", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/lru", nil)
+	req.Header.Set("X-Tenant-ID", "ACME")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"keys":["user:1"]`) {
+		t.Errorf("expected normalized key in listing, got %s", w.Body.String())
+	}
+}
+
+func TestServer_Pop(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "jobs/1", "payload", 0)
+
+	pop := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		return w
+	}
+
+	// Первый запрос получает значение, повторный — 404
+	w := pop("/api/lru/jobs%2F1/pop")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Key != "jobs/1" || response.Value != "payload" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if w := pop("/api/lru/jobs/1/pop"); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), errorCodeKeyNotFound) {
+		t.Errorf("expected key_not_found after pop, got %d %s", w.Code, w.Body.String())
+	}
+
+	// POST по пути ключа без суффикса /pop не сопоставлен маршруту
+	if w := pop("/api/lru/jobs/1"); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), errorCodeRouteNotFound) {
+		t.Errorf("expected route_not_found, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServer_JSONContentType(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "key", "value", 0)
+
+	// Успешный ответ содержит кодировку
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/key", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("expected JSON content type with charset, got %q", ct)
+	}
+
+	// Ошибки также возвращаются в формате JSON
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`invalid`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var response struct {
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("expected JSON error body, got %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusBadRequest || response.Code != errorCodeDecode || response.Error == "" {
+		t.Errorf("unexpected error response %d %+v", w.Code, response)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeJSON {
+		t.Errorf("expected JSON content type for error, got %q", ct)
+	}
+}
+
+func TestServer_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithTracerProvider(tp))
+	_ = cacheInstance.Put(context.Background(), "key", "value", 0)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/key", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected server and cache spans, got %d", len(spans))
+	}
+	cacheSpan, serverSpan := spans[0], spans[1]
+
+	// Операция с кэшем записывается дочерним спаном серверного спана
+	if cacheSpan.Name != "cache.get" || cacheSpan.Parent.SpanID() != serverSpan.SpanContext.SpanID() {
+		t.Errorf("expected cache.get child span, got %q with parent %s", cacheSpan.Name, cacheSpan.Parent.SpanID())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range cacheSpan.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["cache.key"].AsString() != "key" || !attrs["cache.hit"].AsBool() {
+		t.Errorf("unexpected cache span attributes %v", cacheSpan.Attributes)
+	}
+	if ttl := attrs["cache.ttl_seconds"].AsInt64(); ttl <= 0 || ttl > 60 {
+		t.Errorf("expected ttl within default TTL, got %d", ttl)
+	}
+	if serverSpan.Name != "GET /api/lru/*" || serverSpan.SpanKind != trace.SpanKindServer {
+		t.Errorf("unexpected server span %q kind %s", serverSpan.Name, serverSpan.SpanKind)
+	}
+
+	// Промах отмечается в атрибуте cache.hit
+	exporter.Reset()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/missing", nil))
+	spans = exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	for _, kv := range spans[0].Attributes {
+		if kv.Key == "cache.hit" && kv.Value.AsBool() {
+			t.Error("expected cache miss to be recorded")
+		}
+	}
+}
+
+func TestServer_CreateEcho(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, 2*time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	create := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"key":"k","value":"v"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Без echo тело ответа пустое
+	if w := create("/api/lru"); w.Code != http.StatusCreated || w.Body.Len() != 0 {
+		t.Errorf("expected empty 201 response, got %d %q", w.Code, w.Body.String())
+	}
+
+	// С echo=true ответ содержит срок жизни по TTL сервера по умолчанию
+	before := time.Now()
+	w := create("/api/lru?echo=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for update, got %d", w.Code)
+	}
+	var response struct {
+		Key              string `json:"key"`
+		ExpiresAt        int64  `json:"expires_at"`
+		RemainingSeconds int64  `json:"remaining_seconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := before.Add(2 * time.Minute).Unix()
+	if response.Key != "k" || response.ExpiresAt < expected || response.ExpiresAt > expected+1 {
+		t.Errorf("expected expiry near %d, got %+v", expected, response)
+	}
+	if response.RemainingSeconds < 119 || response.RemainingSeconds > 120 {
+		t.Errorf("expected about 120 remaining seconds, got %d", response.RemainingSeconds)
+	}
+}
+
+func TestServer_RawJSONValues(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithRawJSONValues(true))
+
+	// Порядок ключей и запись чисел отличаются от результата повторного кодирования
+	value := `{"z":1,"a":{"y":1.50,"b":[1e3,-0.0]},"m":"é"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"doc","value": `+value+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/doc", nil))
+	if !strings.Contains(w.Body.String(), `"value":`+value+`,`) {
+		t.Errorf("expected value %s returned verbatim, got %s", value, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru", nil))
+	if !strings.Contains(w.Body.String(), `"values":[`+value+`]`) {
+		t.Errorf("expected value returned verbatim in listing, got %s", w.Body.String())
+	}
+}
+
+func TestServer_JSONNumbers(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithJSONNumbers(true))
+
+	// 2^63-1 не представимо в float64 без потери точности
+	value := `{"id":9223372036854775807,"count":5,"ratio":0.25}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"big","value":`+value+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/big", nil))
+	for _, want := range []string{`"id":9223372036854775807`, `"count":5,`, `"ratio":0.25`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %s in response, got %s", want, w.Body.String())
+		}
+	}
+
+	// Импорт также сохраняет точность целых чисел
+	req = httptest.NewRequest(http.MethodPost, "/api/lru/import", strings.NewReader(`{"key":"imported","value":9007199254740993}`+"\n"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	imported, _, _ := cacheInstance.Get(context.Background(), "imported")
+	if n, ok := imported.(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Errorf("expected imported json.Number 9007199254740993, got %#v", imported)
+	}
+
+	// Без параметра большие целые числа теряют точность при разборе в float64
+	plain := NewServer(cache.NewLRUCache(10, time.Minute), log)
+	req = httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(`{"key":"big","value":9007199254740993}`))
+	req.Header.Set("Content-Type", "application/json")
+	plain.ServeHTTP(httptest.NewRecorder(), req)
+	w = httptest.NewRecorder()
+	plain.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/big", nil))
+	if strings.Contains(w.Body.String(), "9007199254740993") {
+		t.Errorf("expected precision loss without WithJSONNumbers, got %s", w.Body.String())
+	}
+}
+
+func TestServer_CreateMaxTTL(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute, cache.WithMaxTTL(time.Hour))
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	create := func(key string, ttlSeconds int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"key":%q,"value":"v","ttl_seconds":%d}`, key, ttlSeconds)
+		req := httptest.NewRequest(http.MethodPost, "/api/lru?echo=true", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// TTL в пределах ограничения не помечается
+	w := create("within", 60)
+	if w.Code != http.StatusCreated || w.Header().Get("X-Cache-TTL-Clamped") != "" {
+		t.Errorf("expected unclamped 201, got %d %q", w.Code, w.Header().Get("X-Cache-TTL-Clamped"))
+	}
+
+	// Больший TTL уменьшается до ограничения, о чём сообщают заголовок и поле ответа
+	w = create("above", 86400)
+	if w.Code != http.StatusCreated || w.Header().Get("X-Cache-TTL-Clamped") != "true" {
+		t.Fatalf("expected clamped 201, got %d %q", w.Code, w.Header().Get("X-Cache-TTL-Clamped"))
+	}
+	var response struct {
+		RemainingSeconds int64 `json:"remaining_seconds"`
+		TTLClamped       bool  `json:"ttl_clamped"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !response.TTLClamped || response.RemainingSeconds < 3599 || response.RemainingSeconds > 3600 {
+		t.Errorf("expected clamped expiry in about 3600 seconds, got %+v", response)
+	}
+}
+
+func TestServer_GetLongPoll(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log, WithMaxWait(time.Second))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Ожидающий запрос получает значение, записанное параллельно
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- get("/api/lru/job?wait=5s")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := cacheInstance.Put(context.Background(), "job", "result", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := <-done
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"value":"result"`) {
+		t.Errorf("expected 200 with value, got %d %s", w.Code, w.Body.String())
+	}
+
+	// По истечении ожидания, ограниченного максимумом сервера, возвращается 404
+	start := time.Now()
+	if w := get("/api/lru/missing?wait=1h"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after wait, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("expected wait capped at 1s, took %s", elapsed)
+	}
+
+	if w := get("/api/lru/missing?wait=soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid wait, got %d", w.Code)
+	}
+}
+
+func TestServer_Changes(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	type changesResponse struct {
+		Version uint64 `json:"version"`
+		Changes []struct {
+			Key     string `json:"key"`
+			Deleted bool   `json:"deleted"`
+		} `json:"changes"`
+	}
+	changes := func(since uint64) changesResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/lru/changes?since=%d", since), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response changesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	_ = cacheInstance.Put(context.Background(), "a", "1", 0)
+	initial := changes(0)
+	if len(initial.Changes) != 1 || initial.Changes[0].Key != "a" {
+		t.Fatalf("expected initial sync with a, got %+v", initial)
+	}
+
+	// Запись и удаление после версии возвращаются, а версия растёт
+	_ = cacheInstance.Put(context.Background(), "b", "2", 0)
+	_, _ = cacheInstance.Evict(context.Background(), "a")
+	next := changes(initial.Version)
+	if next.Version <= initial.Version {
+		t.Errorf("expected version to advance past %d, got %d", initial.Version, next.Version)
+	}
+	if len(next.Changes) != 2 || next.Changes[0].Key != "b" || next.Changes[0].Deleted ||
+		next.Changes[1].Key != "a" || !next.Changes[1].Deleted {
+		t.Errorf("expected [b -a], got %+v", next.Changes)
+	}
+
+	// После полной очистки инкрементальная синхронизация недоступна
+	_ = cacheInstance.EvictAll(context.Background())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/lru/changes?since=%d", next.Version), nil))
+	if w.Code != http.StatusGone || !strings.Contains(w.Body.String(), errorCodeChangesExpired) {
+		t.Errorf("expected 410 changes_expired, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServer_GetAllSnapshot(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute, cache.WithSnapshotInterval(time.Hour))
+	defer cacheInstance.Close()
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	_ = cacheInstance.Put(context.Background(), "a", "1", 0)
+	_, _ = cacheInstance.RefreshSnapshot(context.Background())
+	_ = cacheInstance.Put(context.Background(), "b", "2", 0)
+
+	// Список строится по последнему снимку, запись после него не видна
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Keys) != 1 || response.Keys[0] != "a" {
+		t.Errorf("expected keys from snapshot [a], got %v", response.Keys)
+	}
+	if w.Header().Get("Age") != "0" {
+		t.Errorf("expected Age header 0, got %q", w.Header().Get("Age"))
+	}
+
+	// Экспорт также использует снимок
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/export", nil))
+	if lines := strings.Count(w.Body.String(), "\n"); lines != 1 {
+		t.Errorf("expected 1 exported entry, got %d: %s", lines, w.Body.String())
+	}
+}
+
+func TestServer_PanicRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	s := &Server{log: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelError}))}
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(s.recoverMiddleware)
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("secret internal state")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeJSON {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+	var response struct {
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Code != errorCodeInternal {
+		t.Errorf("expected code %s, got %s", errorCodeInternal, response.Code)
+	}
+	// По умолчанию подробности паники не попадают в ответ
+	if strings.Contains(response.Error, "secret") || strings.Contains(response.Error, "goroutine") {
+		t.Errorf("expected no panic details in response, got %q", response.Error)
+	}
+	line := logs.String()
+	for _, want := range []string{"level=ERROR", `msg="Panic while handling request"`, `panic="secret internal state"`, "request_id=req-42", "runtime/debug.Stack"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %s in panic log, got %q", want, line)
+		}
+	}
+
+	// В подробном режиме ответ содержит значение паники и стек
+	s.verbosePanics = true
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if !strings.Contains(response.Error, "panic: secret internal state") || !strings.Contains(response.Error, "goroutine") {
+		t.Errorf("expected panic value and stack in verbose response, got %q", response.Error)
+	}
+}
+
+func TestServer_CreateCost(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(5, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		create(fmt.Sprintf(`{"key":%q,"value":"v"}`, key))
+	}
+	if w := create(`{"key":"d","value":"v","cost":4}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if used := cacheInstance.UsedCost(); used != 5 {
+		t.Errorf("expected total cost 5, got %d", used)
+	}
+	if cacheInstance.Len() != 2 {
+		t.Errorf("expected 2 entries after evicting cheap ones, got %d", cacheInstance.Len())
+	}
+
+	// Некорректная стоимость отклоняется ошибкой проверки поля
+	for _, body := range []string{`{"key":"e","value":"v","cost":0}`, `{"key":"e","value":"v","cost":6}`} {
+		w := create(body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"cost"`) {
+			t.Errorf("expected cost validation error for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestServer_TTL(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, 0)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "hour", "v", time.Hour)
+	_ = cacheInstance.Put(context.Background(), "forever", "v", 0)
+	_ = cacheInstance.Put(context.Background(), "expired", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	body := `{"keys":["hour","forever","expired","absent"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lru/ttl", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		RemainingSeconds map[string]int64 `json:"remaining_seconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if remaining := response.RemainingSeconds["hour"]; remaining < 3590 || remaining > 3600 {
+		t.Errorf("expected about an hour remaining, got %d", remaining)
+	}
+	if remaining := response.RemainingSeconds["forever"]; remaining != -1 {
+		t.Errorf("expected -1 for a non-expiring entry, got %d", remaining)
+	}
+	// Истёкшие и отсутствующие ключи в ответ не попадают
+	for _, key := range []string{"expired", "absent"} {
+		if remaining, ok := response.RemainingSeconds[key]; ok {
+			t.Errorf("expected %s to be omitted, got %d", key, remaining)
+		}
+	}
+
+	// Пустой список ключей отклоняется ошибкой проверки поля
+	req = httptest.NewRequest(http.MethodPost, "/api/lru/ttl", bytes.NewBufferString(`{"keys":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for empty keys, got %d", w.Code)
+	}
+}
+
+func TestServer_ReplaceAll(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "old", "v", 0)
+
+	replace := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := replace(`{"entries":[{"key":"a","value":1},{"key":"b","value_b64":"AQI="}]}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	keys, _, _ := cacheInstance.GetAll(context.Background())
+	if strings.Join(keys, ",") != "a,b" {
+		t.Errorf("expected keys a,b after replace, got %v", keys)
+	}
+
+	// Некорректный элемент отклоняется, и содержимое не меняется
+	w = replace(`{"entries":[{"key":"c","value":1},{"key":"","value":2}]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"entries[1].key"`) {
+		t.Errorf("expected entries[1].key validation error, got %d: %s", w.Code, w.Body.String())
+	}
+	w = replace(`{"entries":[{"key":"c","value":1},{"key":"c","value":2}]}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for duplicate keys, got %d", w.Code)
+	}
+	if cacheInstance.Len() != 2 {
+		t.Errorf("expected contents to stay unchanged, got %d entries", cacheInstance.Len())
+	}
+	if w := replace(`{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without entries, got %d", w.Code)
+	}
+}
+
+func TestServer_GetAllNotModified(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "a", "1", 0)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/lru", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", w.Code, etag)
+	}
+
+	// Без изменений возвращается 304 без тела
+	w = get(etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected empty 304, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := get(`"other", W/` + etag); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching weak ETag in a list, got %d", w.Code)
+	}
+
+	// После записи возвращается новое содержимое с другим ETag
+	_ = cacheInstance.Put(context.Background(), "b", "2", 0)
+	w = get(etag)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"b"`) {
+		t.Fatalf("expected fresh 200 after put, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("expected ETag to change after put")
+	}
+}
+
+func TestServer_GetAllOrder(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	for _, key := range []string{"b", "c", "a"} {
+		_ = cacheInstance.Put(context.Background(), key, "value-"+key, 0)
+	}
+
+	tests := []struct {
+		order string
+		keys  []string
+	}{
+		{"", []string{"a", "c", "b"}},
+		{"recency", []string{"a", "c", "b"}},
+		{"lru", []string{"b", "c", "a"}},
+		{"key", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru?order="+tt.order, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("order %q: expected status 200, got %d", tt.order, w.Code)
+		}
+		var response struct {
+			Keys   []string      `json:"keys"`
+			Values []interface{} `json:"values"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		if strings.Join(response.Keys, ",") != strings.Join(tt.keys, ",") {
+			t.Errorf("order %q: expected keys %v, got %v", tt.order, tt.keys, response.Keys)
+		}
+		// Значения переставляются вместе с ключами
+		for i, key := range response.Keys {
+			if response.Values[i] != "value-"+key {
+				t.Errorf("order %q: expected value-%s at %d, got %v", tt.order, key, i, response.Values[i])
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru?order=random", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown order, got %d", w.Code)
+	}
+}
+
+func TestServer_GetRaw(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("DEBUG")
+	r := NewServer(cacheInstance, log)
+	_ = cacheInstance.Put(context.Background(), "text", "hello", time.Hour)
+	_ = cacheInstance.Put(context.Background(), "blob", []byte{0, 1, 2}, time.Hour)
+	_ = cacheInstance.Put(context.Background(), "doc", map[string]interface{}{"a": 1.0}, time.Hour)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// По умолчанию значение возвращается в обёртке
+	w := get("/api/lru/text")
+	var envelope struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &envelope)
+	if w.Code != http.StatusOK || envelope.Key != "text" || envelope.Value != "hello" {
+		t.Fatalf("expected enveloped value, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Cache-Key") != "" {
+		t.Error("expected no X-Cache-Key header in envelope mode")
+	}
+
+	// Без обёртки тело — само значение, а ключ и срок жизни — в заголовках
+	w = get("/api/lru/text?raw=true")
+	if w.Code != http.StatusOK || w.Body.String() != "hello" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected raw text body, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if w.Header().Get("X-Cache-Key") != "text" || w.Header().Get("ETag") == "" {
+		t.Errorf("expected key and ETag headers, got %v", w.Header())
+	}
+	if remaining, _ := strconv.Atoi(w.Header().Get("X-Cache-Remaining-Seconds")); remaining < 3590 {
+		t.Errorf("expected about an hour remaining, got %q", w.Header().Get("X-Cache-Remaining-Seconds"))
+	}
+	if expiresAt, _ := strconv.ParseInt(w.Header().Get("X-Cache-Expires-At"), 10, 64); time.Until(time.Unix(expiresAt, 0)) < 59*time.Minute {
+		t.Errorf("expected expiry in about an hour, got %q", w.Header().Get("X-Cache-Expires-At"))
+	}
+
+	w = get("/api/lru/blob?raw=1")
+	if !bytes.Equal(w.Body.Bytes(), []byte{0, 1, 2}) || w.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("expected raw binary body, got %q: %v", w.Header().Get("Content-Type"), w.Body.Bytes())
+	}
+	w = get("/api/lru/doc?raw=true")
+	if strings.TrimSpace(w.Body.String()) != `{"a":1}` || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("expected raw JSON body, got %q: %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	if w := get("/api/lru/text?raw=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid raw, got %d", w.Code)
+	}
+	if w := get("/api/lru/missing?raw=true"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing key, got %d", w.Code)
+	}
+}
+
+func TestServer_DecodeVsRejectedErrors(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute, cache.WithMaxBytes(256))
+	log := logger.NewLogger("ERROR")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var response struct {
+			Code string `json:"code"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Code
+	}
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Body.String()
+	}
+
+	// Некорректное тело — ошибка клиента
+	if status, code := post(`{"key":`); status != http.StatusBadRequest || code != errorCodeDecode {
+		t.Errorf("expected 400 %s for malformed body, got %d %s", errorCodeDecode, status, code)
+	}
+	// Корректный запрос, отклонённый кэшем: значение больше бюджета памяти
+	large := strings.Repeat("x", 1024)
+	if status, code := post(`{"key":"large","value":"` + large + `"}`); status != http.StatusUnprocessableEntity || code != errorCodeRejected {
+		t.Errorf("expected 422 %s for a value over the byte budget, got %d %s", errorCodeRejected, status, code)
+	}
+	if status, _ := post(`{"key":"small","value":"ok"}`); status != http.StatusCreated {
+		t.Errorf("expected 201 for a valid entry, got %d", status)
+	}
+
+	body := scrape()
+	if !strings.Contains(body, `request_errors_total{kind="client_decode"} 1`) {
+		t.Error("expected one client_decode error in metrics")
+	}
+	if !strings.Contains(body, `request_errors_total{kind="cache_rejected"} 1`) {
+		t.Error("expected one cache_rejected error in metrics")
+	}
+}
+
+func TestServer_CreateKeyTemplate(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Ключ строится из полей значения
+	w := post(`{"key_template":"{tenant}:{id}","value":{"tenant":"acme","id":42,"name":"widget"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if location := w.Header().Get("Location"); location != "/api/lru/acme:42" {
+		t.Errorf("expected Location of the computed key, got %q", location)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru/acme:42", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"widget"`) {
+		t.Errorf("expected entry under the computed key, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Отсутствующее поле, не объект и одновременное указание key отклоняются
+	for _, body := range []string{
+		`{"key_template":"{tenant}:{id}","value":{"tenant":"acme"}}`,
+		`{"key_template":"{tenant}","value":"plain"}`,
+		`{"key_template":"{tenant}","key":"k","value":{"tenant":"acme"}}`,
+		`{"key_template":"{tenant","value":{"tenant":"acme"}}`,
+	} {
+		w := post(body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"key_template"`) {
+			t.Errorf("expected key_template validation error for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+	if cacheInstance.Len() != 1 {
+		t.Errorf("expected only the templated entry, got %d entries", cacheInstance.Len())
+	}
+}
+
+func TestServer_RequestLogger(t *testing.T) {
+	var logs bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewServer(cache.NewLRUCache(10, time.Minute), log)
+
+	send := func(method, path, body, requestID string) {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send(http.MethodGet, "/api/lru/missing", "", "req-7")
+	send(http.MethodPost, "/api/lru", `{"value":"v"}`, "req-8")
+	send(http.MethodPost, "/api/lru/import", "not json\n", "req-9")
+
+	// Записи обработчиков, вспомогательных функций и middleware содержат поля, установленные
+	// middleware, без их повторения в вызове
+	for _, tc := range []struct {
+		msg    string
+		fields []string
+	}{
+		{"Failed to get key from cache", []string{"method=GET", "path=/api/lru/missing", "request_id=req-7", "remote_ip=192.0.2.1"}},
+		{"Request completed", []string{"method=GET", "path=/api/lru/missing", "request_id=req-7", "remote_ip=192.0.2.1"}},
+		{"Request validation failed", []string{"method=POST", "path=/api/lru", "request_id=req-8", "remote_ip=192.0.2.1"}},
+		{"Invalid import line", []string{"method=POST", "path=/api/lru/import", "request_id=req-9", "remote_ip=192.0.2.1"}},
+	} {
+		var line string
+		for _, l := range strings.Split(logs.String(), "\n") {
+			if strings.Contains(l, fmt.Sprintf("msg=%q", tc.msg)) {
+				line = l
+				break
+			}
+		}
+		if line == "" {
+			t.Errorf("expected %q log line, got %q", tc.msg, logs.String())
+			continue
+		}
+		for _, field := range tc.fields {
+			if !strings.Contains(line, field) {
+				t.Errorf("expected %s in %q log line, got %q", field, tc.msg, line)
+			}
+		}
+		if strings.Count(line, "method=") != 1 {
+			t.Errorf("expected method to be logged once, got %q", line)
+		}
+	}
+}
+
+func TestServer_CreateIfAbsent(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru?if_absent=true", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"key":"key1","value":"first"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+
+	// Повторная запись не изменяет элемент и возвращает его текущее значение
+	w = post(`{"key":"key1","value":"second"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Code    string `json:"code"`
+		Current struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		} `json:"current"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Code != errorCodeKeyExists || response.Current.Key != "key1" || response.Current.Value != "first" {
+		t.Errorf("expected key_exists with the current value, got %s", w.Body.String())
+	}
+	if w.Header().Get("ETag") != etag {
+		t.Errorf("expected ETag of the current version %s, got %s", etag, w.Header().Get("ETag"))
+	}
+	if value, _, _ := cacheInstance.Get(context.Background(), "key1"); value != "first" {
+		t.Errorf("expected value to stay first, got %v", value)
+	}
+}
+
+func TestServer_Tags(t *testing.T) {
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	log := logger.NewLogger("ERROR")
+	r := NewServer(cacheInstance, log)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Теги возвращаются при чтении и не влияют на значение
+	if w := post(`{"key":"a","value":"v1","tags":{"source":"db","cache-control":"max-age=60"}}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	_ = post(`{"key":"b","value":"v2","tags":{"source":"api"}}`)
+	_ = post(`{"key":"c","value":"v3","tags":{"source":"db"}}`)
+	_ = post(`{"key":"d","value":"v4"}`)
+
+	var entry struct {
+		Value interface{}       `json:"value"`
+		Tags  map[string]string `json:"tags"`
+	}
+	if err := json.NewDecoder(get("/api/lru/a").Body).Decode(&entry); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if entry.Value != "v1" || entry.Tags["source"] != "db" || entry.Tags["cache-control"] != "max-age=60" {
+		t.Errorf("expected tags to round-trip, got %+v", entry)
+	}
+	if w := get("/api/lru/d"); strings.Contains(w.Body.String(), `"tags"`) {
+		t.Errorf("expected no tags for an untagged entry, got %s", w.Body.String())
+	}
+
+	// Список фильтруется по тегу
+	var list struct {
+		Keys   []string      `json:"keys"`
+		Values []interface{} `json:"values"`
+	}
+	w := get("/api/lru?tag=source:db&order=key")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if strings.Join(list.Keys, ",") != "a,c" || len(list.Values) != 2 || list.Values[0] != "v1" {
+		t.Errorf("expected entries a, c tagged source=db, got %+v", list)
+	}
+	if w := get("/api/lru?tag=source:cdn"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"keys":[]`) {
+		t.Errorf("expected empty list for an unmatched tag, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Некорректные имена тегов и фильтры отклоняются
+	if w := post(`{"key":"e","value":"v","tags":{"a:b":"x"}}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"tags"`) {
+		t.Errorf("expected tags validation error, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post(`{"key":"e","value":"v","tags":{"n":1}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected non-string tag value to be rejected, got %d", w.Code)
+	}
+	for _, filter := range []string{"source", ":db"} {
+		if w := get("/api/lru?tag=" + filter); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for tag filter %q, got %d", filter, w.Code)
+		}
+	}
+}
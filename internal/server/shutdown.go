@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPServer — http.Server, учитывающий число активных запросов для остановки
+// с ограниченным ожиданием (см. Drain).
+type HTTPServer struct {
+	*http.Server
+	inFlight atomic.Int64 // Число запросов, обработка которых ещё не завершилась
+}
+
+// track оборачивает обработчик подсчётом активных запросов.
+func (s *HTTPServer) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Drain корректно останавливает сервер: сразу прекращает приём новых соединений
+// и ждёт завершения активных запросов не дольше grace, после чего принудительно
+// закрывает оставшиеся соединения. Число запросов, не успевших завершиться,
+// записывается в журнал. Время ожидания не зависит от таймаутов Timeouts:
+// они ограничивают отдельные запросы, а grace — всю остановку. При grace, равном 0,
+// активные соединения закрываются без ожидания.
+func (s *HTTPServer) Drain(grace time.Duration, log *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	err := s.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	log.Warn("Shutdown grace period expired, closing remaining connections",
+		"addr", s.Addr,
+		"grace", grace,
+		"in_flight", s.inFlight.Load(),
+	)
+	return s.Close()
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"cache_service/internal/cache"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// tenantSeparator отделяет идентификатор арендатора от логического ключа.
+const tenantSeparator = ":"
+
+// tenantContextKey — ключ контекста запроса для префикса ключей арендатора.
+type tenantContextKey struct{}
+
+// WithTenantHeader включает разделение ключей по арендаторам. Идентификатор арендатора
+// берётся из заголовка header (например, X-Tenant-ID) и прозрачно добавляется префиксом
+// к каждому ключу, поэтому одинаковые логические ключи разных арендаторов не пересекаются.
+// Запросы без заголовка отклоняются с кодом 400. Пустая строка отключает разделение.
+// При регистронезависимых ключах кэша (cache.WithCaseInsensitiveKeys) идентификатор
+// арендатора, как часть ключа, также не зависит от регистра.
+func WithTenantHeader(header string) Option {
+	return func(s *Server) {
+		s.tenantHeader = header
+	}
+}
+
+// tenantMiddleware определяет арендатора запроса по заголовку и сохраняет
+// префикс его ключей в контексте запроса.
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(s.tenantHeader)
+		if tenant == "" || strings.Contains(tenant, tenantSeparator) {
+			s.requestLog(r).Warn("Missing or invalid tenant header", "header", s.tenantHeader, "tenant", tenant)
+			s.writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "missing or invalid "+s.tenantHeader+" header")
+			return
+		}
+		if s.cache.CaseInsensitiveKeys() {
+			tenant = strings.ToLower(tenant)
+		}
+		ctx := context.WithValue(r.Context(), tenantContextKey{}, tenant+tenantSeparator)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tenantPrefix возвращает префикс ключей арендатора запроса или пустую строку,
+// если разделение по арендаторам отключено.
+func tenantPrefix(ctx context.Context) string {
+	prefix, _ := ctx.Value(tenantContextKey{}).(string)
+	return prefix
+}
+
+// tenantKey возвращает ключ кэша для логического ключа арендатора.
+// Пустой ключ не изменяется, чтобы кэш отклонил его как обычно.
+func tenantKey(ctx context.Context, key string) string {
+	if key == "" {
+		return key
+	}
+	return tenantPrefix(ctx) + key
+}
+
+// tenantOwns сообщает, принадлежит ли ключ кэша арендатору запроса,
+// и возвращает логический ключ без префикса.
+func tenantOwns(ctx context.Context, key string) (string, bool) {
+	prefix := tenantPrefix(ctx)
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	return key[len(prefix):], true
+}
+
+// tenantEntries оставляет в списках ключей и значений только элементы арендатора
+// запроса, убирая префикс из ключей.
+func tenantEntries(ctx context.Context, keys []string, values []interface{}) ([]string, []interface{}) {
+	if tenantPrefix(ctx) == "" {
+		return keys, values
+	}
+	var ownKeys []string
+	var ownValues []interface{}
+	for i, key := range keys {
+		if logical, ok := tenantOwns(ctx, key); ok {
+			ownKeys = append(ownKeys, logical)
+			ownValues = append(ownValues, values[i])
+		}
+	}
+	return ownKeys, ownValues
+}
+
+// tenantItems оставляет в снимке только элементы арендатора запроса, убирая префикс из ключей.
+func tenantItems(ctx context.Context, items []cache.Item) []cache.Item {
+	if tenantPrefix(ctx) == "" {
+		return items
+	}
+	own := items[:0]
+	for _, item := range items {
+		if logical, ok := tenantOwns(ctx, item.Key); ok {
+			item.Key = logical
+			own = append(own, item)
+		}
+	}
+	return own
+}
+
+// evictAll очищает кэш, а при разделении по арендаторам удаляет только элементы арендатора запроса.
+func (s *Server) evictAll(ctx context.Context) error {
+	if tenantPrefix(ctx) == "" {
+		return s.cache.EvictAll(ctx)
+	}
+	items, err := s.cache.Items(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range tenantItems(ctx, items) {
+		// Элемент мог быть удалён или истечь после снятия снимка
+		_, _ = s.cache.Evict(ctx, tenantKey(ctx, item.Key))
+	}
+	return nil
+}
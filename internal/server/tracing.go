@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"net/http"
+	"time"
+)
+
+// tracerName — имя инструментирующей библиотеки в создаваемых спанах.
+const tracerName = "cache_service/internal/server"
+
+// WithTracerProvider включает трассировку запросов OpenTelemetry: на каждый запрос
+// создаётся серверный спан, а операции с кэшем записываются дочерними спанами с ключом,
+// признаком попадания и TTL. Контекст трассировки входящего запроса извлекается из
+// заголовков W3C Trace Context (traceparent). Без этого параметра или при nil
+// трассировка отключена и спаны не создаются.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *Server) {
+		if tp != nil {
+			s.tracer = tp.Tracer(tracerName)
+		}
+	}
+}
+
+// defaultTracer возвращает трассировщик, не создающий спанов.
+func defaultTracer() trace.Tracer {
+	return noop.NewTracerProvider().Tracer(tracerName)
+}
+
+// tracingMiddleware создаёт серверный спан на каждый запрос.
+//
+// Шаблон маршрута доступен только после сопоставления запроса маршрутизатором,
+// поэтому имя спана и атрибут http.route задаются после вызова следующего обработчика.
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	propagator := propagation.TraceContext{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := s.tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+		if !span.IsRecording() {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := routePattern(r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// startCacheSpan начинает дочерний спан операции с кэшем op для ключа key.
+func (s *Server) startCacheSpan(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "cache."+op, trace.WithAttributes(
+		attribute.String("cache.operation", op),
+		attribute.String("cache.key", key),
+	))
+}
+
+// endCacheSpan завершает спан операции с кэшем, записывая признак попадания hit
+// (для записи — элемент существовал), а при успешной операции — оставшееся время
+// жизни элемента cache.ttl_seconds (-1 — бессрочный элемент).
+func endCacheSpan(span trace.Span, hit bool, err error, expiresAt time.Time) {
+	span.SetAttributes(attribute.Bool("cache.hit", hit))
+	if err == nil {
+		span.SetAttributes(attribute.Int64("cache.ttl_seconds", remainingSeconds(expiresAt)))
+	}
+	span.End()
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+)
+
+// fieldError описывает ошибку проверки одного поля запроса.
+type fieldError struct {
+	Field   string `json:"field"`   // Имя поля тела или заголовка запроса
+	Message string `json:"message"` // Описание ошибки
+}
+
+// validationErrors накапливает ошибки проверки полей запроса, чтобы сообщить о них разом.
+type validationErrors []fieldError
+
+// add добавляет ошибку проверки поля.
+func (v *validationErrors) add(field, message string) {
+	*v = append(*v, fieldError{Field: field, Message: message})
+}
+
+// writeValidationErrors отвечает кодом 400 со списком всех ошибок проверки полей.
+//
+// Тело ответа (JSON):
+// - errors (array): Ошибки в виде пар field и message.
+func (s *Server) writeValidationErrors(w http.ResponseWriter, r *http.Request, errs validationErrors) {
+	s.requestLog(r).Error("Request validation failed", "errors", len(errs))
+	response := struct {
+		Errors validationErrors `json:"errors"`
+	}{
+		Errors: errs,
+	}
+	s.writeJSON(w, r, http.StatusBadRequest, response)
+}
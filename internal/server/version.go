@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+)
+
+// Сведения о сборке, задаются при компиляции через -ldflags, например:
+//
+//	go build -ldflags "-X cache_service/internal/server.Version=v1.2.0 \
+//	  -X cache_service/internal/server.Commit=$(git rev-parse --short HEAD) \
+//	  -X cache_service/internal/server.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/cache-service
+var (
+	Version   = "dev"     // Версия сборки
+	Commit    = "unknown" // Хеш коммита, из которого собран сервис
+	BuildTime = "unknown" // Время сборки в формате RFC 3339
+)
+
+// VersionHandler обрабатывает GET-запрос на получение сведений о сборке, чтобы
+// операторы могли убедиться, какая сборка развёрнута. Маршрут не обращается к кэшу
+// и доступен и во время остановки сервиса.
+//
+// Метод:
+// - GET /version
+//
+// Тело ответа (JSON):
+// - version (string): Версия сборки ("dev", если не задана).
+// - commit (string): Хеш коммита ("unknown", если не задан).
+// - build_time (string): Время сборки ("unknown", если не задано).
+//
+// Ответы:
+// - 200 OK: Сведения о сборке.
+func (s *Server) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Version   string `json:"version"`
+		Commit    string `json:"commit"`
+		BuildTime string `json:"build_time"`
+	}{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+	s.writeJSON(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Ошибки, соответствующие кодам ответа сервиса. Проверяются через errors.Is;
+// код и текст ответа доступны через errors.As с *StatusError.
+var (
+	// ErrNotFound возвращается, если элемент не найден или его срок жизни истёк (404).
+	ErrNotFound = errors.New("not found")
+	// ErrPreconditionFailed возвращается, если не выполнено условие запроса (412).
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnavailable возвращается, если сервис перегружен или останавливается (503).
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// maxErrorBody — максимальный размер тела ответа с ошибкой, сохраняемого в StatusError.
+const maxErrorBody = 4 << 10
+
+// StatusError описывает неуспешный ответ сервиса.
+type StatusError struct {
+	StatusCode int    // Код ответа
+	Message    string // Тело ответа
+}
+
+// Error возвращает описание ошибки с кодом ответа.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("cache service: status %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap возвращает ошибку, соответствующую коду ответа, или nil.
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Entry описывает элемент, полученный из кэша.
+type Entry struct {
+	Key       string      // Ключ элемента
+	Value     interface{} // Значение элемента; двоичные значения возвращаются как []byte
+	ExpiresAt time.Time   // Время истечения срока жизни (нулевое — бессрочный элемент)
+	ETag      string      // Версия элемента для условных запросов
+}
+
+// Client — клиент HTTP API кэш-сервиса.
+type Client struct {
+	baseURL    string       // Адрес сервиса без завершающего «/»
+	httpClient *http.Client // HTTP-клиент для выполнения запросов
+}
+
+// Option задаёт дополнительный параметр клиента.
+type Option func(*Client)
+
+// WithHTTPClient задаёт HTTP-клиент для выполнения запросов.
+// По умолчанию используется http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New создаёт клиент сервиса, доступного по адресу baseURL (например, http://localhost:8080).
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Put добавляет или обновляет элемент. Значение []byte передаётся как двоичное.
+// Если ttl равен 0, используется TTL по умолчанию сервиса.
+func (c *Client) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	body := struct {
+		Key      string      `json:"key"`
+		Value    interface{} `json:"value,omitempty"`
+		ValueB64 *string     `json:"value_b64,omitempty"`
+	}{
+		Key: key,
+	}
+	if raw, ok := value.([]byte); ok {
+		encoded := base64.StdEncoding.EncodeToString(raw)
+		body.ValueB64 = &encoded
+	} else {
+		body.Value = value
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/lru", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if ttl > 0 {
+		req.Header.Set("X-Cache-TTL", ttl.String())
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get возвращает элемент по ключу. Если элемент не найден, возвращается ErrNotFound.
+func (c *Client) Get(ctx context.Context, key string) (Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.keyURL(key), nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer resp.Body.Close()
+
+	var body entryBody
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Entry{}, fmt.Errorf("decode response: %w", err)
+	}
+
+	entry, err := body.entry()
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.ETag = resp.Header.Get("ETag")
+	return entry, nil
+}
+
+// Export возвращает все актуальные элементы от недавно использованных к давно
+// использованным, читая построчный ответ GET /api/lru/export. Поле ETag не заполняется.
+func (c *Client) Export(ctx context.Context) ([]Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/lru/export", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var entries []Entry
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var body entryBody
+		if err := decoder.Decode(&body); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		entry, err := body.entry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// entryBody описывает элемент в JSON-ответах сервиса.
+type entryBody struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ValueB64  *string     `json:"value_b64"`
+	ExpiresAt int64       `json:"expires_at"`
+}
+
+// entry преобразует элемент ответа в Entry, декодируя двоичное значение.
+func (b entryBody) entry() (Entry, error) {
+	entry := Entry{
+		Key:   b.Key,
+		Value: b.Value,
+	}
+	if b.ValueB64 != nil {
+		raw, err := base64.StdEncoding.DecodeString(*b.ValueB64)
+		if err != nil {
+			return Entry{}, fmt.Errorf("decode value_b64: %w", err)
+		}
+		entry.Value = raw
+	}
+	if b.ExpiresAt != 0 {
+		entry.ExpiresAt = time.Unix(b.ExpiresAt, 0)
+	}
+	return entry, nil
+}
+
+// Delete удаляет элемент по ключу. Если элемент не найден, возвращается ErrNotFound.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.keyURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// GetAll возвращает ключи и значения всех актуальных элементов.
+// Для пустого кэша возвращаются пустые срезы без ошибки.
+func (c *Client) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/lru", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil, nil
+	}
+
+	var body struct {
+		Keys   []string      `json:"keys"`
+		Values []interface{} `json:"values"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, nil, fmt.Errorf("decode response: %w", err)
+	}
+	return body.Keys, body.Values, nil
+}
+
+// keyURL возвращает адрес элемента с экранированным ключом.
+func (c *Client) keyURL(key string) string {
+	return c.baseURL + "/api/lru/" + url.PathEscape(key)
+}
+
+// do выполняет запрос и возвращает *StatusError для неуспешных кодов ответа.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
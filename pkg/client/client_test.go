@@ -0,0 +1,126 @@
+package client
+
+import (
+	"bytes"
+	"cache_service/internal/cache"
+	"cache_service/internal/logger"
+	"cache_service/internal/server"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient запускает сервер с настоящим маршрутизатором и возвращает клиент к нему.
+func newTestClient(t *testing.T, opts ...server.Option) *Client {
+	t.Helper()
+	cacheInstance := cache.NewLRUCache(10, time.Minute)
+	ts := httptest.NewServer(server.NewServer(cacheInstance, logger.NewLogger("ERROR"), opts...))
+	t.Cleanup(ts.Close)
+	return New(ts.URL, WithHTTPClient(ts.Client()))
+}
+
+func TestClient_PutGetDelete(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	if err := c.Put(ctx, "users/1", "alice", time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entry, err := c.Get(ctx, "users/1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Key != "users/1" || entry.Value != "alice" || entry.ETag == "" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if remaining := time.Until(entry.ExpiresAt); remaining < 59*time.Minute {
+		t.Errorf("expected TTL of about an hour, got %s", remaining)
+	}
+
+	// Двоичное значение возвращается в том же виде
+	if err := c.Put(ctx, "blob", []byte{0xff, 0x00}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry, err = c.Get(ctx, "blob"); err != nil || !bytes.Equal(entry.Value.([]byte), []byte{0xff, 0x00}) {
+		t.Errorf("expected binary value, got %v, %v", entry.Value, err)
+	}
+
+	if err := c.Delete(ctx, "users/1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Get(ctx, "users/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := c.Delete(ctx, "users/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for missing key, got %v", err)
+	}
+}
+
+func TestClient_GetAll(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	// Пустой кэш
+	keys, values, err := c.GetAll(ctx)
+	if err != nil || len(keys) != 0 || len(values) != 0 {
+		t.Fatalf("expected empty result, got %v, %v, %v", keys, values, err)
+	}
+
+	_ = c.Put(ctx, "a", "1", 0)
+	_ = c.Put(ctx, "b", float64(2), 0)
+	keys, values, err = c.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 2 || len(values) != 2 {
+		t.Errorf("expected 2 entries, got %v, %v", keys, values)
+	}
+}
+
+func TestClient_Export(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	// Пустой кэш
+	entries, err := c.Export(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected no entries, got %v, %v", entries, err)
+	}
+
+	_ = c.Put(ctx, "a", "1", time.Hour)
+	_ = c.Put(ctx, "b", []byte{1, 2}, 0)
+	entries, err = c.Export(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "b" || !bytes.Equal(entries[0].Value.([]byte), []byte{1, 2}) {
+		t.Fatalf("expected binary b first, got %+v", entries)
+	}
+	if entries[1].Key != "a" || entries[1].Value != "1" || time.Until(entries[1].ExpiresAt) < 59*time.Minute {
+		t.Errorf("expected a with an hour TTL, got %+v", entries[1])
+	}
+}
+
+func TestClient_StatusErrors(t *testing.T) {
+	shutdown := make(chan struct{})
+	close(shutdown)
+	c := newTestClient(t, server.WithShutdownSignal(shutdown))
+
+	err := c.Put(context.Background(), "key", "value", 0)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected StatusError with status 503, got %v", err)
+	}
+
+	// Неизвестные коды не сопоставляются ни с одной из ошибок
+	err = (&StatusError{StatusCode: http.StatusBadRequest}).Unwrap()
+	if err != nil {
+		t.Errorf("expected no sentinel for status 400, got %v", err)
+	}
+}
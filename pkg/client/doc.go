@@ -0,0 +1,7 @@
+// Package client реализует Go-клиент HTTP API кэш-сервиса.
+//
+// Основной функционал:
+// - Типизированные методы Put, Get, Delete, GetAll и Export поверх REST API /api/lru.
+// - Преобразование кодов ответа в ошибки (ErrNotFound, ErrPreconditionFailed, ErrUnavailable).
+// - Подключаемый *http.Client для настройки таймаутов, транспорта и повторов.
+package client